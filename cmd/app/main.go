@@ -1,17 +1,71 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"inditilla/config"
 	"inditilla/internal/app"
 	"log"
+	"os"
 )
 
-// Get config and run application with that config
+const usage = `Usage: app [command] [arguments]
+
+Commands:
+  serve                           start http server, the default command
+  migrate up                      apply all pending migrations
+  migrate down [N]                roll back N migrations, 1 by default
+  migrate to N                    migrate up or down to version N
+  migrate status                  show database version and available migrations
+  migrate force N                 set version N without running migrations, clears dirty state
+  user create -email EMAIL [-password PASSWORD] [-first-name NAME] [-last-name NAME] [-role ROLE]
+  user disable -id ID
+  user reset-password -id ID [-password PASSWORD]
+  user set-role -id ID -role ROLE [-remove]
+  token issue -id ID              issue token pair of user for debugging
+
+Password is generated and printed if it is not given.
+`
+
+// errUsage is returned for invalid command line, usage is printed for it
+var errUsage = errors.New("invalid command line")
+
+// Get config and run command given in arguments with that config
 func main() {
 	cfg, err := config.NewConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	app.Run(cfg)
+	if err := run(cfg, os.Args[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(os.Stderr, usage)
+		}
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		app.Run(cfg)
+		return nil
+	}
+
+	switch args[0] {
+	case "serve":
+		app.Run(cfg)
+		return nil
+	case "migrate":
+		return migrateCommand(cfg, args[1:])
+	case "user":
+		return userCommand(cfg, args[1:])
+	case "token":
+		return tokenCommand(cfg, args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
 }
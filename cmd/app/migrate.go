@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"inditilla/config"
+	"inditilla/internal/app"
+	"inditilla/internal/repository/db"
+	"strconv"
+)
+
+func migrateCommand(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: migrate subcommand is required", errUsage)
+	}
+
+	m, err := app.NewMigrator(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		err = m.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = numberArg(args[1]); err != nil {
+				return err
+			}
+		}
+		err = m.Steps(-steps)
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("%w: migrate to requires version", errUsage)
+		}
+		version, argErr := numberArg(args[1])
+		if argErr != nil {
+			return argErr
+		}
+		err = m.To(uint(version))
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("%w: migrate force requires version", errUsage)
+		}
+		version, argErr := numberArg(args[1])
+		if argErr != nil {
+			return argErr
+		}
+		err = m.Force(version)
+	case "status":
+	default:
+		return fmt.Errorf("%w: unknown migrate subcommand %q", errUsage, args[0])
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return migrateStatus(m)
+}
+
+// migrateStatus prints database version and embedded migrations marked as applied or pending
+func migrateStatus(m *db.Migrator) error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case dirty:
+		fmt.Printf("version: %d (dirty, fix the database and run 'migrate force N')\n", version)
+	case version == 0:
+		fmt.Println("version: none")
+	default:
+		fmt.Printf("version: %d\n", version)
+	}
+
+	for _, mg := range migrations {
+		state := "pending"
+		if mg.Version <= version {
+			state = "applied"
+		}
+		if dirty && mg.Version == version {
+			state = "dirty"
+		}
+		fmt.Printf("  %03d %-32s %s\n", mg.Version, mg.Name, state)
+	}
+
+	return nil
+}
+
+// numberArg parses non-negative number argument
+func numberArg(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %q is not a non-negative number", errUsage, arg)
+	}
+
+	return n, nil
+}
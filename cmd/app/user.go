@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"inditilla/config"
+	"inditilla/internal/app"
+	"inditilla/internal/entity"
+	"inditilla/internal/service"
+	"inditilla/pkg/logger"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// operator is caller of user and token commands. It is granted all permissions, including
+// ones no role grants, and its id matches no user, so even actions admins may not perform
+// on themselves are allowed
+var operator = entity.Caller{
+	Email: "operator",
+	Roles: []string{entity.RoleAdmin},
+	Permissions: []string{
+		entity.PermProfileReadAny,
+		entity.PermProfileUpdateAny,
+		entity.PermUsersRead,
+		entity.PermUsersManage,
+		entity.PermRolesManage,
+		entity.PermTokensIssue,
+	},
+}
+
+// userArgs are arguments of user subcommand
+type userArgs struct {
+	command   string
+	id        int
+	email     string
+	password  string
+	firstName string
+	lastName  string
+	role      string
+	remove    bool
+}
+
+// parseUserArgs parses arguments of user subcommand and checks the ones it requires are set
+func parseUserArgs(args []string) (userArgs, error) {
+	if len(args) == 0 {
+		return userArgs{}, fmt.Errorf("%w: user subcommand is required", errUsage)
+	}
+
+	a := userArgs{command: args[0]}
+
+	flags := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.IntVar(&a.id, "id", 0, "user id")
+	flags.StringVar(&a.email, "email", "", "user email")
+	flags.StringVar(&a.password, "password", "", "user password, generated if empty")
+	flags.StringVar(&a.firstName, "first-name", "User", "user first name")
+	flags.StringVar(&a.lastName, "last-name", "User", "user last name")
+	flags.StringVar(&a.role, "role", "", "role name")
+	flags.BoolVar(&a.remove, "remove", false, "remove role instead of assigning it")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return userArgs{}, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if flags.NArg() > 0 {
+		return userArgs{}, fmt.Errorf("%w: unexpected argument %q", errUsage, flags.Arg(0))
+	}
+
+	requireId := func() error {
+		if a.id <= 0 {
+			return fmt.Errorf("%w: -id is required", errUsage)
+		}
+		return nil
+	}
+
+	switch a.command {
+	case "create":
+		if a.email == "" {
+			return userArgs{}, fmt.Errorf("%w: -email is required", errUsage)
+		}
+	case "disable", "reset-password":
+		if err := requireId(); err != nil {
+			return userArgs{}, err
+		}
+	case "set-role":
+		if err := requireId(); err != nil {
+			return userArgs{}, err
+		}
+		if a.role == "" {
+			return userArgs{}, fmt.Errorf("%w: -role is required", errUsage)
+		}
+	default:
+		return userArgs{}, fmt.Errorf("%w: unknown user subcommand %q", errUsage, a.command)
+	}
+
+	return a, nil
+}
+
+func userCommand(cfg *config.Config, args []string) error {
+	a, err := parseUserArgs(args)
+	if err != nil {
+		return err
+	}
+
+	idStr := strconv.Itoa(a.id)
+
+	return withServices(cfg, func(ctx context.Context, s *service.Services, l *logger.Logger) error {
+		switch a.command {
+		case "create":
+			generated, err := passwordOrGenerated(&a.password)
+			if err != nil {
+				return err
+			}
+
+			form := entity.UserSignupForm{FirstName: a.firstName, LastName: a.lastName, Email: a.email, Password: a.password}
+			newId, err := s.Admin.CreateUser(ctx, &form)
+			if err != nil {
+				return formError(err, form.FieldErrors)
+			}
+			if a.role != "" {
+				if err := s.Admin.AssignRole(ctx, strconv.Itoa(newId), a.role); err != nil {
+					return fmt.Errorf("user %d is created, but role is not assigned: %w", newId, err)
+				}
+			}
+
+			fmt.Printf("user %d is created\n", newId)
+			if generated {
+				fmt.Printf("password: %s\n", a.password)
+			}
+			l.Info("operator created user with id '%d'", newId)
+		case "disable":
+			if err := s.Admin.DisableUser(ctx, operator, idStr); err != nil {
+				return err
+			}
+
+			fmt.Printf("user %d is disabled, sessions are revoked\n", a.id)
+			l.Info("operator disabled user with id '%d'", a.id)
+		case "reset-password":
+			generated, err := passwordOrGenerated(&a.password)
+			if err != nil {
+				return err
+			}
+
+			form := entity.PasswordForm{Password: a.password}
+			if err := s.User.SetPassword(ctx, operator, idStr, &form); err != nil {
+				return formError(err, form.FieldErrors)
+			}
+
+			fmt.Printf("password of user %d is reset, sessions are revoked\n", a.id)
+			if generated {
+				fmt.Printf("password: %s\n", a.password)
+			}
+			l.Info("operator reset password of user with id '%d'", a.id)
+		case "set-role":
+			if a.remove {
+				if err := s.Admin.RemoveRole(ctx, idStr, a.role); err != nil {
+					return err
+				}
+
+				fmt.Printf("role %q is removed from user %d, sessions are revoked\n", a.role, a.id)
+				l.Info("operator removed role '%s' from user with id '%d'", a.role, a.id)
+				return nil
+			}
+
+			if err := s.Admin.AssignRole(ctx, idStr, a.role); err != nil {
+				return err
+			}
+
+			fmt.Printf("role %q is assigned to user %d, it takes effect on next log in\n", a.role, a.id)
+			l.Info("operator assigned role '%s' to user with id '%d'", a.role, a.id)
+		}
+
+		return nil
+	})
+}
+
+// parseTokenArgs parses arguments of token subcommand and returns id of the user
+func parseTokenArgs(args []string) (int, error) {
+	if len(args) == 0 || args[0] != "issue" {
+		return 0, fmt.Errorf("%w: token issue is the only token subcommand", errUsage)
+	}
+
+	flags := flag.NewFlagSet("token issue", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	id := flags.Int("id", 0, "user id")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return 0, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if flags.NArg() > 0 {
+		return 0, fmt.Errorf("%w: unexpected argument %q", errUsage, flags.Arg(0))
+	}
+	if *id <= 0 {
+		return 0, fmt.Errorf("%w: -id is required", errUsage)
+	}
+
+	return *id, nil
+}
+
+func tokenCommand(cfg *config.Config, args []string) error {
+	id, err := parseTokenArgs(args)
+	if err != nil {
+		return err
+	}
+
+	return withServices(cfg, func(ctx context.Context, s *service.Services, l *logger.Logger) error {
+		tokens, err := s.User.IssueTokens(ctx, operator, strconv.Itoa(id))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("access_token: %s\nrefresh_token: %s\n", tokens.AccessToken, tokens.RefreshToken)
+		l.Warn("operator issued tokens of user with id '%d'", id)
+
+		return nil
+	})
+}
+
+// withServices runs 'fn' with services initialized from config, they are closed afterwards
+func withServices(cfg *config.Config, fn func(context.Context, *service.Services, *logger.Logger) error) error {
+	l, closeFile := logger.New(cfg.Log.Level)
+	defer closeFile()
+
+	s, closeServices, err := app.NewServices(cfg, l, nil)
+	if err != nil {
+		return err
+	}
+	defer closeServices()
+
+	return fn(context.Background(), s, l)
+}
+
+// passwordOrGenerated sets random password if given one is empty and reports whether it did
+func passwordOrGenerated(password *string) (bool, error) {
+	if *password != "" {
+		return false, nil
+	}
+
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return false, err
+	}
+	*password = base64.RawURLEncoding.EncodeToString(b)
+
+	return true, nil
+}
+
+// formError adds validation errors of invalid form to the error
+func formError(err error, fieldErrors map[string]string) error {
+	if !errors.Is(err, entity.ErrInvalidInputData) || len(fieldErrors) == 0 {
+		return err
+	}
+
+	fields := make([]string, 0, len(fieldErrors))
+	for field := range fieldErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		err = fmt.Errorf("%w\n  %s: %s", err, field, fieldErrors[field])
+	}
+
+	return err
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"inditilla/config"
+	"strings"
+	"testing"
+)
+
+func TestParseUserArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want userArgs
+	}{
+		{
+			"create with defaults",
+			[]string{"create", "-email", "ann@example.com"},
+			userArgs{command: "create", email: "ann@example.com", firstName: "User", lastName: "User"},
+		},
+		{
+			"create with all flags",
+			[]string{"create", "-email", "ann@example.com", "-password", "Password-1", "-first-name", "Ann", "-last-name", "Lee", "-role", "admin"},
+			userArgs{command: "create", email: "ann@example.com", password: "Password-1", firstName: "Ann", lastName: "Lee", role: "admin"},
+		},
+		{
+			"disable",
+			[]string{"disable", "-id", "7"},
+			userArgs{command: "disable", id: 7, firstName: "User", lastName: "User"},
+		},
+		{
+			"reset password with flag=value syntax",
+			[]string{"reset-password", "-id=7", "--password=Password-1"},
+			userArgs{command: "reset-password", id: 7, password: "Password-1", firstName: "User", lastName: "User"},
+		},
+		{
+			"remove role",
+			[]string{"set-role", "-id", "7", "-role", "admin", "-remove"},
+			userArgs{command: "set-role", id: 7, role: "admin", remove: true, firstName: "User", lastName: "User"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUserArgs(tt.args)
+			if err != nil {
+				t.Fatalf("parseUserArgs: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseUserArgs = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUserArgsUsage(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"no subcommand", nil, "user subcommand is required"},
+		{"unknown subcommand", []string{"delete", "-id", "7"}, `unknown user subcommand "delete"`},
+		{"unknown flag", []string{"create", "-email", "ann@example.com", "-nick", "ann"}, "flag provided but not defined: -nick"},
+		{"flag without value", []string{"create", "-email"}, "flag needs an argument: -email"},
+		{"id is not a number", []string{"disable", "-id", "abc"}, `invalid value "abc" for flag -id`},
+		{"positional argument", []string{"disable", "7"}, `unexpected argument "7"`},
+		{"create without email", []string{"create", "-password", "Password-1"}, "-email is required"},
+		{"disable without id", []string{"disable"}, "-id is required"},
+		{"disable with zero id", []string{"disable", "-id", "0"}, "-id is required"},
+		{"reset password without id", []string{"reset-password", "-password", "Password-1"}, "-id is required"},
+		{"set role without id", []string{"set-role", "-role", "admin"}, "-id is required"},
+		{"set role without role", []string{"set-role", "-id", "7"}, "-role is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseUserArgs(tt.args)
+			assertUsageError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParseTokenArgs(t *testing.T) {
+	id, err := parseTokenArgs([]string{"issue", "-id", "7"})
+	if err != nil {
+		t.Fatalf("parseTokenArgs: %v", err)
+	}
+	if id != 7 {
+		t.Errorf("id = %d; want 7", id)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"no subcommand", nil, "token issue is the only token subcommand"},
+		{"unknown subcommand", []string{"revoke", "-id", "7"}, "token issue is the only token subcommand"},
+		{"without id", []string{"issue"}, "-id is required"},
+		{"negative id", []string{"issue", "-id", "-1"}, "-id is required"},
+		{"id is not a number", []string{"issue", "-id", "abc"}, `invalid value "abc" for flag -id`},
+		{"positional argument", []string{"issue", "-id", "7", "8"}, `unexpected argument "8"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTokenArgs(tt.args)
+			assertUsageError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	// Usage errors are returned before config is used, so empty config is enough
+	cfg := &config.Config{}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"unknown command", []string{"users"}, `unknown command "users"`},
+		{"user without subcommand", []string{"user"}, "user subcommand is required"},
+		{"user create without email", []string{"user", "create"}, "-email is required"},
+		{"token without subcommand", []string{"token"}, "token issue is the only token subcommand"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertUsageError(t, run(cfg, tt.args), tt.wantErr)
+		})
+	}
+}
+
+func assertUsageError(t *testing.T, err error, want string) {
+	t.Helper()
+
+	if !errors.Is(err, errUsage) {
+		t.Fatalf("error = %v; want usage error", err)
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %q; want it to contain %q", err, want)
+	}
+}
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/joho/godotenv"
 )
 
 type (
@@ -11,23 +14,91 @@ type (
 		App  `yaml:"app"`
 		Http `yaml:"http"`
 		Auth
-		Log      `yaml:"log"`
-		Database `yaml:"database"`
+		Admin     `yaml:"admin"`
+		Mail      `yaml:"mail"`
+		RateLimit `yaml:"rateLimit"`
+		Metrics   `yaml:"metrics"`
+		Tracing   `yaml:"tracing"`
+		Log       `yaml:"log"`
+		Database  `yaml:"database"`
 	}
 
 	App struct {
 		Name    string `env-required:"true" yaml:"name" env:"APP_NAME"`
 		Version string `env-requited:"true" yaml:"version" env:"APP_VERSION"`
+		BaseURL string `yaml:"baseUrl" env:"APP_BASE_URL" env-default:"http://localhost:7000"` // Used for links in emails
 	}
 
 	Http struct {
 		Port      string `env-required:"true" yaml:"port" env:"HTTP_PORT"`
 		StaticDir string `env-requited:"true" yaml:"staticDir" env:"HTTP_STATIC_DIR"`
+		StaticURL string `yaml:"staticUrl" env:"HTTP_STATIC_URL" env-default:"/static/"` // URL prefix files of static dir are served at
+
+		RequireIfMatch bool `yaml:"requireIfMatch" env:"HTTP_REQUIRE_IF_MATCH" env-default:"false"` // Reject profile updates without If-Match header
+		ShutdownDelay  int  `yaml:"shutdownDelay" env:"HTTP_SHUTDOWN_DELAY" env-default:"0"`        // Seconds /readyz fails before server stops accepting requests
 	}
 
 	Auth struct {
-		Deadline   string `env-required:"true" env:"AUTH_DEADLINE" env-default:"43200"` // Default value - 12 hours in seconds
-		SigningKey string `env-required:"true" env:"SIGNING_KEY"`
+		Deadline        string `env-required:"true" env:"AUTH_DEADLINE" env-default:"43200"`           // Default value - 12 hours in seconds
+		RefreshDeadline string `env-required:"true" env:"AUTH_REFRESH_DEADLINE" env-default:"2592000"` // Default value - 30 days in seconds
+		SigningMethod   string `env:"AUTH_SIGNING_METHOD" env-default:"HS256"`                         // One of HS256, RS256, EdDSA
+		SigningKey      string `env:"SIGNING_KEY"`                                                     // Shared secret, required for HS256 only
+		KeysDir         string `env:"AUTH_KEYS_DIR"`                                                   // Directory with '<kid>.pem' keys, required for RS256 and EdDSA
+		SigningKeyId    string `env:"AUTH_SIGNING_KEY_ID"`                                             // Id of the key in AUTH_KEYS_DIR new tokens are signed with
+
+		RequireVerifiedEmail bool   `env:"AUTH_REQUIRE_VERIFIED_EMAIL" env-default:"false"` // Deny log in until email is verified
+		MfaIssuer            string `env:"AUTH_MFA_ISSUER" env-default:"inditilla"`         // Name accounts are shown with in authenticator apps
+
+		LoginMaxFailures  int `env:"AUTH_LOGIN_MAX_FAILURES" env-default:"10"` // Account is locked after this many failed log in attempts in a row
+		LoginLockout      int `env:"AUTH_LOGIN_LOCKOUT" env-default:"900"`     // Account lockout duration in seconds, older failures are forgotten
+		LoginBackoffAfter int `env:"AUTH_LOGIN_BACKOFF_AFTER" env-default:"3"` // Failures allowed before delay between attempts is required
+		LoginBackoffBase  int `env:"AUTH_LOGIN_BACKOFF_BASE" env-default:"1"`  // First delay in seconds, doubled on every next failure
+		LoginBackoffMax   int `env:"AUTH_LOGIN_BACKOFF_MAX" env-default:"300"` // Maximum delay in seconds
+	}
+
+	// Admin is initial admin user created (or granted admin role) on start up if email is set
+	Admin struct {
+		Email    string `yaml:"email" env:"ADMIN_EMAIL"`
+		Password string `env:"ADMIN_PASSWORD"`
+	}
+
+	Mail struct {
+		Driver   string `yaml:"driver" env:"MAIL_DRIVER" env-default:"stdout"` // One of smtp, file, stdout
+		From     string `yaml:"from" env:"MAIL_FROM" env-default:"no-reply@inditilla.local"`
+		Host     string `yaml:"host" env:"MAIL_HOST"`
+		Port     string `yaml:"port" env:"MAIL_PORT" env-default:"587"`
+		Username string `env:"MAIL_USERNAME"`
+		Password string `env:"MAIL_PASSWORD"`
+		File     string `yaml:"file" env:"MAIL_FILE" env-default:"mails.txt"` // Used by file driver
+	}
+
+	// RateLimit limits are token buckets kept per client IP, or per user on authenticated
+	// routes. Route limits are keyed by route's 'METHOD /path' and apply along with global one
+	RateLimit struct {
+		Enabled bool                     `yaml:"enabled" env:"RATE_LIMIT_ENABLED" env-default:"true"`
+		Store   string                   `yaml:"store" env:"RATE_LIMIT_STORE" env-default:"memory"` // Only memory for now
+		Global  RateLimitRule            `yaml:"global"`
+		Routes  map[string]RateLimitRule `yaml:"routes"`
+	}
+
+	// RateLimitRule allows 'requests' per 'period' seconds
+	RateLimitRule struct {
+		Requests int `yaml:"requests"`
+		Period   int `yaml:"period"`
+	}
+
+	// Metrics are served in Prometheus format at /metrics of admin port, separate from API
+	Metrics struct {
+		Enabled bool   `yaml:"enabled" env:"METRICS_ENABLED" env-default:"true"`
+		Port    string `yaml:"port" env:"METRICS_PORT" env-default:"7001"`
+	}
+
+	// Tracing exports OpenTelemetry spans of requests, service methods, queries and password hashing
+	Tracing struct {
+		Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`           // One of otlp, stdout, none
+		Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT" env-default:"localhost:4318"` // OTLP/HTTP collector host:port
+		Insecure    bool    `yaml:"insecure" env:"TRACING_INSECURE" env-default:"false"`          // Send spans to collector over plain HTTP
+		SampleRatio float64 `yaml:"sampleRatio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`       // Ratio of traces started here that are recorded
 	}
 
 	Log struct {
@@ -35,12 +106,22 @@ type (
 	}
 
 	Database struct {
+		Driver   string `yaml:"driver" env:"DB_DRIVER" env-default:"postgres"` // One of postgres, memory (data is lost on restart)
 		Port     string `yaml:"db_port" env:"DB_PORT" env-default:"7777"`
 		Host     string `yaml:"db_host" env:"DB_HOST" env-default:"localhost"`
 		Name     string `yaml:"db_name" env:"DB_NAME" env-default:"postgres"`
 		User     string `yaml:"db_user" env:"DB_USER" env-default:"postgres"`
 		Password string `yaml:"db_password" env:"DB_PASSWORD"`
-		URL      string `env-required:"true" env:"DB_URL"`
+		URL      string `env:"DB_URL"` // Required for postgres driver
+		SSLMode  string `env:"DB_SSL_MODE" env-default:"disable"`
+		Migrate  bool   `yaml:"migrate" env:"DB_MIGRATE" env-default:"true"` // Apply pending migrations on start up
+
+		// Connection pool settings, durations are in seconds
+		MaxConns        int32 `yaml:"maxConns" env:"DB_MAX_CONNS" env-default:"10"`
+		MinConns        int32 `yaml:"minConns" env:"DB_MIN_CONNS" env-default:"0"`
+		MaxConnLifetime int   `yaml:"maxConnLifetime" env:"DB_MAX_CONN_LIFETIME" env-default:"3600"`
+		MaxConnIdleTime int   `yaml:"maxConnIdleTime" env:"DB_MAX_CONN_IDLE_TIME" env-default:"1800"`
+		ConnectTimeout  int   `yaml:"connectTimeout" env:"DB_CONNECT_TIMEOUT" env-default:"5"`
 	}
 )
 
@@ -49,6 +130,11 @@ type (
 func NewConfig() (*Config, error) {
 	cfg := &Config{}
 
+	// Variables of '.env' file are loaded if it exists, variables set in environment are kept
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("environment file error: %v", err)
+	}
+
 	if err := cleanenv.ReadConfig("./config/config.yml", cfg); err != nil {
 		return nil, fmt.Errorf("config file error: %v", err)
 	}
@@ -3,13 +3,22 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
 	"inditilla/config"
 	"inditilla/internal/data"
 	"inditilla/internal/handlers"
 	"inditilla/internal/repository"
+	"inditilla/internal/repository/db"
+	userRepo "inditilla/internal/repository/user"
 	"inditilla/internal/service"
 	"inditilla/internal/service/user"
+	"inditilla/pkg/blob"
+	"inditilla/pkg/jwks"
 	"inditilla/pkg/logger"
+	"inditilla/pkg/mailer"
+	"inditilla/pkg/metrics"
+	"inditilla/pkg/ratelimit"
+	"inditilla/pkg/tracing"
 	"log"
 	"net/http"
 	"os"
@@ -18,67 +27,155 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 )
 
+// loginFailuresPruneInterval is how often forgotten failed log in attempts are deleted
+const loginFailuresPruneInterval = 10 * time.Minute
+
 func Run(cfg *config.Config) {
 	// Initialize new logger
 	l, closeFile := logger.New(cfg.Log.Level)
 	defer closeFile()
 
-	// Open database connection
-	db, err := openDB(cfg.Database.URL)
+	// Apply pending migrations if configured, otherwise they are applied by 'migrate up' command
+	if cfg.Database.Driver == "postgres" && cfg.Database.Migrate {
+		if err := migrateUp(cfg.Database, l); err != nil {
+			l.Fatal(err.Error())
+		}
+	}
+
+	// Initialize tracer provider with exporter set in config
+	shutdownTracing, err := tracing.New(context.Background(), tracing.Options{
+		Exporter:       cfg.Tracing.Exporter,
+		Endpoint:       cfg.Tracing.Endpoint,
+		Insecure:       cfg.Tracing.Insecure,
+		SampleRatio:    cfg.Tracing.SampleRatio,
+		ServiceName:    cfg.App.Name,
+		ServiceVersion: cfg.App.Version,
+	})
 	if err != nil {
 		l.Fatal(err.Error())
 	}
 
-	// Initialize repository
-	r := repository.New(db)
+	// Initialize metrics if enabled, they are served on admin port
+	var m *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		m = metrics.New()
+	}
 
-	// Initialize authorizer with deadline and signing key from config
-	deadline, err := strconv.Atoi(cfg.Auth.Deadline)
+	// Initialize services with repositories for database driver set in config
+	s, closeServices, err := NewServices(cfg, l, m)
 	if err != nil {
 		l.Fatal(err.Error())
 	}
-	auth := user.NewAuthorizer([]byte(cfg.Auth.SigningKey), time.Duration(deadline)*time.Second)
 
-	// Initialize token model
-	tokenModel := &data.TokenModel{Log: l}
+	// Create initial admin if configured
+	if cfg.Admin.Email != "" {
+		if err := s.Admin.SeedAdmin(context.Background(), cfg.Admin.Email, cfg.Admin.Password); err != nil {
+			l.Fatal("seed admin: %v", err)
+		}
+	}
 
-	// Initialize service
-	s := service.New(r, auth, tokenModel)
+	// Prune forgotten failed log in attempts in background until shutdown
+	stopJobs, jobsDone := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+
+		runPeriodically(stopJobs, loginFailuresPruneInterval, func() {
+			if err := s.User.PruneLoginFailures(context.Background()); err != nil {
+				l.Error("prune login failures: %v", err)
+			}
+		})
+	}()
+
+	// Initialize rate limiter
+	limiter, err := newLimiter(cfg.RateLimit)
+	if err != nil {
+		l.Fatal(err.Error())
+	}
 
 	// Create new Error logger for http server
 	logAdapter := zerolog.New(zerolog.NewConsoleWriter()).With().Timestamp().Caller().Logger().Level(zerolog.ErrorLevel)
 	errLogger := log.New(logAdapter, "", 0)
 
+	// Initialize router serving API along with files of static dir
+	router := handlers.NewRouter(l, s, handlers.Options{
+		Limiter:        limiter,
+		Metrics:        m,
+		RequireIfMatch: cfg.Http.RequireIfMatch,
+		Static:         os.DirFS(cfg.Http.StaticDir),
+		StaticURL:      cfg.Http.StaticURL,
+	})
+
 	// Initialize custom http server
 	server := &http.Server{
 		Addr:         "127.0.0.1:" + cfg.Http.Port,
-		Handler:      handlers.NewRouter(l, s),
+		Handler:      router,
 		ErrorLog:     errLogger,
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 45 * time.Second,
 	}
 
-	// Background goroutine for graceful shutdown
+	// Initialize admin server serving metrics apart from API, so they are not exposed to clients
+	var adminServer *http.Server
+	if m != nil {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("/metrics", m.Handler())
+
+		adminServer = &http.Server{
+			Addr:         "127.0.0.1:" + cfg.Metrics.Port,
+			Handler:      adminMux,
+			ErrorLog:     errLogger,
+			IdleTimeout:  time.Minute,
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 45 * time.Second,
+		}
+
+		go func() {
+			l.Info("starting the admin server: addr - %s", adminServer.Addr)
+			if err := adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				l.Fatal("admin listen and serve: %v", err)
+			}
+		}()
+	}
+
+	// Background goroutine for graceful shutdown, server stops serving as soon as shutdown
+	// starts, so Run waits for it to complete
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
+
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 
 		sig := <-sigCh
 		l.Info("signal received: %s", sig.String())
 
+		// Readiness probe fails first, so load balancer stops routing requests here before
+		// server stops accepting them
+		s.Health.SetShuttingDown()
+		time.Sleep(time.Duration(cfg.Http.ShutdownDelay) * time.Second)
+
 		if err := server.Shutdown(context.Background()); err != nil {
 			l.Fatal("server shutdown: %v", err)
 		}
-		if err := db.Close(context.Background()); err != nil {
-			l.Fatal("db connection close: %v", err)
+		if adminServer != nil {
+			if err := adminServer.Shutdown(context.Background()); err != nil {
+				l.Fatal("admin server shutdown: %v", err)
+			}
 		}
+		// Running job completes before database is closed
+		close(stopJobs)
+		<-jobsDone
+		closeServices()
 
-		os.Exit(0)
+		// Spans of the last requests are flushed to exporter
+		if err := shutdownTracing(context.Background()); err != nil {
+			l.Error("tracing shutdown: %v", err)
+		}
 	}()
 
 	// Start server here
@@ -86,19 +183,220 @@ func Run(cfg *config.Config) {
 	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		l.Fatal("listen and serve: %v", err)
 	}
+
+	<-shutdownDone
+}
+
+// runPeriodically calls 'fn' every 'interval' until 'stop' is closed
+func runPeriodically(stop <-chan struct{}, interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			fn()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// NewServices initializes services with dependencies set in config and returns function closing
+// database and mailer. It is shared by the server and CLI commands. Database queries are
+// recorded in given metrics, nothing is recorded if it is nil
+func NewServices(cfg *config.Config, l *logger.Logger, m *metrics.Metrics) (*service.Services, func(), error) {
+	// Initialize authorizer with deadlines and signing key from config
+	deadline, err := strconv.Atoi(cfg.Auth.Deadline)
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshDeadline, err := strconv.Atoi(cfg.Auth.RefreshDeadline)
+	if err != nil {
+		return nil, nil, err
+	}
+	auth, err := newAuthorizer(cfg.Auth, time.Duration(deadline)*time.Second, time.Duration(refreshDeadline)*time.Second)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Initialize token model
+	tokenModel := &data.TokenModel{Log: l}
+
+	// Initialize blob store keeping uploaded files in static dir
+	blobs, err := newBlobStore(cfg.Http)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Initialize mailer and notifier sending emails to users
+	mail, closeMailer, err := newMailer(cfg.Mail)
+	if err != nil {
+		return nil, nil, err
+	}
+	notifier := user.NewNotifier(mail, cfg.App.BaseURL)
+
+	// Initialize repository for database driver set in config
+	r, closeDB, err := newRepositories(cfg.Database, m)
+	if err != nil {
+		closeMailer()
+		return nil, nil, err
+	}
+	if cfg.Database.Driver == "memory" {
+		l.Warn("memory database driver is used, data will be lost on restart")
+	}
+
+	// Initialize service
+	policy := user.Policy{
+		RequireVerifiedEmail: cfg.Auth.RequireVerifiedEmail,
+		MfaIssuer:            cfg.Auth.MfaIssuer,
+		Lockout: user.LockoutPolicy{
+			MaxFailures:  cfg.Auth.LoginMaxFailures,
+			Duration:     time.Duration(cfg.Auth.LoginLockout) * time.Second,
+			BackoffAfter: cfg.Auth.LoginBackoffAfter,
+			BackoffBase:  time.Duration(cfg.Auth.LoginBackoffBase) * time.Second,
+			BackoffMax:   time.Duration(cfg.Auth.LoginBackoffMax) * time.Second,
+		},
+	}
+
+	closeAll := func() {
+		closeDB()
+		closeMailer()
+	}
+
+	return service.New(l, r, auth, tokenModel, notifier, blobs, policy), closeAll, nil
 }
 
-// openDB creates new connection to the database with given database url
-// then connection is tested with ping method
-func openDB(url string) (*pgx.Conn, error) {
-	conn, err := pgx.Connect(context.Background(), url)
+// newRepositories creates repositories for database driver set in config and returns function
+// closing database
+func newRepositories(cfg config.Database, m *metrics.Metrics) (*repository.Repositories, func(), error) {
+	switch cfg.Driver {
+	case "postgres":
+		if cfg.URL == "" {
+			return nil, nil, errors.New("database: DB_URL is required for postgres driver")
+		}
+		pool, err := openDB(cfg, m)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repository.New(pool), pool.Close, nil
+	case "memory":
+		return repository.NewMemory(userRepo.PasswordCost), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("database: unsupported driver %q", cfg.Driver)
+	}
+}
+
+// openDB creates new connection pool to the database with given settings, queries are
+// traced and recorded in metrics if they are not nil. Then connection is tested with ping method
+func openDB(cfg config.Database, m *metrics.Metrics) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	poolConfig.MaxConns = cfg.MaxConns
+	poolConfig.MinConns = cfg.MinConns
+	poolConfig.MaxConnLifetime = time.Duration(cfg.MaxConnLifetime) * time.Second
+	poolConfig.MaxConnIdleTime = time.Duration(cfg.MaxConnIdleTime) * time.Second
+	poolConfig.ConnConfig.ConnectTimeout = time.Duration(cfg.ConnectTimeout) * time.Second
+	poolConfig.ConnConfig.Tracer = db.NewQueryTracer(m)
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := conn.Ping(context.Background()); err != nil {
+	if err := pool.Ping(context.Background()); err != nil {
+		pool.Close()
 		return nil, err
 	}
 
-	return conn, err
+	return pool, nil
+}
+
+// newAuthorizer creates authorizer for signing method set in config. Shared secret
+// is used for HS256, key pairs are loaded from keys directory for RS256 and EdDSA
+func newAuthorizer(cfg config.Auth, deadline, refreshDeadline time.Duration) (*user.Authorizer, error) {
+	switch cfg.SigningMethod {
+	case "HS256":
+		if cfg.SigningKey == "" {
+			return nil, errors.New("auth: SIGNING_KEY is required for HS256 signing method")
+		}
+		return user.NewAuthorizer([]byte(cfg.SigningKey), deadline, refreshDeadline), nil
+	case "RS256", "EdDSA":
+		keys, err := jwks.LoadDir(cfg.KeysDir)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %v", err)
+		}
+		return user.NewKeyAuthorizer(cfg.SigningMethod, keys, cfg.SigningKeyId, deadline, refreshDeadline)
+	default:
+		return nil, fmt.Errorf("auth: unsupported signing method %q", cfg.SigningMethod)
+	}
+}
+
+// newMailer creates mailer for driver set in config and returns function closing it
+func newMailer(cfg config.Mail) (mailer.Mailer, func() error, error) {
+	noop := func() error { return nil }
+
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.Host == "" {
+			return nil, nil, errors.New("mail: MAIL_HOST is required for smtp driver")
+		}
+		return mailer.NewSMTP(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From), noop, nil
+	case "file":
+		m, closeFile, err := mailer.NewFile(cfg.File, cfg.From)
+		if err != nil {
+			return nil, nil, fmt.Errorf("mail: %v", err)
+		}
+		return m, closeFile, nil
+	case "stdout":
+		return mailer.NewWriter(os.Stdout, cfg.From), noop, nil
+	default:
+		return nil, nil, fmt.Errorf("mail: unsupported driver %q", cfg.Driver)
+	}
+}
+
+// newBlobStore returns store keeping blobs in static dir, so they are served by static URL
+func newBlobStore(cfg config.Http) (blob.BlobStore, error) {
+	if cfg.StaticDir == "" {
+		return nil, errors.New("http: HTTP_STATIC_DIR is required")
+	}
+
+	store, err := blob.NewLocal(cfg.StaticDir, cfg.StaticURL)
+	if err != nil {
+		return nil, fmt.Errorf("blob: %v", err)
+	}
+
+	return store, nil
+}
+
+// newLimiter returns rate limiter with limits from config, or nil if rate limiting is disabled
+func newLimiter(cfg config.RateLimit) (*ratelimit.Limiter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var store ratelimit.Store
+	switch cfg.Store {
+	case "memory":
+		store = ratelimit.NewMemoryStore()
+	default:
+		return nil, fmt.Errorf("rate limit: unsupported store %q", cfg.Store)
+	}
+
+	routes := make(map[string]ratelimit.Limit, len(cfg.Routes))
+	for route, rule := range cfg.Routes {
+		routes[route] = rateLimit(rule)
+	}
+
+	return ratelimit.New(store, rateLimit(cfg.Global), routes), nil
+}
+
+func rateLimit(rule config.RateLimitRule) ratelimit.Limit {
+	return ratelimit.Limit{
+		Requests: rule.Requests,
+		Period:   time.Duration(rule.Period) * time.Second,
+	}
 }
@@ -3,14 +3,10 @@ package app
 import (
 	"errors"
 	"fmt"
-	"os"
+	"inditilla/config"
+	"inditilla/internal/repository/db"
+	"inditilla/pkg/logger"
 	"time"
-
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	"github.com/joho/godotenv"
-	"github.com/rs/zerolog"
 )
 
 const (
@@ -18,58 +14,66 @@ const (
 	_defaultTimeout  = time.Second
 )
 
-// Initialize database migration up before start of the server
-func init() {
-	logger := zerolog.New(zerolog.NewConsoleWriter()).With().Timestamp().Caller().Logger()
-
-	if err := godotenv.Load(); err != nil {
-		logger.Fatal().Err(err).Str("state", "error loading '.env' file").Msg("migrate")
-	}
-
-	dbURL, ok := os.LookupEnv("DB_URL") // Url in .env is empty for now
-	if !ok || len(dbURL) == 0 {
-		logger.Fatal().Str("state", "environment variable not declared: DB_URL").Msg("migrate")
-	}
-
-	sslMode, ok := os.LookupEnv("DB_SSL_MODE")
-	if !ok || len(sslMode) == 0 {
-		sslMode = "disable"
+// migrateUp applies pending migrations to postgres database set in config. Connecting
+// is retried while database is starting up, invalid config is not
+func migrateUp(cfg config.Database, l logger.ILogger) error {
+	url, err := migrateURL(cfg)
+	if err != nil {
+		return err
 	}
 
-	dbURL += "?sslmode=" + sslMode
-
 	var (
 		attempts = _defaultAttempts
-		err      error
-		m        *migrate.Migrate
+		m        *db.Migrator
 	)
 
 	for attempts > 0 {
-		m, err = migrate.New("file://migrations", dbURL)
+		m, err = db.NewMigrator(url)
 		if err == nil {
 			break
 		}
 
-		logger.Warn().Str("state", fmt.Sprintf("postgres is trying to connect, attempts left: %d", attempts)).Msg("migrate")
+		l.Warn("migrate: postgres is trying to connect, attempts left: %d", attempts)
 		time.Sleep(_defaultTimeout)
 		attempts--
 	}
 
 	if err != nil {
-		logger.Fatal().Err(err).Str("state", "postgres connection").Msg("migrate")
+		return fmt.Errorf("migrate: %w", err)
 	}
-
-	err = m.Up()
 	defer m.Close()
 
-	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		logger.Fatal().Err(err).Str("state", "up").Msg("migrate")
+	if err := m.Up(); err != nil {
+		return fmt.Errorf("migrate up: %w", err)
+	}
+
+	version, _, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	l.Info("migrate: database is at version %d", version)
+
+	return nil
+}
+
+// NewMigrator returns migrator of postgres database set in config. It should be closed after use
+func NewMigrator(cfg config.Database) (*db.Migrator, error) {
+	url, err := migrateURL(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	if errors.Is(err, migrate.ErrNoChange) {
-		logger.Warn().Str("state", "no change").Msg("migrate")
-		return
+	return db.NewMigrator(url)
+}
+
+// migrateURL returns URL of database set in config migrations are applied to
+func migrateURL(cfg config.Database) (string, error) {
+	if cfg.Driver != "postgres" {
+		return "", fmt.Errorf("migrate: migrations apply to postgres driver only, not %q", cfg.Driver)
+	}
+	if cfg.URL == "" {
+		return "", errors.New("migrate: DB_URL is required for postgres driver")
 	}
 
-	logger.Info().Str("migrate", "success")
+	return db.WithSSLMode(cfg.URL, cfg.SSLMode), nil
 }
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"bytes"
+	"inditilla/config"
+	"inditilla/pkg/logger"
+	"strings"
+	"testing"
+)
+
+func TestMigrateUpConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Database
+		wantErr string
+	}{
+		{"other driver", config.Database{Driver: "memory"}, "migrations apply to postgres driver only"},
+		{"no URL", config.Database{Driver: "postgres"}, "DB_URL is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			err := migrateUp(tt.cfg, logger.NewWriter(&out))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("migrateUp error = %v; want %q", err, tt.wantErr)
+			}
+
+			// Invalid config fails right away, without connection attempts
+			if strings.Contains(out.String(), "attempts left") {
+				t.Errorf("connecting is retried with invalid config, log: %s", out.String())
+			}
+		})
+	}
+}
@@ -1,30 +1,67 @@
 package data
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"inditilla/pkg/logger"
 	"time"
 
 	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/google/uuid"
 )
 
+const opaqueTokenBytes = 32
+
 type TokenModel struct {
 	Log *logger.Logger
 }
 
+// Claims are custom jwt claims. Standard 'jti' claim uniquely identifies token
+// so it could be revoked, 'sid' is the refresh token family (log in session)
+// the token was issued for
 type Claims struct {
 	jwt.StandardClaims
-	Email string `json:"email"`
+	UserId    int      `json:"uid"`
+	Email     string   `json:"email"`
+	Roles     []string `json:"roles,omitempty"`
+	SessionId string   `json:"sid"`
 }
 
-// New returns new jwt token with custom claims consisting of expiration date and user email
-func (t *TokenModel) New(email string, deadline time.Duration) *jwt.Token {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+// New returns new jwt token for given signing method with custom claims consisting of
+// token id, expiration date, user id, user email, user roles and session id
+func (t *TokenModel) New(method jwt.SigningMethod, userId int, email string, roles []string, sessionId string, deadline time.Duration) *jwt.Token {
+	token := jwt.NewWithClaims(method, &Claims{
 		StandardClaims: jwt.StandardClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.At(time.Now().Add(deadline)),
 			IssuedAt:  jwt.At(time.Now()),
 		},
-		Email: email,
+		UserId:    userId,
+		Email:     email,
+		Roles:     roles,
+		SessionId: sessionId,
 	})
 
 	return token
 }
+
+// NewOpaque returns new random opaque token (e.g. refresh or password reset token) and
+// its hash. Only the hash should be stored, plain token is given to the user once
+func (t *TokenModel) NewOpaque() (string, string, error) {
+	b := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	plain := base64.RawURLEncoding.EncodeToString(b)
+
+	return plain, HashToken(plain), nil
+}
+
+// HashToken returns hex encoded sha256 hash of given opaque token
+func HashToken(plain string) string {
+	hash := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,100 @@
+package e2e
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inditilla/internal/entity"
+	"net/http"
+	"testing"
+)
+
+func TestAdminUsers(t *testing.T) {
+	s := New(t)
+	admin := s.NewAdminSession("admin@example.com")
+	ann := s.NewSession("ann@example.com")
+
+	s.Do(http.MethodGet, "/v1/admin/users", ann.AccessToken, nil).Golden("admin_users_forbidden")
+	s.Do(http.MethodGet, "/v1/admin/users?sort=email", admin.AccessToken, nil).Golden("admin_users_ok")
+	s.Do(http.MethodGet, "/v1/admin/users?limit=abc", admin.AccessToken, nil).Golden("admin_users_invalid_query")
+
+	disable := fmt.Sprintf("/v1/admin/users/%d/disable", ann.Id)
+
+	s.Do(http.MethodPost, disable, admin.AccessToken, nil).Golden("admin_disable_ok")
+	s.Do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"email":    ann.Email,
+		"password": Password,
+	}).Golden("login_disabled_user")
+}
+
+func TestAdminRoles(t *testing.T) {
+	s := New(t)
+	admin := s.NewAdminSession("admin@example.com")
+
+	s.Do(http.MethodGet, "/v1/admin/roles", admin.AccessToken, nil).Golden("admin_roles_ok")
+	s.Do(http.MethodPut, fmt.Sprintf("/v1/admin/users/%d/roles/unknown", admin.Id), admin.AccessToken, nil).Golden("admin_assign_unknown_role")
+}
+
+func TestAdminCreatedUsersVerified(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Policy.RequireVerifiedEmail = true
+	s := NewWithConfig(t, cfg)
+	ctx := context.Background()
+
+	if err := s.Services.Admin.SeedAdmin(ctx, "root@example.com", Password); err != nil {
+		t.Fatalf("SeedAdmin: %v", err)
+	}
+
+	form := entity.UserSignupForm{FirstName: "Ann", LastName: "Lee", Email: "ann@example.com", Password: Password}
+	if _, err := s.Services.Admin.CreateUser(ctx, &form); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	// Users created by operator may log in right away, with no verification email sent
+	s.LogIn("root@example.com", Password)
+	s.LogIn("ann@example.com", Password)
+	if len(s.Mailbox.messages) != 0 {
+		t.Errorf("%d emails are sent; want none", len(s.Mailbox.messages))
+	}
+
+	invalid := entity.UserSignupForm{FirstName: "Bob", LastName: "Lee", Email: "not-an-email", Password: "short"}
+	if _, err := s.Services.Admin.CreateUser(ctx, &invalid); !errors.Is(err, entity.ErrInvalidInputData) {
+		t.Errorf("CreateUser with invalid data error = %v; want %v", err, entity.ErrInvalidInputData)
+	}
+	if _, err := s.Services.Admin.CreateUser(ctx, &form); !errors.Is(err, entity.ErrDuplicateEmail) {
+		t.Errorf("CreateUser with taken email error = %v; want %v", err, entity.ErrDuplicateEmail)
+	}
+}
+
+func TestSeedAdminValidation(t *testing.T) {
+	s := New(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{"invalid email", "root", Password},
+		{"no password", "root@example.com", ""},
+		{"short password", "root@example.com", "short"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Services.Admin.SeedAdmin(ctx, tt.email, tt.password); !errors.Is(err, entity.ErrInvalidInputData) {
+				t.Errorf("SeedAdmin error = %v; want %v", err, entity.ErrInvalidInputData)
+			}
+		})
+	}
+
+	if exists, _ := s.Services.User.Exists(ctx, "root@example.com"); exists {
+		t.Error("admin is created with invalid data")
+	}
+
+	// Existing user is granted admin role whatever the password is
+	s.SignUp("ann@example.com")
+	if err := s.Services.Admin.SeedAdmin(ctx, "ann@example.com", ""); err != nil {
+		t.Errorf("SeedAdmin of existing user: %v", err)
+	}
+}
@@ -0,0 +1,463 @@
+// Package e2e runs application's router with real services and memory repositories
+// over http test server. Tests call endpoints as clients do and compare responses
+// with golden files in testdata directory, run them with -update flag to rewrite files
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"inditilla/internal/data"
+	"inditilla/internal/entity"
+	"inditilla/internal/handlers"
+	"inditilla/internal/repository"
+	"inditilla/internal/service"
+	"inditilla/internal/service/user"
+	"inditilla/pkg/blob"
+	"inditilla/pkg/logger"
+	"inditilla/pkg/mailer"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with actual responses")
+
+// Password is password users are signed up with by harness helpers
+const Password = "Password-1"
+
+// SigningKey is shared secret access tokens are signed with unless Config.Authorizer is set
+const SigningKey = "e2e-signing-key"
+
+// Config is configuration of the application started by harness
+type Config struct {
+	Policy user.Policy
+	Router handlers.Options
+
+	// Authorizer signs access tokens, tokens are signed with shared secret if it is nil
+	Authorizer *user.Authorizer
+}
+
+// DefaultConfig returns configuration with default policy of the application
+func DefaultConfig() Config {
+	return Config{
+		Policy: user.Policy{
+			MfaIssuer: "inditilla",
+			Lockout: user.LockoutPolicy{
+				MaxFailures:  10,
+				Duration:     15 * time.Minute,
+				BackoffAfter: 3,
+				BackoffBase:  time.Second,
+				BackoffMax:   5 * time.Minute,
+			},
+		},
+	}
+}
+
+// Server is the application running over http test server
+type Server struct {
+	*httptest.Server
+
+	Services  *service.Services
+	Repos     *repository.Repositories // Repositories of services, for test data that could not be made through API
+	Mailbox   *Mailbox
+	StaticDir string // Uploaded files are stored here, in temporary directory
+
+	t *testing.T
+}
+
+// Session is signed up and logged in user
+type Session struct {
+	Id           int
+	Email        string
+	AccessToken  string
+	RefreshToken string
+}
+
+// New starts application with default configuration. Server is closed on test cleanup
+func New(t *testing.T) *Server {
+	return NewWithConfig(t, DefaultConfig())
+}
+
+// NewWithConfig starts application with given configuration. Server is closed on test cleanup
+func NewWithConfig(t *testing.T, cfg Config) *Server {
+	t.Helper()
+
+	l := logger.NewWriter(io.Discard)
+	mailbox := &Mailbox{}
+
+	auth := cfg.Authorizer
+	if auth == nil {
+		auth = user.NewAuthorizer([]byte(SigningKey), 12*time.Hour, 30*24*time.Hour)
+	}
+	notifier := user.NewNotifier(mailbox, "http://inditilla.test")
+
+	staticDir := t.TempDir()
+	blobs, err := blob.NewLocal(staticDir, "/static/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos := repository.NewMemory(bcrypt.MinCost)
+	services := service.New(l, repos, auth, &data.TokenModel{Log: l}, notifier, blobs, cfg.Policy)
+
+	// Uploaded files are served as application serves them
+	opts := cfg.Router
+	opts.Static = os.DirFS(staticDir)
+	opts.StaticURL = "/static/"
+
+	s := &Server{
+		Server:    httptest.NewServer(handlers.NewRouter(l, services, opts)),
+		Services:  services,
+		Repos:     repos,
+		Mailbox:   mailbox,
+		StaticDir: staticDir,
+		t:         t,
+	}
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Do sends request with given body and bearer token (if not empty) and returns response.
+// String and byte slice bodies are sent as is, other bodies are encoded to JSON
+func (s *Server) Do(method, path, token string, body interface{}) *Response {
+	s.t.Helper()
+
+	return s.DoWithHeader(method, path, token, body, nil)
+}
+
+// DoWithHeader is like Do but sets given headers of the request
+func (s *Server) DoWithHeader(method, path, token string, body interface{}, header http.Header) *Response {
+	s.t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatalf("encode request body: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		s.t.Fatalf("new request: %v", err)
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		s.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatalf("read response body: %v", err)
+	}
+
+	return &Response{
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Body:   respBody,
+		t:      s.t,
+	}
+}
+
+// SignUp signs up user with given email and Password and returns user's id
+func (s *Server) SignUp(email string) int {
+	s.t.Helper()
+
+	resp := s.Do(http.MethodPost, "/v1/user/signup", "", entity.UserSignupForm{
+		FirstName: "Ann",
+		LastName:  "Lee",
+		Email:     email,
+		Password:  Password,
+	})
+	resp.ExpectStatus(http.StatusOK)
+
+	var signup entity.SignupResponse
+	resp.JSON(&signup)
+
+	return signup.UserID
+}
+
+// LogIn logs user in with given email and password and returns issued tokens
+func (s *Server) LogIn(email, password string) entity.LoginResponse {
+	s.t.Helper()
+
+	resp := s.Do(http.MethodPost, "/v1/user/login", "", entity.UserLoginForm{Email: email, Password: password})
+	resp.ExpectStatus(http.StatusCreated)
+
+	var tokens entity.LoginResponse
+	resp.JSON(&tokens)
+
+	return tokens
+}
+
+// NewSession signs up user with given email and logs the user in
+func (s *Server) NewSession(email string) Session {
+	s.t.Helper()
+
+	id := s.SignUp(email)
+	tokens := s.LogIn(email, Password)
+
+	return Session{
+		Id:           id,
+		Email:        email,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}
+}
+
+// NewAdminSession signs up user with given email, grants the user admin role and logs the user in
+func (s *Server) NewAdminSession(email string) Session {
+	s.t.Helper()
+
+	id := s.SignUp(email)
+	if err := s.Services.Admin.SeedAdmin(context.Background(), email, ""); err != nil {
+		s.t.Fatalf("grant admin role: %v", err)
+	}
+
+	tokens := s.LogIn(email, Password)
+
+	return Session{
+		Id:           id,
+		Email:        email,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}
+}
+
+// Response is response of the application
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	t *testing.T
+}
+
+// ExpectStatus fails the test if response has other status
+func (r *Response) ExpectStatus(status int) *Response {
+	r.t.Helper()
+
+	if r.Status != status {
+		r.t.Fatalf("status = %d; want %d, body: %s", r.Status, status, r.Body)
+	}
+
+	return r
+}
+
+// JSON decodes response body into 'target'
+func (r *Response) JSON(target interface{}) {
+	r.t.Helper()
+
+	if err := json.Unmarshal(r.Body, target); err != nil {
+		r.t.Fatalf("decode response body %q: %v", r.Body, err)
+	}
+}
+
+// volatileFields are JSON fields whose values differ between runs, they are
+// replaced with placeholders in golden files
+var volatileFields = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"mfa_token":     true,
+	"secret":        true,
+	"otpauthUri":    true,
+	"recoveryCodes": true,
+	"createdAt":     true,
+	"disabledAt":    true,
+	"nextCursor":    true,
+	"avatarUrl":     true,
+	"durationMs":    true,
+}
+
+// ignoredHeaders are response headers golden files do not contain
+var ignoredHeaders = map[string]bool{
+	"Date":           true,
+	"Content-Length": true,
+}
+
+// Golden compares response with golden file 'testdata/<name>.golden'. File holds status,
+// headers and indented JSON body with volatile fields replaced with placeholders
+func (r *Response) Golden(name string) {
+	r.t.Helper()
+
+	got := r.dump()
+	path := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			r.t.Fatalf("write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		r.t.Fatalf("read golden file (run tests with -update to create it): %v", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		r.t.Errorf("response differs from %s\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
+
+// dump returns response in golden file format
+func (r *Response) dump() []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "%d %s\n", r.Status, http.StatusText(r.Status))
+
+	keys := make([]string, 0, len(r.Header))
+	for key := range r.Header {
+		if !ignoredHeaders[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range r.Header[key] {
+			fmt.Fprintf(&b, "%s: %s\n", key, value)
+		}
+	}
+
+	if len(r.Body) > 0 {
+		b.WriteString("\n")
+		b.Write(normalize(r.Body))
+		b.WriteString("\n")
+	}
+
+	return b.Bytes()
+}
+
+// normalize indents JSON body and replaces values of volatile fields with placeholders.
+// Body which is not JSON is returned as is
+func normalize(body []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+
+	var b bytes.Buffer
+
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(redact(v)); err != nil {
+		return body
+	}
+
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if volatileFields[key] && value != nil {
+				v[key] = "<" + key + ">"
+				continue
+			}
+			v[key] = redact(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redact(value)
+		}
+	}
+
+	return v
+}
+
+// Mailbox is mailer keeping sent messages
+type Mailbox struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+var _ mailer.Mailer = (*Mailbox)(nil)
+
+func (m *Mailbox) Send(_ context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+
+	return nil
+}
+
+var tokenLink = regexp.MustCompile(`https?://\S+\?token=\S+`)
+
+// LastLink returns link with token of the last message sent to given address
+func (m *Mailbox) LastLink(t *testing.T, to string) *url.URL {
+	t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To != to {
+			continue
+		}
+
+		match := tokenLink.FindString(m.messages[i].Body)
+		if match == "" {
+			t.Fatalf("message to %s has no token link: %s", to, m.messages[i].Body)
+		}
+
+		link, err := url.Parse(match)
+		if err != nil {
+			t.Fatalf("parse link: %v", err)
+		}
+
+		return link
+	}
+
+	t.Fatalf("no messages sent to %s", to)
+	return nil
+}
+
+// LastToken returns token from link of the last message sent to given address
+func (m *Mailbox) LastToken(t *testing.T, to string) string {
+	t.Helper()
+
+	return m.LastLink(t, to).Query().Get("token")
+}
+
+// FollowLink requests page given link of email points to from the application
+func (s *Server) FollowLink(link *url.URL) *Response {
+	s.t.Helper()
+
+	return s.Do(http.MethodGet, link.RequestURI(), "", nil)
+}
@@ -0,0 +1,60 @@
+package e2e
+
+import (
+	"context"
+	"errors"
+	"inditilla/pkg/health"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	s := New(t)
+
+	s.Do(http.MethodGet, "/healthz", "", nil).ExpectStatus(http.StatusOK).Golden("healthz_ok")
+	s.Do(http.MethodGet, "/readyz", "", nil).ExpectStatus(http.StatusOK).Golden("readyz_ok")
+
+	// Registered dependency is checked along with built-in checks
+	s.Services.Health.Register("cache", health.CheckerFunc(func(context.Context) error {
+		return errors.New("connection refused")
+	}))
+	s.Do(http.MethodGet, "/readyz", "", nil).ExpectStatus(http.StatusServiceUnavailable).Golden("readyz_check_failed")
+
+	// Liveness does not depend on checks
+	s.Do(http.MethodGet, "/healthz", "", nil).ExpectStatus(http.StatusOK)
+}
+
+func TestHealthTimeout(t *testing.T) {
+	s := New(t)
+
+	// Checker ignoring context fails once timeout is over
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+
+	s.Services.Health.Register("slow", health.CheckerFunc(func(context.Context) error {
+		<-block
+		return nil
+	}))
+
+	start := time.Now()
+	resp := s.Do(http.MethodGet, "/readyz", "", nil).ExpectStatus(http.StatusServiceUnavailable)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("readiness check took %s, timeout is not applied", elapsed)
+	}
+
+	var report health.Report
+	resp.JSON(&report)
+	if got := report.Checks["slow"].Error; got != context.DeadlineExceeded.Error() {
+		t.Errorf("slow check error = %q; want %q", got, context.DeadlineExceeded.Error())
+	}
+}
+
+func TestReadinessShuttingDown(t *testing.T) {
+	s := New(t)
+
+	s.Services.Health.SetShuttingDown()
+
+	s.Do(http.MethodGet, "/readyz", "", nil).ExpectStatus(http.StatusServiceUnavailable).Golden("readyz_shutting_down")
+	s.Do(http.MethodGet, "/healthz", "", nil).ExpectStatus(http.StatusOK)
+}
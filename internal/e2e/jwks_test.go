@@ -0,0 +1,38 @@
+package e2e
+
+import (
+	"fmt"
+	"inditilla/internal/service/user"
+	"inditilla/pkg/jwks"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJWKS(t *testing.T) {
+	keys, err := jwks.LoadDir(filepath.Join("testdata", "keys"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Authorizer, err = user.NewKeyAuthorizer("RS256", keys, "2024-02", 12*time.Hour, 30*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewWithConfig(t, cfg)
+
+	// Retired public-only key stays published, so tokens it signed could still be verified
+	s.Do(http.MethodGet, "/.well-known/jwks.json", "", nil).Golden("jwks_ok")
+
+	ann := s.NewSession("ann@example.com")
+	s.Do(http.MethodGet, fmt.Sprintf("/v1/user/profile/%d", ann.Id), ann.AccessToken, nil).ExpectStatus(http.StatusOK)
+}
+
+func TestJWKSSharedSecret(t *testing.T) {
+	s := New(t)
+
+	s.Do(http.MethodGet, "/.well-known/jwks.json", "", nil).Golden("jwks_shared_secret")
+}
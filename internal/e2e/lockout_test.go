@@ -0,0 +1,83 @@
+package e2e
+
+import (
+	"fmt"
+	"inditilla/internal/entity"
+	"inditilla/internal/service/user"
+	"inditilla/pkg/totp"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// lockoutConfig returns configuration locking account after 3 failures, without backoff,
+// so Retry-After of locked account is always the whole lockout duration
+func lockoutConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Policy.Lockout = user.LockoutPolicy{MaxFailures: 3, Duration: 15 * time.Minute}
+
+	return cfg
+}
+
+func TestLockout(t *testing.T) {
+	s := NewWithConfig(t, lockoutConfig())
+	admin := s.NewAdminSession("admin@example.com")
+	ann := s.NewSession("ann@example.com")
+
+	login := func(password string) *Response {
+		t.Helper()
+
+		return s.Do(http.MethodPost, "/v1/user/login", "", map[string]string{
+			"email":    ann.Email,
+			"password": password,
+		})
+	}
+
+	for i := 0; i < 3; i++ {
+		login("wrong-password").ExpectStatus(http.StatusBadRequest)
+	}
+
+	// Account is locked even for the right password
+	login(Password).Golden("login_locked_out")
+
+	s.Do(http.MethodPost, fmt.Sprintf("/v1/admin/users/%d/unlock", ann.Id), ann.AccessToken, nil).Golden("admin_unlock_forbidden")
+	s.Do(http.MethodPost, fmt.Sprintf("/v1/admin/users/%d/unlock", ann.Id), admin.AccessToken, nil).Golden("admin_unlock_ok")
+
+	login(Password).ExpectStatus(http.StatusCreated)
+}
+
+func TestLockoutMfa(t *testing.T) {
+	s := NewWithConfig(t, lockoutConfig())
+	ann := s.NewSession("ann@example.com")
+
+	var secret entity.TotpSetupResponse
+	s.Do(http.MethodPost, "/v1/user/mfa/totp/setup", ann.AccessToken, nil).ExpectStatus(http.StatusOK).JSON(&secret)
+
+	code, err := totp.Code(secret.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Do(http.MethodPost, "/v1/user/mfa/totp/confirm", ann.AccessToken, map[string]string{"code": code}).ExpectStatus(http.StatusOK)
+
+	login := func() *Response {
+		t.Helper()
+
+		return s.Do(http.MethodPost, "/v1/user/login", "", map[string]string{
+			"email":    ann.Email,
+			"password": Password,
+		})
+	}
+
+	// Right password does not make wrong codes forgotten
+	for i := 0; i < 3; i++ {
+		var challenge entity.MfaChallengeResponse
+		login().ExpectStatus(http.StatusAccepted).JSON(&challenge)
+
+		s.Do(http.MethodPost, "/v1/user/login/mfa", "", map[string]string{
+			"mfa_token": challenge.MfaToken,
+			"code":      "000000",
+		}).ExpectStatus(http.StatusBadRequest)
+	}
+
+	login().Golden("login_mfa_locked_out")
+}
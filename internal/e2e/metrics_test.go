@@ -0,0 +1,81 @@
+package e2e
+
+import (
+	"inditilla/internal/data"
+	"inditilla/internal/entity"
+	"inditilla/pkg/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go/v4"
+)
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+
+	cfg := DefaultConfig()
+	cfg.Router.Metrics = m
+	s := NewWithConfig(t, cfg)
+
+	ann := s.NewSession("ann@example.com")
+	s.Do(http.MethodPost, "/v1/user/login", "", entity.UserLoginForm{Email: ann.Email, Password: "wrong"}).ExpectStatus(http.StatusBadRequest)
+
+	s.Do(http.MethodGet, "/v1/user/profile/"+strconv.Itoa(ann.Id), ann.AccessToken, nil).ExpectStatus(http.StatusOK)
+	s.Do(http.MethodGet, "/v1/user/profile/"+strconv.Itoa(ann.Id), "", nil).ExpectStatus(http.StatusUnauthorized)
+	s.Do(http.MethodGet, "/v1/user/profile/"+strconv.Itoa(ann.Id), "garbage", nil).ExpectStatus(http.StatusUnauthorized)
+
+	expired, err := (&data.TokenModel{}).New(jwt.SigningMethodHS256, ann.Id, ann.Email, nil, "session", -time.Minute).SignedString([]byte(SigningKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Do(http.MethodGet, "/v1/user/profile/"+strconv.Itoa(ann.Id), expired, nil).ExpectStatus(http.StatusUnauthorized)
+	s.Do(http.MethodGet, "/no/such/route", "", nil).ExpectStatus(http.StatusNotFound)
+	s.Do("BREW", "/no/such/route", "", nil).ExpectStatus(http.StatusNotFound)
+	s.Do("PROPFIND", "/v1/user/profile/"+strconv.Itoa(ann.Id), "", nil)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("metrics status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+
+	// Requests are labelled by route pattern, not by raw path
+	for _, want := range []string{
+		`inditilla_http_requests_total{method="POST",route="/v1/user/signup",status="200"} 1`,
+		`inditilla_http_requests_total{method="POST",route="/v1/user/login",status="201"} 1`,
+		`inditilla_http_requests_total{method="POST",route="/v1/user/login",status="400"} 1`,
+		`inditilla_http_requests_total{method="GET",route="/v1/user/profile/:id",status="200"} 1`,
+		`inditilla_http_requests_total{method="GET",route="/v1/user/profile/:id",status="401"} 3`,
+		`inditilla_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`inditilla_http_request_duration_seconds_count{method="GET",route="/v1/user/profile/:id",status="200"} 1`,
+		`inditilla_auth_signups_total 1`,
+		`inditilla_auth_logins_total{result="success"} 1`,
+		`inditilla_auth_logins_total{result="failure"} 1`,
+		`inditilla_auth_token_validation_failures_total{reason="missing"} 1`,
+		`inditilla_auth_token_validation_failures_total{reason="invalid"} 1`,
+		`inditilla_auth_token_validation_failures_total{reason="expired"} 1`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics do not contain %q", want)
+		}
+	}
+
+	if strings.Contains(body, `route="/v1/user/profile/`+strconv.Itoa(ann.Id)+`"`) {
+		t.Errorf("requests are labelled by raw path")
+	}
+
+	// Custom methods are labelled together, whatever clients send
+	if !strings.Contains(body, `inditilla_http_requests_total{method="OTHER",route="unmatched",status="404"} 1`+"\n") {
+		t.Errorf("requests with custom method are not labelled as other method")
+	}
+	for _, method := range []string{"BREW", "PROPFIND"} {
+		if strings.Contains(body, `method="`+method+`"`) {
+			t.Errorf("requests are labelled by custom method %s", method)
+		}
+	}
+}
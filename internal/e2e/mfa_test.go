@@ -0,0 +1,94 @@
+package e2e
+
+import (
+	"inditilla/internal/entity"
+	"inditilla/internal/service/user"
+	"inditilla/pkg/totp"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMfa(t *testing.T) {
+	// Wrong codes are not throttled here, it is covered by TestLockoutMfa
+	cfg := DefaultConfig()
+	cfg.Policy.Lockout = user.LockoutPolicy{}
+
+	s := NewWithConfig(t, cfg)
+	ann := s.NewSession("ann@example.com")
+
+	setup := s.Do(http.MethodPost, "/v1/user/mfa/totp/setup", ann.AccessToken, nil)
+	setup.Golden("mfa_setup_ok")
+
+	var secret entity.TotpSetupResponse
+	setup.JSON(&secret)
+
+	// Codes of the step after current one are accepted too, so every step is used once
+	step := totp.Step(time.Now())
+	code := func() string {
+		t.Helper()
+
+		c, err := totp.Code(secret.Secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step++
+
+		return c
+	}
+
+	confirm := "/v1/user/mfa/totp/confirm"
+	s.Do(http.MethodPost, confirm, ann.AccessToken, map[string]string{"code": "abcdef"}).Golden("mfa_confirm_invalid_code")
+
+	confirmed := s.Do(http.MethodPost, confirm, ann.AccessToken, map[string]string{"code": code()})
+	confirmed.Golden("mfa_confirm_ok")
+
+	var recovery entity.RecoveryCodesResponse
+	confirmed.JSON(&recovery)
+
+	s.Do(http.MethodPost, confirm, ann.AccessToken, map[string]string{"code": "123456"}).Golden("mfa_confirm_already_enabled")
+
+	login := func() string {
+		t.Helper()
+
+		resp := s.Do(http.MethodPost, "/v1/user/login", "", map[string]string{
+			"email":    ann.Email,
+			"password": Password,
+		}).ExpectStatus(http.StatusAccepted)
+
+		var challenge entity.MfaChallengeResponse
+		resp.JSON(&challenge)
+
+		return challenge.MfaToken
+	}
+
+	s.Do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"email":    ann.Email,
+		"password": Password,
+	}).Golden("login_mfa_challenge")
+
+	loginMfa := func(mfaToken, code string) *Response {
+		t.Helper()
+
+		return s.Do(http.MethodPost, "/v1/user/login/mfa", "", map[string]string{
+			"mfa_token": mfaToken,
+			"code":      code,
+		})
+	}
+
+	totpCode := code()
+	loginMfa(login(), totpCode).Golden("login_mfa_ok")
+	loginMfa(login(), totpCode).Golden("login_mfa_reused_code")
+
+	// Challenge is single use, even if code was wrong
+	challenge := login()
+	loginMfa(challenge, "000000").Golden("login_mfa_wrong_code")
+	loginMfa(challenge, code()).Golden("login_mfa_used_challenge")
+
+	// Recovery codes are accepted in any case and with or without dash
+	loginMfa(login(), recovery.RecoveryCodes[0]).Golden("login_mfa_recovery_code")
+	loginMfa(login(), recovery.RecoveryCodes[0]).Golden("login_mfa_used_recovery_code")
+	typed := strings.ToUpper(strings.Replace(recovery.RecoveryCodes[1], "-", "", 1))
+	loginMfa(login(), typed).ExpectStatus(http.StatusCreated)
+}
@@ -0,0 +1,47 @@
+package e2e
+
+import (
+	"inditilla/pkg/ratelimit"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Router.Limiter = ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Limit{Requests: 100, Period: time.Hour}, map[string]ratelimit.Limit{
+		"POST /v1/user/password/forgot": {Requests: 1, Period: time.Hour},
+	})
+
+	s := NewWithConfig(t, cfg)
+	forgot := map[string]string{"email": "ann@example.com"}
+
+	s.Do(http.MethodPost, "/v1/user/password/forgot", "", forgot).Golden("rate_limit_allowed")
+	s.Do(http.MethodPost, "/v1/user/password/forgot", "", forgot).Golden("rate_limit_exceeded")
+}
+
+func TestRateLimitSkipsProbes(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Router.Limiter = ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Limit{Requests: 1, Period: time.Hour}, nil)
+
+	s := NewWithConfig(t, cfg)
+
+	s.Do(http.MethodGet, "/openapi.json", "", nil).ExpectStatus(http.StatusOK)
+	s.Do(http.MethodGet, "/openapi.json", "", nil).ExpectStatus(http.StatusTooManyRequests)
+
+	// Probes are served after clients exhausted global limit and do not consume it
+	for i := 0; i < 3; i++ {
+		resp := s.Do(http.MethodGet, "/healthz", "", nil).ExpectStatus(http.StatusOK)
+		s.Do(http.MethodGet, "/readyz", "", nil).ExpectStatus(http.StatusOK)
+
+		if limit := resp.Header.Get("RateLimit-Limit"); limit != "" {
+			t.Errorf("probe response has RateLimit-Limit header %q", limit)
+		}
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	s := New(t)
+
+	s.Do(http.MethodGet, "/v1/unknown", "", nil).Golden("not_found")
+}
@@ -0,0 +1,81 @@
+package e2e
+
+import (
+	"context"
+	"inditilla/internal/data"
+	"inditilla/internal/entity"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswordReset(t *testing.T) {
+	s := New(t)
+	ann := s.NewSession("ann@example.com")
+
+	forgot := func(email string) *Response {
+		return s.Do(http.MethodPost, "/v1/user/password/forgot", "", map[string]string{"email": email})
+	}
+	reset := func(token, password string) *Response {
+		return s.Do(http.MethodPost, "/v1/user/password/reset", "", map[string]string{"token": token, "password": password})
+	}
+
+	// Response does not tell whether email is registered
+	forgot("ann@example.com").Golden("password_forgot_ok")
+	forgot("nobody@example.com").Golden("password_forgot_unknown_email")
+	forgot("not-an-email").Golden("password_forgot_invalid_email")
+
+	// Only the latest token could be used
+	superseded := s.Mailbox.LastToken(t, "ann@example.com")
+	forgot("ann@example.com").ExpectStatus(http.StatusAccepted)
+	token := s.Mailbox.LastToken(t, "ann@example.com")
+
+	reset("not-a-token", "New-password-1").Golden("password_reset_unknown_token")
+	reset(superseded, "New-password-1").Golden("password_reset_superseded_token")
+	reset(expiredToken(t, s, ann.Id, entity.TokenPurposePasswordReset, nil), "New-password-1").Golden("password_reset_expired_token")
+	reset(token, "short").Golden("password_reset_invalid_data")
+
+	// Link of the email opens page setting password with its token
+	link := s.Mailbox.LastLink(t, "ann@example.com")
+	if link.Path != "/ui/reset-password.html" {
+		t.Errorf("reset link = %s; want reset password page", link)
+	}
+	page := s.FollowLink(link).ExpectStatus(http.StatusOK)
+	if !strings.Contains(string(page.Body), `data-page="reset-password"`) {
+		t.Errorf("reset link opens other page: %s", page.Body)
+	}
+
+	reset(token, "New-password-1").Golden("password_reset_ok")
+	reset(token, "Other-password-1").Golden("password_reset_used_token")
+
+	// Sessions started before reset are revoked, only the new password is accepted
+	s.Do(http.MethodPost, "/v1/user/token/refresh", "", map[string]string{
+		"refresh_token": ann.RefreshToken,
+	}).ExpectStatus(http.StatusUnauthorized)
+	s.Do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"email":    ann.Email,
+		"password": Password,
+	}).ExpectStatus(http.StatusBadRequest)
+	s.LogIn(ann.Email, "New-password-1")
+}
+
+// expiredToken saves one-time token of given purpose issued to user which has just expired
+// and returns it
+func expiredToken(t *testing.T, s *Server, userId int, purpose string, email *string) string {
+	t.Helper()
+
+	plain := "expired-" + purpose
+	err := s.Repos.Token.SaveOneTimeToken(context.Background(), &entity.OneTimeToken{
+		UserId:    userId,
+		Purpose:   purpose,
+		TokenHash: data.HashToken(plain),
+		Email:     email,
+		ExpiresAt: time.Now().Add(-time.Second),
+	})
+	if err != nil {
+		t.Fatalf("save expired token: %v", err)
+	}
+
+	return plain
+}
@@ -0,0 +1,90 @@
+package e2e
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStaticFiles(t *testing.T) {
+	s := New(t)
+
+	dir := filepath.Join(s.StaticDir, "avatars", "1")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"a-64.jpg": "jpeg", ".upload-1": "partial"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp := s.Do(http.MethodGet, "/static/avatars/1/a-64.jpg", "", nil).ExpectStatus(http.StatusOK)
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("ETag header is not set")
+	}
+	if got := resp.Header.Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Errorf("Cache-Control = %q; want %q", got, "public, max-age=3600")
+	}
+	if got := resp.Header.Get("Content-Type"); got != "image/jpeg" {
+		t.Errorf("Content-Type = %q; want %q", got, "image/jpeg")
+	}
+	if string(resp.Body) != "jpeg" {
+		t.Errorf("body = %q; want %q", resp.Body, "jpeg")
+	}
+
+	s.DoWithHeader(http.MethodGet, "/static/avatars/1/a-64.jpg", "", nil, http.Header{"If-None-Match": {etag}}).ExpectStatus(http.StatusNotModified)
+
+	// ETag follows file content
+	if err := os.WriteFile(filepath.Join(dir, "a-64.jpg"), []byte("new jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	resp = s.DoWithHeader(http.MethodGet, "/static/avatars/1/a-64.jpg", "", nil, http.Header{"If-None-Match": {etag}}).ExpectStatus(http.StatusOK)
+	if resp.Header.Get("ETag") == etag {
+		t.Errorf("ETag was not changed along with file content")
+	}
+
+	// Directories are not listed and hidden files are not served
+	s.Do(http.MethodGet, "/static/avatars/1/", "", nil).ExpectStatus(http.StatusNotFound)
+	s.Do(http.MethodGet, "/static/avatars/1/.upload-1", "", nil).ExpectStatus(http.StatusNotFound)
+	s.Do(http.MethodGet, "/static/../go.mod", "", nil).ExpectStatus(http.StatusNotFound)
+}
+
+func TestWebUI(t *testing.T) {
+	s := New(t)
+
+	for _, page := range []string{"/ui/", "/ui/signup.html", "/ui/profile.html", "/ui/reset-password.html", "/ui/verify-email.html"} {
+		resp := s.Do(http.MethodGet, page, "", nil).ExpectStatus(http.StatusOK)
+
+		if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+			t.Errorf("%s: Content-Type = %q; want text/html", page, got)
+		}
+		if got := resp.Header.Get("Cache-Control"); got != "no-cache" {
+			t.Errorf("%s: Cache-Control = %q; want %q", page, got, "no-cache")
+		}
+		// Content-Security-Policy denies inline scripts and styles
+		for _, inline := range []string{"<script>", "<style", " style=", " onclick=", " onsubmit="} {
+			if strings.Contains(string(resp.Body), inline) {
+				t.Errorf("%s: page contains inline %q", page, inline)
+			}
+		}
+	}
+
+	s.Do(http.MethodGet, "/ui/app.js", "", nil).ExpectStatus(http.StatusOK)
+
+	client := *s.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	resp, err := client.Get(s.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/ui/" {
+		t.Errorf("GET / = %d to %q; want %d to %q", resp.StatusCode, resp.Header.Get("Location"), http.StatusFound, "/ui/")
+	}
+}
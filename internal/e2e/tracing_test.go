@@ -0,0 +1,112 @@
+package e2e
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	s := New(t)
+	ann := s.NewSession("ann@example.com")
+
+	// Request continues trace of the caller given in traceparent header
+	const (
+		traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanId  = "00f067aa0ba902b7"
+	)
+	header := http.Header{"Traceparent": {"00-" + traceId + "-" + spanId + "-01"}}
+
+	body := map[string]string{"password": "Password-2"}
+	s.DoWithHeader(http.MethodPatch, "/v1/user/profile/"+strconv.Itoa(ann.Id), ann.AccessToken, body, header).ExpectStatus(http.StatusOK)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() == traceId {
+			spans[span.Name()] = span
+		}
+	}
+
+	root, ok := spans["PATCH /v1/user/profile/:id"]
+	if !ok {
+		t.Fatalf("no span of request named by route pattern in caller's trace, spans: %v", names(spans))
+	}
+	if got := root.Parent().SpanID().String(); got != spanId {
+		t.Errorf("request span parent = %s; want caller's span %s", got, spanId)
+	}
+	if root.SpanKind() != trace.SpanKindServer {
+		t.Errorf("request span kind = %s; want %s", root.SpanKind(), trace.SpanKindServer)
+	}
+
+	// Service methods called by jwtAuth and handler are nested in request span, hashing in service span
+	for _, name := range []string{"UserService.IsRevoked", "UserService.ResolveCaller", "UserService.GetById", "UserService.Update"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("no span %s, spans: %v", name, names(spans))
+			continue
+		}
+		if span.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("span %s is not nested in request span", name)
+		}
+	}
+
+	hash, ok := spans["bcrypt.GenerateFromPassword"]
+	if !ok {
+		t.Fatalf("no span of password hashing, spans: %v", names(spans))
+	}
+	if update, ok := spans["UserService.Update"]; ok && hash.Parent().SpanID() != update.SpanContext().SpanID() {
+		t.Errorf("password hashing span is not nested in UserService.Update span")
+	}
+
+	// Span of request with custom method is named as other method, the method is kept in attribute
+	s.Do("BREW", "/no/such/route", "", nil).ExpectStatus(http.StatusNotFound)
+
+	var other sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "BREW unmatched" {
+			t.Errorf("span is named by custom method")
+		}
+		if span.Name() == "OTHER unmatched" {
+			other = span
+		}
+	}
+	if other == nil {
+		t.Fatal("no span of request with custom method named as other method")
+	}
+	var original string
+	for _, attr := range other.Attributes() {
+		if attr.Key == semconv.HTTPRequestMethodOriginalKey {
+			original = attr.Value.AsString()
+		}
+	}
+	if original != "BREW" {
+		t.Errorf("original method = %q; want BREW", original)
+	}
+}
+
+func names(spans map[string]sdktrace.ReadOnlySpan) []string {
+	names := make([]string, 0, len(spans))
+	for name := range spans {
+		names = append(names, name)
+	}
+
+	return names
+}
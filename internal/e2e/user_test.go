@@ -0,0 +1,330 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"inditilla/internal/entity"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSignUp(t *testing.T) {
+	s := New(t)
+
+	tests := []struct {
+		name string
+		body interface{}
+	}{
+		{"signup_ok", `{"firstName":"Ann","lastName":"Lee","email":"ann@example.com","password":"Password-1"}`},
+		{"signup_duplicate_email", `{"firstName":"Ann","lastName":"Lee","email":"ann@example.com","password":"Password-1"}`},
+		{"signup_invalid_data", `{"firstName":"","lastName":"Lee","email":"not-an-email","password":"short"}`},
+		{"signup_empty_body", ``},
+		{"signup_malformed_json", `{"firstName":`},
+		{"signup_bad_syntax", `{"firstName" "Ann"}`},
+		{"signup_wrong_type", `{"firstName":1}`},
+		{"signup_unknown_field", `{"nickname":"ann"}`},
+		{"signup_two_values", `{"firstName":"Ann"}{"firstName":"Bob"}`},
+	}
+
+	// Cases run in order, duplicate email one relies on user signed up by the first case
+	for _, tt := range tests {
+		s.Do(http.MethodPost, "/v1/user/signup", "", tt.body).Golden(tt.name)
+	}
+}
+
+func TestLogIn(t *testing.T) {
+	s := New(t)
+	s.SignUp("ann@example.com")
+
+	s.Do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"email":    "ann@example.com",
+		"password": Password,
+	}).Golden("login_ok")
+
+	s.Do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"email":    "ann@example.com",
+		"password": "wrong-password",
+	}).Golden("login_wrong_password")
+
+	s.Do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"email":    "bob@example.com",
+		"password": Password,
+	}).Golden("login_unknown_email")
+}
+
+func TestProfile(t *testing.T) {
+	s := New(t)
+	ann := s.NewSession("ann@example.com")
+	bob := s.NewSession("bob@example.com")
+
+	annProfile := fmt.Sprintf("/v1/user/profile/%d", ann.Id)
+
+	s.Do(http.MethodGet, annProfile, "", nil).Golden("profile_no_token")
+	s.Do(http.MethodGet, annProfile, "not-a-jwt", nil).Golden("profile_invalid_token")
+	s.Do(http.MethodGet, annProfile, ann.AccessToken, nil).Golden("profile_own")
+	s.Do(http.MethodGet, annProfile, bob.AccessToken, nil).Golden("profile_other_user")
+	s.Do(http.MethodGet, "/v1/user/profile/abc", ann.AccessToken, nil).Golden("profile_invalid_id")
+}
+
+func TestProfileUpdate(t *testing.T) {
+	s := New(t)
+	ann := s.NewSession("ann@example.com")
+
+	profile := fmt.Sprintf("/v1/user/profile/%d", ann.Id)
+	etag := s.Do(http.MethodGet, profile, ann.AccessToken, nil).ExpectStatus(http.StatusOK).Header.Get("ETag")
+
+	update := map[string]string{"firstName": "Anna"}
+
+	s.DoWithHeader(http.MethodPatch, profile, ann.AccessToken, update, http.Header{"If-Match": {etag}}).Golden("profile_update_ok")
+	s.DoWithHeader(http.MethodPatch, profile, ann.AccessToken, update, http.Header{"If-Match": {etag}}).Golden("profile_update_stale_etag")
+	s.Do(http.MethodPatch, profile, ann.AccessToken, `{"firstName":`).Golden("profile_update_malformed_json")
+}
+
+func TestProfileUpdateRequiresIfMatch(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Router.RequireIfMatch = true
+
+	s := NewWithConfig(t, cfg)
+	ann := s.NewSession("ann@example.com")
+
+	s.Do(http.MethodPatch, fmt.Sprintf("/v1/user/profile/%d", ann.Id), ann.AccessToken, map[string]string{
+		"firstName": "Anna",
+	}).Golden("profile_update_no_if_match")
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	s := New(t)
+	ann := s.NewSession("ann@example.com")
+
+	s.Do(http.MethodPost, "/v1/user/token/refresh", "", map[string]string{
+		"refresh_token": ann.RefreshToken,
+	}).Golden("refresh_ok")
+
+	// Used refresh token is revoked
+	s.Do(http.MethodPost, "/v1/user/token/refresh", "", map[string]string{
+		"refresh_token": ann.RefreshToken,
+	}).Golden("refresh_reused")
+
+	session := s.LogIn(ann.Email, Password)
+
+	s.Do(http.MethodPost, "/v1/user/logout", session.AccessToken, nil).Golden("logout_ok")
+	s.Do(http.MethodGet, fmt.Sprintf("/v1/user/profile/%d", ann.Id), session.AccessToken, nil).Golden("profile_after_logout")
+}
+
+func TestEmailVerification(t *testing.T) {
+	s := New(t)
+	id := s.SignUp("ann@example.com")
+
+	verify := func(token string) *Response {
+		return s.Do(http.MethodPost, "/v1/user/email/verify", "", map[string]string{"token": token})
+	}
+	resend := func(email string) *Response {
+		return s.Do(http.MethodPost, "/v1/user/email/verify/resend", "", map[string]string{"email": email})
+	}
+
+	// Resent token replaces the one sent on sign up. Response does not tell whether email is registered
+	superseded := s.Mailbox.LastToken(t, "ann@example.com")
+	resend("ann@example.com").Golden("email_resend_ok")
+	resend("nobody@example.com").Golden("email_resend_unknown_email")
+	resend("not-an-email").Golden("email_resend_invalid_email")
+	token := s.Mailbox.LastToken(t, "ann@example.com")
+
+	email := "ann@example.com"
+	verify("not-a-token").Golden("email_verify_unknown_token")
+	verify(superseded).Golden("email_verify_superseded_token")
+	verify(expiredToken(t, s, id, entity.TokenPurposeEmailVerification, &email)).Golden("email_verify_expired_token")
+	verify("").Golden("email_verify_invalid_data")
+
+	// Link of the email opens page verifying email with its token
+	link := s.Mailbox.LastLink(t, "ann@example.com")
+	if link.Path != "/ui/verify-email.html" {
+		t.Errorf("verification link = %s; want verify email page", link)
+	}
+	page := s.FollowLink(link).ExpectStatus(http.StatusOK)
+	if !strings.Contains(string(page.Body), `data-page="verify-email"`) {
+		t.Errorf("verification link opens other page: %s", page.Body)
+	}
+
+	verify(token).Golden("email_verify_ok")
+	verify(token).Golden("email_verify_used_token")
+
+	// Nothing is sent to verified email
+	sent := len(s.Mailbox.messages)
+	resend("ann@example.com").Golden("email_resend_verified")
+	if len(s.Mailbox.messages) != sent {
+		t.Error("verification email is sent to verified email")
+	}
+}
+
+func TestEmailChange(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Policy.RequireVerifiedEmail = true
+	s := NewWithConfig(t, cfg)
+
+	id := s.SignUp("ann@example.com")
+	s.Do(http.MethodPost, "/v1/user/email/verify", "", map[string]string{
+		"token": s.Mailbox.LastToken(t, "ann@example.com"),
+	}).ExpectStatus(http.StatusNoContent)
+	ann := s.LogIn("ann@example.com", Password)
+
+	profile := fmt.Sprintf("/v1/user/profile/%d", id)
+	etag := s.Do(http.MethodGet, profile, ann.AccessToken, nil).ExpectStatus(http.StatusOK).Header.Get("ETag")
+
+	s.DoWithHeader(http.MethodPatch, profile, ann.AccessToken, map[string]string{
+		"email": "anna@example.com",
+	}, http.Header{"If-Match": {etag}}).Golden("email_change_pending")
+
+	// Old email stays active until the new one is verified
+	s.Do(http.MethodGet, profile, ann.AccessToken, nil).Golden("email_change_profile_pending")
+	s.LogIn("ann@example.com", Password)
+	s.Do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"email":    "anna@example.com",
+		"password": Password,
+	}).Golden("email_change_login_unverified")
+
+	// Updating other fields keeps the pending change and the token already sent
+	token := s.Mailbox.LastToken(t, "anna@example.com")
+	sent := len(s.Mailbox.messages)
+	etag = s.Do(http.MethodGet, profile, ann.AccessToken, nil).ExpectStatus(http.StatusOK).Header.Get("ETag")
+	s.DoWithHeader(http.MethodPatch, profile, ann.AccessToken, map[string]string{
+		"firstName": "Anna",
+	}, http.Header{"If-Match": {etag}}).Golden("email_change_pending_other_field")
+	if len(s.Mailbox.messages) != sent {
+		t.Error("verification email is sent again on update of other field")
+	}
+
+	s.Do(http.MethodPost, "/v1/user/email/verify", "", map[string]string{
+		"token": token,
+	}).Golden("email_change_verify_ok")
+
+	// Tokens issued to the old email are not accepted anymore
+	s.Do(http.MethodGet, profile, ann.AccessToken, nil).Golden("email_change_old_token")
+
+	anna := s.LogIn("anna@example.com", Password)
+	s.Do(http.MethodGet, profile, anna.AccessToken, nil).Golden("email_change_profile_verified")
+	s.Do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"email":    "ann@example.com",
+		"password": Password,
+	}).Golden("email_change_login_old_email")
+}
+
+func TestProfileFields(t *testing.T) {
+	s := New(t)
+	ann := s.NewSession("ann@example.com")
+
+	profile := fmt.Sprintf("/v1/user/profile/%d", ann.Id)
+
+	s.Do(http.MethodPatch, profile, ann.AccessToken, map[string]string{
+		"displayName": "Annie",
+		"bio":         "Backend developer",
+		"phone":       "+14155552671",
+		"dateOfBirth": "1990-02-28",
+		"locale":      "en-US",
+		"timezone":    "Europe/Berlin",
+		"website":     "https://example.com",
+	}).Golden("profile_fields_ok")
+
+	// Fields not given are kept, empty ones are cleared
+	s.Do(http.MethodPatch, profile, ann.AccessToken, map[string]string{
+		"bio":     "",
+		"website": "",
+	}).Golden("profile_fields_partial")
+
+	s.Do(http.MethodPatch, profile, ann.AccessToken, map[string]string{
+		"phone":       "4155552671",
+		"dateOfBirth": "2990-01-01",
+		"locale":      "not a locale",
+		"timezone":    "Mars/Olympus",
+		"website":     "ftp://example.com",
+	}).Golden("profile_fields_invalid")
+}
+
+func TestAvatar(t *testing.T) {
+	s := New(t)
+	ann := s.NewSession("ann@example.com")
+	bob := s.NewSession("bob@example.com")
+
+	avatar := fmt.Sprintf("/v1/user/profile/%d/avatar", ann.Id)
+
+	upload := func(token, field string, content []byte) *Response {
+		t.Helper()
+
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, err := mw.CreateFormFile(field, "avatar.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(content)
+		mw.Close()
+
+		return s.DoWithHeader(http.MethodPut, avatar, token, body.Bytes(), http.Header{"Content-Type": {mw.FormDataContentType()}})
+	}
+
+	// Wide image is cropped to square of its center
+	img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	var content bytes.Buffer
+	if err := png.Encode(&content, img); err != nil {
+		t.Fatal(err)
+	}
+
+	var first, second, profile entity.UserProfileResponse
+
+	resp := upload(ann.AccessToken, "avatar", content.Bytes())
+	resp.Golden("avatar_ok")
+	resp.JSON(&first)
+	firstURL := first.AvatarURL
+
+	for _, size := range []string{"256", "128", "64"} {
+		name := strings.TrimPrefix(strings.Replace(firstURL, "-256.jpg", "-"+size+".jpg", 1), "/static/")
+		if _, err := os.Stat(filepath.Join(s.StaticDir, name)); err != nil {
+			t.Errorf("avatar of size %s: %v", size, err)
+		}
+	}
+
+	s.Do(http.MethodGet, fmt.Sprintf("/v1/user/profile/%d", ann.Id), ann.AccessToken, nil).ExpectStatus(http.StatusOK).JSON(&profile)
+	if profile.AvatarURL != firstURL {
+		t.Errorf("profile avatarUrl = %q; want %q", profile.AvatarURL, firstURL)
+	}
+
+	// New upload replaces previous images
+	upload(ann.AccessToken, "avatar", content.Bytes()).ExpectStatus(http.StatusOK).JSON(&second)
+	if second.AvatarURL == firstURL {
+		t.Errorf("avatarUrl was not changed by new upload")
+	}
+	if _, err := os.Stat(filepath.Join(s.StaticDir, strings.TrimPrefix(firstURL, "/static/"))); !os.IsNotExist(err) {
+		t.Errorf("previous avatar was not deleted: %v", err)
+	}
+
+	upload(ann.AccessToken, "avatar", []byte("GIF89a not really an image")).Golden("avatar_unsupported_type")
+	upload(ann.AccessToken, "avatar", append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...)).Golden("avatar_invalid_image")
+	// Too large image is rejected by its header, before its data is decoded
+	upload(ann.AccessToken, "avatar", pngHeader(4097, 4096)).ExpectStatus(http.StatusUnprocessableEntity)
+	upload(ann.AccessToken, "avatar", make([]byte, entity.AvatarMaxBytes+1)).Golden("avatar_too_large")
+	upload(ann.AccessToken, "avatar", make([]byte, entity.AvatarMaxBytes+1<<20)).ExpectStatus(http.StatusRequestEntityTooLarge)
+	upload(ann.AccessToken, "photo", content.Bytes()).Golden("avatar_missing_file")
+	upload(bob.AccessToken, "avatar", content.Bytes()).Golden("avatar_other_user")
+}
+
+// pngHeader returns PNG signature and header of 'width' x 'height' image without its data,
+// enough for image size to be read
+func pngHeader(width, height uint32) []byte {
+	chunk := []byte("IHDR")
+	chunk = binary.BigEndian.AppendUint32(chunk, width)
+	chunk = binary.BigEndian.AppendUint32(chunk, height)
+	chunk = append(chunk, 8, 2, 0, 0, 0) // 8 bit RGB, no interlace
+
+	header := []byte("\x89PNG\r\n\x1a\n")
+	header = binary.BigEndian.AppendUint32(header, uint32(len(chunk)-4))
+	header = append(header, chunk...)
+
+	return binary.BigEndian.AppendUint32(header, crc32.ChecksumIEEE(chunk))
+}
@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"inditilla/internal/service/validator"
+	"time"
+)
+
+const (
+	UserStatusActive   = "active"
+	UserStatusDisabled = "disabled"
+)
+
+// UserListForm is a query of users list. All fields are raw query parameters
+type UserListForm struct {
+	Email               string `form:"email"`
+	CreatedFrom         string `form:"created_from"`
+	CreatedTo           string `form:"created_to"`
+	Status              string `form:"status"`
+	Sort                string `form:"sort"`
+	Limit               string `form:"limit"`
+	Cursor              string `form:"cursor"`
+	validator.Validator `form:"-"`
+}
+
+// SortOrDefault returns requested sort order of users list, users are sorted by id by default
+func (f *UserListForm) SortOrDefault() string {
+	if f.Sort == "" {
+		return "id"
+	}
+
+	return f.Sort
+}
+
+// UserFilter is a validated users list query passed to repository. Users are
+// sorted by 'SortBy' column and id, page starts right after 'After' user if set
+type UserFilter struct {
+	EmailPrefix string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Status      string
+	SortBy      string
+	Desc        bool
+	Limit       int
+	After       *UserEntity
+}
+
+type AdminUserResponse struct {
+	Id         int        `json:"id"`
+	FirstName  string     `json:"firstName"`
+	LastName   string     `json:"lastName"`
+	Email      string     `json:"email"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	DisabledAt *time.Time `json:"disabledAt,omitempty"`
+}
+
+type UserListResponse struct {
+	Users      []AdminUserResponse `json:"users"`
+	NextCursor string              `json:"nextCursor,omitempty"`
+}
+
+// UserPage is a single page of users list
+type UserPage struct {
+	Users      []UserEntity
+	NextCursor string
+}
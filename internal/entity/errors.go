@@ -1,17 +1,48 @@
 package entity
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
-	ErrNoRecord           = errors.New("entity: no matching row found")
-	ErrDuplicateEmail     = errors.New("entity: duplicate email")
-	ErrInvalidCredentials = errors.New("entity: invalid credentials")
-	ErrInvalidInputData   = errors.New("entity: invalid form fill")
-	ErrInvalidUserId      = errors.New("entity: invalid user id")
-	ErrInvalidAccessToken = errors.New("entity: invalid auth token")
-	ErrEditConflict       = errors.New("entity: edit conflict")
+	ErrNoRecord            = errors.New("entity: no matching row found")
+	ErrDuplicateEmail      = errors.New("entity: duplicate email")
+	ErrInvalidCredentials  = errors.New("entity: invalid credentials")
+	ErrInvalidInputData    = errors.New("entity: invalid form fill")
+	ErrInvalidUserId       = errors.New("entity: invalid user id")
+	ErrInvalidAccessToken  = errors.New("entity: invalid auth token")
+	ErrExpiredAccessToken  = errors.New("entity: expired auth token")
+	ErrEditConflict        = errors.New("entity: edit conflict")
+	ErrInvalidRefreshToken = errors.New("entity: invalid refresh token")
+	ErrRefreshTokenReuse   = errors.New("entity: refresh token reuse detected")
+	ErrForbidden           = errors.New("entity: action forbidden")
+	ErrUserDisabled        = errors.New("entity: user is disabled")
+	ErrInvalidToken        = errors.New("entity: invalid or expired token")
+	ErrEmailNotVerified    = errors.New("entity: email is not verified")
+	ErrMfaEnabled          = errors.New("entity: mfa is already enabled")
+	ErrMfaNotSetUp         = errors.New("entity: mfa is not set up")
+	ErrInvalidMfaCode      = errors.New("entity: invalid mfa code")
+	ErrTooManyAttempts     = errors.New("entity: too many failed attempts")
+	ErrImageTooLarge       = errors.New("entity: image is too large")
+	ErrUnsupportedImage    = errors.New("entity: unsupported image type")
+	ErrInvalidImage        = errors.New("entity: invalid image")
 )
 
+// RetryError is an error of action that could be retried after some time
+type RetryError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
 type ErrorResponse struct {
 	ResponseStatus string            `json:"responseStatus"`
 	Code           int               `json:"code"`
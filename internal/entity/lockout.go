@@ -0,0 +1,13 @@
+package entity
+
+import "strings"
+
+// LoginEmailKey returns key failed log in attempts to the account with given email are counted by
+func LoginEmailKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+// LoginIPKey returns key failed log in attempts from given client IP are counted by
+func LoginIPKey(ip string) string {
+	return "ip:" + ip
+}
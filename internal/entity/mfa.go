@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"inditilla/internal/service/validator"
+	"time"
+)
+
+// Totp is user's TOTP authenticator. It is enabled only after user confirms
+// it with a valid code, until then setup could be started over
+type Totp struct {
+	UserId       int
+	Secret       string
+	ConfirmedAt  *time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+type TotpSetupResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauthUri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// LoginResult is result of password log in - token pair or, if user has MFA enabled,
+// challenge token log in is completed with
+type LoginResult struct {
+	Tokens   TokenPair
+	MfaToken string
+}
+
+type MfaChallengeResponse struct {
+	MfaToken string `json:"mfa_token"`
+}
+
+type MfaCodeForm struct {
+	Code                string `json:"code"`
+	validator.Validator `json:"-"`
+}
+
+// MfaLoginForm completes log in with TOTP code or one of recovery codes
+type MfaLoginForm struct {
+	MfaToken            string `json:"mfa_token"`
+	Code                string `json:"code"`
+	IP                  string `json:"-"` // Client IP failed attempts are counted by
+	validator.Validator `json:"-"`
+}
@@ -0,0 +1,32 @@
+package entity
+
+import "slices"
+
+const RoleAdmin = "admin"
+
+// Permissions granted by roles
+const (
+	PermProfileReadAny   = "profile:read:any"
+	PermProfileUpdateAny = "profile:update:any"
+	PermUsersRead        = "users:read"
+	PermUsersManage      = "users:manage"
+	PermRolesManage      = "roles:manage"
+
+	// PermTokensIssue is granted by no role, only operator of the application has it
+	PermTokensIssue = "tokens:issue"
+)
+
+type Role struct {
+	Id          int      `json:"id"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+type RolesResponse struct {
+	Roles []Role `json:"roles"`
+}
+
+// Can reports whether caller was granted given permission by any of its roles
+func (c Caller) Can(permission string) bool {
+	return slices.Contains(c.Permissions, permission)
+}
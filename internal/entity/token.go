@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"inditilla/internal/service/validator"
+	"time"
+)
+
+type RefreshToken struct {
+	Id        int
+	UserId    int
+	FamilyId  string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// Purposes of one time tokens
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeMfaChallenge      = "mfa_challenge"
+)
+
+// OneTimeToken is single use token sent to user by email (e.g. to reset password)
+// or given on log in to complete it with second factor
+type OneTimeToken struct {
+	Id        int
+	UserId    int
+	Purpose   string
+	TokenHash string
+	Email     *string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// TokenPair is a pair of tokens issued to user on log in and on every refresh
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+type RefreshTokenForm struct {
+	RefreshToken        string `json:"refresh_token"`
+	validator.Validator `json:"-"`
+}
+
+type TokenForm struct {
+	Token               string `json:"token"`
+	validator.Validator `json:"-"`
+}
@@ -5,18 +5,48 @@ import (
 	"time"
 )
 
+// AvatarMaxBytes is maximum size of uploaded avatar image
+const AvatarMaxBytes = 5 << 20
+
 type UserEntity struct {
-	Id                  int       `json:"id"`
-	FirstName           string    `json:"firstName"`
-	LastName            string    `json:"lastName"`
-	Email               string    `json:"email"`
-	Password            string    `json:"-"`
-	CreatedAt           time.Time `json:"createdAt"`
+	Id                  int        `json:"id"`
+	FirstName           string     `json:"firstName"`
+	LastName            string     `json:"lastName"`
+	Email               string     `json:"email"`
+	Password            string     `json:"-"`
+	EmailVerifiedAt     *time.Time `json:"emailVerifiedAt,omitempty"`
+	PendingEmail        string     `json:"-"`
+	DisabledAt          *time.Time `json:"disabledAt,omitempty"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	Version             int        `json:"-"` // Incremented on every update, used as profile's ETag
+	Avatar              string     `json:"-"` // Key prefix of avatar images in blob store, empty if not set
+	AvatarURL           string     `json:"-"` // URL of the largest avatar image, set by service
 	validator.Validator `json:"-"`
+	Profile
+}
+
+// Profile holds optional profile fields, empty field is not set
+type Profile struct {
+	DisplayName string `json:"displayName,omitempty"`
+	Bio         string `json:"bio,omitempty"`
+	Phone       string `json:"phone,omitempty"`       // E.164 format, e.g. +14155552671
+	DateOfBirth string `json:"dateOfBirth,omitempty"` // YYYY-MM-DD
+	Locale      string `json:"locale,omitempty"`      // BCP 47 language tag, e.g. en-US
+	Timezone    string `json:"timezone,omitempty"`    // IANA time zone name, e.g. Europe/Berlin
+	Website     string `json:"website,omitempty"`     // http or https URL
+}
+
+// Caller is authenticated user making the request
+type Caller struct {
+	Id          int
+	Email       string
+	Roles       []string
+	Permissions []string
 }
 
 type LoginResponse struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 type SignupResponse struct {
@@ -24,9 +54,13 @@ type SignupResponse struct {
 }
 
 type UserProfileResponse struct {
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Email     string `json:"email"`
+	FirstName     string `json:"firstName"`
+	LastName      string `json:"lastName"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"emailVerified"`
+	PendingEmail  string `json:"pendingEmail,omitempty"`
+	AvatarURL     string `json:"avatarUrl,omitempty"`
+	Profile
 }
 
 type UserSignupForm struct {
@@ -37,8 +71,51 @@ type UserSignupForm struct {
 	validator.Validator `json:"-"`
 }
 
+// UserUpdateForm is partial update of user profile, only given fields are changed.
+// Optional profile fields are cleared with empty string
+type UserUpdateForm struct {
+	FirstName   *string `json:"firstName"`
+	LastName    *string `json:"lastName"`
+	Email       *string `json:"email"`
+	Password    *string `json:"password"`
+	DisplayName *string `json:"displayName"`
+	Bio         *string `json:"bio"`
+	Phone       *string `json:"phone"`
+	DateOfBirth *string `json:"dateOfBirth"`
+	Locale      *string `json:"locale"`
+	Timezone    *string `json:"timezone"`
+	Website     *string `json:"website"`
+}
+
 type UserLoginForm struct {
 	Email               string `json:"email"`
 	Password            string `json:"password"`
+	IP                  string `json:"-"` // Client IP failed attempts are counted by
 	validator.Validator `json:"-"`
 }
+
+type ForgotPasswordForm struct {
+	Email               string `json:"email"`
+	validator.Validator `json:"-"`
+}
+
+type EmailForm struct {
+	Email               string `json:"email"`
+	validator.Validator `json:"-"`
+}
+
+type ResetPasswordForm struct {
+	Token               string `json:"token"`
+	Password            string `json:"password"`
+	validator.Validator `json:"-"`
+}
+
+// PasswordForm sets new password of user without reset token, e.g. by operator
+type PasswordForm struct {
+	Password            string `json:"password"`
+	validator.Validator `json:"-"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"inditilla/internal/entity"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func (r *routes) adminRoles(w http.ResponseWriter, req *http.Request) {
+	roles, err := r.s.Admin.Roles(req.Context())
+	if err != nil {
+		r.serverError(w, req, err, "Admin roles")
+		return
+	}
+
+	r.sendResponse(w, req, http.StatusOK, entity.RolesResponse{Roles: roles})
+}
+
+func (r *routes) adminAssignRole(w http.ResponseWriter, req *http.Request) {
+	id := r.retrieveParamId(req)
+	role := httprouter.ParamsFromContext(req.Context()).ByName("role")
+
+	err := r.s.Admin.AssignRole(req.Context(), id, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrNoRecord), errors.Is(err, entity.ErrInvalidUserId):
+			r.notFound(w, req, "Admin assign role")
+		default:
+			r.serverError(w, req, err, "Admin assign role")
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	// Log role assignment
+	r.l.Info("user with id '%d' assigned role '%s' to user with id '%s' at %s", r.callerFromContext(req).Id, role, id, time.Now().Format(timeFormat))
+}
+
+func (r *routes) adminRemoveRole(w http.ResponseWriter, req *http.Request) {
+	id := r.retrieveParamId(req)
+	role := httprouter.ParamsFromContext(req.Context()).ByName("role")
+
+	err := r.s.Admin.RemoveRole(req.Context(), id, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrNoRecord), errors.Is(err, entity.ErrInvalidUserId):
+			r.notFound(w, req, "Admin remove role")
+		default:
+			r.serverError(w, req, err, "Admin remove role")
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	// Log role removal
+	r.l.Info("user with id '%d' removed role '%s' from user with id '%s' at %s", r.callerFromContext(req).Id, role, id, time.Now().Format(timeFormat))
+}
+
+func (r *routes) adminUsers(w http.ResponseWriter, req *http.Request) {
+	var userListForm entity.UserListForm
+
+	if err := r.fd.Decode(&userListForm, req.URL.Query()); err != nil {
+		r.badRequest(w, req, errors.New("query contains invalid parameters"), "Admin users")
+		return
+	}
+
+	page, err := r.s.Admin.ListUsers(req.Context(), &userListForm)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrInvalidInputData):
+			r.unprocessableEntity(w, req, userListForm.Validator.FieldErrors, "Admin users")
+		default:
+			r.serverError(w, req, err, "Admin users")
+		}
+
+		return
+	}
+
+	listResp := entity.UserListResponse{
+		Users:      make([]entity.AdminUserResponse, 0, len(page.Users)),
+		NextCursor: page.NextCursor,
+	}
+
+	for _, u := range page.Users {
+		status := entity.UserStatusActive
+		if u.DisabledAt != nil {
+			status = entity.UserStatusDisabled
+		}
+
+		listResp.Users = append(listResp.Users, entity.AdminUserResponse{
+			Id:         u.Id,
+			FirstName:  u.FirstName,
+			LastName:   u.LastName,
+			Email:      u.Email,
+			Status:     status,
+			CreatedAt:  u.CreatedAt,
+			DisabledAt: u.DisabledAt,
+		})
+	}
+
+	r.sendResponse(w, req, http.StatusOK, listResp)
+}
+
+func (r *routes) adminDisableUser(w http.ResponseWriter, req *http.Request) {
+	r.adminUserAction(w, req, "disabled", r.s.Admin.DisableUser)
+}
+
+func (r *routes) adminEnableUser(w http.ResponseWriter, req *http.Request) {
+	r.adminUserAction(w, req, "enabled", r.s.Admin.EnableUser)
+}
+
+func (r *routes) adminDeleteUser(w http.ResponseWriter, req *http.Request) {
+	r.adminUserAction(w, req, "deleted", r.s.Admin.DeleteUser)
+}
+
+func (r *routes) adminUnlockUser(w http.ResponseWriter, req *http.Request) {
+	r.adminUserAction(w, req, "unlocked", r.s.Admin.UnlockUser)
+}
+
+// adminUserAction performs given admin action on user with id from request path and
+// sends empty response on success
+func (r *routes) adminUserAction(w http.ResponseWriter, req *http.Request, action string, perform func(context.Context, entity.Caller, string) error) {
+	id := r.retrieveParamId(req)
+	caller := r.callerFromContext(req)
+	location := "Admin user " + action
+
+	err := perform(req.Context(), caller, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrForbidden):
+			r.forbidden(w, req, location)
+		case errors.Is(err, entity.ErrNoRecord), errors.Is(err, entity.ErrInvalidUserId):
+			r.notFound(w, req, location)
+		default:
+			r.serverError(w, req, err, location)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	// Log admin action
+	r.l.Info("user with id '%s' %s by user with id '%d' at %s", id, action, caller.Id, time.Now().Format(timeFormat))
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"inditilla/pkg/health"
+	"net/http"
+)
+
+// healthz reports that process is alive and serves requests, dependencies are not checked
+func (r *routes) healthz(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	r.sendResponse(w, req, http.StatusOK, health.Report{Status: health.StatusOk})
+}
+
+// readyz reports whether application is ready to serve requests with result of every
+// registered check. It responds with 503 if any check fails, e.g. database is down or
+// application is shutting down
+func (r *routes) readyz(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	report := r.s.Health.Ready(req.Context())
+
+	status := http.StatusOK
+	if report.Status != health.StatusOk {
+		status = http.StatusServiceUnavailable
+	}
+
+	r.sendResponse(w, req, status, report)
+}
@@ -4,10 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"inditilla/internal/data"
 	"inditilla/internal/entity"
 	"io"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -61,6 +66,40 @@ func (r *routes) readJSON(w http.ResponseWriter, req *http.Request, target inter
 	return nil
 }
 
+// readFile reads file uploaded in multipart form 'field'. Files larger than 'maxBytes' are
+// rejected with entity.ErrImageTooLarge, other errors are custom formatted error messages
+func (r *routes) readFile(w http.ResponseWriter, req *http.Request, field string, maxBytes int) ([]byte, error) {
+	// Body holds part headers and boundaries along with the file, so some room is left for them
+	req.Body = http.MaxBytesReader(w, req.Body, int64(maxBytes)+64<<10)
+
+	file, _, err := req.FormFile(field)
+	if err != nil {
+		var maxBodyLen *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBodyLen):
+			return nil, entity.ErrImageTooLarge
+		case errors.Is(err, http.ErrNotMultipart):
+			return nil, errors.New("body must be multipart form")
+		case errors.Is(err, http.ErrMissingFile):
+			return nil, fmt.Errorf("body must contain file in form field - %q", field)
+		default:
+			return nil, errors.New("body contains badly-formed multipart form")
+		}
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, int64(maxBytes)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxBytes {
+		return nil, entity.ErrImageTooLarge
+	}
+
+	return content, nil
+}
+
 func (r *routes) logError(req *http.Request, err error) {
 	r.l.Error("error: %v, request_method: %s, request_url: %s", err, req.Method, req.URL.String())
 }
@@ -90,6 +129,10 @@ func (r *routes) invalidAuthToken(w http.ResponseWriter, req *http.Request, loca
 	r.sendErrorResponse(w, req, http.StatusUnauthorized, "invalid or missing authentication token", nil, location)
 }
 
+func (r *routes) invalidRefreshToken(w http.ResponseWriter, req *http.Request, location string) {
+	r.sendErrorResponse(w, req, http.StatusUnauthorized, "invalid or expired refresh token", nil, location)
+}
+
 func (r *routes) editConflict(w http.ResponseWriter, req *http.Request, validations map[string]string, location string) {
 	r.sendErrorResponse(w, req, http.StatusConflict, "unable to update the record due to an edit conflict, please try again", validations, location)
 }
@@ -98,6 +141,48 @@ func (r *routes) unprocessableEntity(w http.ResponseWriter, req *http.Request, v
 	r.sendErrorResponse(w, req, http.StatusUnprocessableEntity, "invalid input data", validations, location)
 }
 
+func (r *routes) forbidden(w http.ResponseWriter, req *http.Request, location string) {
+	r.sendErrorResponse(w, req, http.StatusForbidden, "you do not have permission to access this resource", nil, location)
+}
+
+func (r *routes) userDisabled(w http.ResponseWriter, req *http.Request, location string) {
+	r.sendErrorResponse(w, req, http.StatusForbidden, "user account is disabled", nil, location)
+}
+
+func (r *routes) emailNotVerified(w http.ResponseWriter, req *http.Request, location string) {
+	r.sendErrorResponse(w, req, http.StatusForbidden, "email address is not verified", nil, location)
+}
+
+// tooManyAttempts sends 429 Too Many Requests response with Retry-After header in seconds
+func (r *routes) tooManyAttempts(w http.ResponseWriter, req *http.Request, retryAfter time.Duration, location string) {
+	w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+
+	r.sendErrorResponse(w, req, http.StatusTooManyRequests, "too many failed attempts, try again later", nil, location)
+}
+
+// rateLimitExceeded sends 429 Too Many Requests response with Retry-After header in seconds
+func (r *routes) rateLimitExceeded(w http.ResponseWriter, req *http.Request, retryAfter time.Duration, location string) {
+	w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+
+	r.sendErrorResponse(w, req, http.StatusTooManyRequests, "rate limit exceeded, try again later", nil, location)
+}
+
+func (r *routes) preconditionFailed(w http.ResponseWriter, req *http.Request, location string) {
+	r.sendErrorResponse(w, req, http.StatusPreconditionFailed, "resource was modified, fetch it again and retry", nil, location)
+}
+
+func (r *routes) preconditionRequired(w http.ResponseWriter, req *http.Request, location string) {
+	r.sendErrorResponse(w, req, http.StatusPreconditionRequired, "If-Match header is required", nil, location)
+}
+
+func (r *routes) contentTooLarge(w http.ResponseWriter, req *http.Request, maxBytes int, location string) {
+	r.sendErrorResponse(w, req, http.StatusRequestEntityTooLarge, fmt.Sprintf("file must not be larger than %d bytes", maxBytes), nil, location)
+}
+
+func (r *routes) unsupportedMediaType(w http.ResponseWriter, req *http.Request, message string, location string) {
+	r.sendErrorResponse(w, req, http.StatusUnsupportedMediaType, message, nil, location)
+}
+
 func (r *routes) notFound(w http.ResponseWriter, req *http.Request, location string) {
 	r.sendErrorResponse(w, req, http.StatusNotFound, "requested resource could not be found", nil, location)
 }
@@ -106,6 +191,41 @@ func (r *routes) badRequest(w http.ResponseWriter, req *http.Request, err error,
 	r.sendErrorResponse(w, req, http.StatusBadRequest, err.Error(), nil, location)
 }
 
+// versionETag returns ETag of resource with given version
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// matchesETag reports whether If-Match header value matches ETag of given version.
+// Only strong comparison is done, as If-Match requires
+func matchesETag(ifMatch string, version int) bool {
+	etag := versionETag(version)
+
+	for _, v := range strings.Split(ifMatch, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryAfterSeconds formats given duration as whole seconds rounded up
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// clientIP returns IP address of the client request was sent from
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
 func (r *routes) serverError(w http.ResponseWriter, req *http.Request, err error, location string) {
 	r.logError(req, err)
 
@@ -148,3 +268,23 @@ func (r *routes) retrieveParamId(req *http.Request) string {
 	params := httprouter.ParamsFromContext(req.Context())
 	return params.ByName("id")
 }
+
+// claimsFromContext returns jwt claims put into request context by jwtAuth middleware
+func (r *routes) claimsFromContext(req *http.Request) *data.Claims {
+	claims, ok := req.Context().Value(claimsContextKey).(*data.Claims)
+	if !ok {
+		panic("missing claims value in request context")
+	}
+
+	return claims
+}
+
+// callerFromContext returns authenticated user put into request context by jwtAuth middleware
+func (r *routes) callerFromContext(req *http.Request) entity.Caller {
+	caller, ok := req.Context().Value(callerContextKey).(entity.Caller)
+	if !ok {
+		panic("missing caller value in request context")
+	}
+
+	return caller
+}
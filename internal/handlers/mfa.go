@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"inditilla/internal/entity"
+	"net/http"
+	"time"
+)
+
+func (r *routes) mfaTotpSetup(w http.ResponseWriter, req *http.Request) {
+	caller := r.callerFromContext(req)
+
+	setup, err := r.s.User.SetupTotp(req.Context(), caller)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrMfaEnabled):
+			r.badRequest(w, req, errors.New("mfa is already enabled"), "MFA setup")
+		default:
+			r.serverError(w, req, err, "MFA setup")
+		}
+
+		return
+	}
+
+	r.sendResponse(w, req, http.StatusOK, setup)
+}
+
+func (r *routes) mfaTotpConfirm(w http.ResponseWriter, req *http.Request) {
+	caller := r.callerFromContext(req)
+
+	var codeForm entity.MfaCodeForm
+
+	err := r.readJSON(w, req, &codeForm)
+	if err != nil {
+		r.badRequest(w, req, err, "MFA confirm")
+		return
+	}
+
+	codes, err := r.s.User.ConfirmTotp(req.Context(), caller, &codeForm)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrInvalidInputData):
+			r.unprocessableEntity(w, req, codeForm.Validator.FieldErrors, "MFA confirm")
+		case errors.Is(err, entity.ErrMfaNotSetUp):
+			r.badRequest(w, req, errors.New("mfa setup was not started"), "MFA confirm")
+		case errors.Is(err, entity.ErrMfaEnabled):
+			r.badRequest(w, req, errors.New("mfa is already enabled"), "MFA confirm")
+		case errors.Is(err, entity.ErrInvalidMfaCode):
+			r.badRequest(w, req, errors.New("invalid mfa code"), "MFA confirm")
+		default:
+			r.serverError(w, req, err, "MFA confirm")
+		}
+
+		return
+	}
+
+	r.sendResponse(w, req, http.StatusOK, entity.RecoveryCodesResponse{
+		RecoveryCodes: codes,
+	})
+
+	// Log mfa enabling
+	r.l.Info("user with id '%d' enabled mfa at %s", caller.Id, time.Now().Format(timeFormat))
+}
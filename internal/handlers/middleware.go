@@ -4,16 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"inditilla/pkg/parser"
+	"inditilla/internal/entity"
+	"inditilla/pkg/metrics"
+	"inditilla/pkg/ratelimit"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/justinas/alice"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const contextKey = "user"
+var tracer = otel.Tracer("inditilla/internal/handlers")
+
+type contextKey string
+
+const (
+	claimsContextKey = contextKey("claims")
+	callerContextKey = contextKey("caller")
+	routeContextKey  = contextKey("route")
+)
+
+// unmatchedRoute is route label of requests not served by any route, e.g. not found or
+// rejected by global rate limit
+const unmatchedRoute = "unmatched"
 
 // jwtAuth is a middleware that authenticates user by given jwt token.
 // It returns 401 Status Unauthorized if no token given or it is invalid
@@ -26,6 +45,7 @@ func (r *routes) jwtAuth(next http.Handler) http.Handler {
 
 		authHeader := req.Header.Get("Authorization")
 		if authHeader == "" {
+			r.m.TokenValidationFailed("missing")
 			r.invalidAuthToken(w, req, "Authentcation")
 			return
 		}
@@ -35,50 +55,258 @@ func (r *routes) jwtAuth(next http.Handler) http.Handler {
 		// If token is present check and validate it
 		headerParts := strings.Split(authHeader, " ")
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			r.m.TokenValidationFailed("malformed")
 			r.invalidAuthToken(w, req, "Authentcation")
 			return
 		}
 
 		if isValidToken := r.validateToken(headerParts[1]); !isValidToken {
+			r.m.TokenValidationFailed("malformed")
 			r.invalidAuthToken(w, req, "Authentication")
 			return
 		}
 
-		// Parse token with signing key from environment file
-		claims, err := parser.ParseToken(headerParts[1], []byte(os.Getenv("SIGNING_KEY")))
+		// Parse and verify token with the key authorizer selects for it
+		claims, err := r.s.User.ParseToken(headerParts[1])
 		if err != nil {
 			r.l.Error("jwtAuth: %v", err)
+			if errors.Is(err, entity.ErrExpiredAccessToken) {
+				r.m.TokenValidationFailed("expired")
+			} else {
+				r.m.TokenValidationFailed("invalid")
+			}
 			r.invalidAuthToken(w, req, "Authentcation")
 			return
 		}
 
-		// Check if such user exists
-		exists, err := r.s.User.Exists(req.Context(), claims.Email)
-		if !exists {
-			if err != nil {
-				if errors.Is(err, pgx.ErrNoRows) {
-					r.invalidAuthToken(w, req, "Authentication")
-					return
-				}
-				r.serverError(w, req, err, "Authentcation")
+		// Tokens without id, session or expiration date can not be revoked, so they are rejected
+		if claims.ID == "" || claims.SessionId == "" || claims.ExpiresAt == nil {
+			r.m.TokenValidationFailed("invalid")
+			r.invalidAuthToken(w, req, "Authentication")
+			return
+		}
+
+		// Check if token or its session was revoked (e.g. on log out)
+		revoked, err := r.s.User.IsRevoked(req.Context(), claims)
+		if err != nil {
+			r.serverError(w, req, err, "Authentication")
+			return
+		}
+		if revoked {
+			r.m.TokenValidationFailed("revoked")
+			r.invalidAuthToken(w, req, "Authentication")
+			return
+		}
+
+		// Resolve user the token was issued to (token is invalid if there is no such user)
+		caller, err := r.s.User.ResolveCaller(req.Context(), claims)
+		if err != nil {
+			if errors.Is(err, entity.ErrInvalidAccessToken) {
+				r.m.TokenValidationFailed("invalid_user")
+				r.invalidAuthToken(w, req, "Authentication")
 				return
 			}
-			r.invalidAuthToken(w, req, "Authentcation")
+			r.serverError(w, req, err, "Authentcation")
 			return
 		}
 
-		// Check if token is not expired
-		if time.Since(claims.ExpiresAt.Time) >= 0 {
-			r.invalidAuthToken(w, req, "Authentcation")
-			return
+		// Put claims and caller to request's context by custom context keys
+		ctx := context.WithValue(req.Context(), claimsContextKey, claims)
+		ctx = context.WithValue(ctx, callerContextKey, caller)
+		req = req.WithContext(ctx)
+		next.ServeHTTP(w, req)
+	})
+}
+
+// requirePermission is a middleware that lets request through only if authenticated
+// caller was granted given permission. It must be chained after jwtAuth middleware
+func (r *routes) requirePermission(permission string) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !r.callerFromContext(req).Can(permission) {
+				r.forbidden(w, req, "Authorization")
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// globalRateLimit is a middleware that limits rate of all requests of a client by global limit
+func (r *routes) globalRateLimit(next http.Handler) http.Handler {
+	if r.rl == nil {
+		return next
+	}
+
+	limit, ok := r.rl.Global()
+	if !ok {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.limitRequest(w, req, next, "global", limit)
+	})
+}
+
+// rateLimit is a middleware that limits rate of client's requests to given route by
+// the route's limit, if there is one. On authenticated routes it must be chained after
+// jwtAuth middleware, so requests are limited per user
+func (r *routes) rateLimit(route string) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		if r.rl == nil {
+			return next
+		}
+
+		limit, ok := r.rl.Route(route)
+		if !ok {
+			return next
 		}
 
-		// Put claims to request's context by custom context key
-		req = req.WithContext(context.WithValue(req.Context(), contextKey, claims))
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			r.limitRequest(w, req, next, route, limit)
+		})
+	}
+}
+
+// limitRequest takes token from the client's bucket of given scope and serves request
+// if it was allowed. If limiter store fails, request is served anyway - broken shared
+// store should not take whole service down
+func (r *routes) limitRequest(w http.ResponseWriter, req *http.Request, next http.Handler, scope string, limit ratelimit.Limit) {
+	res, err := r.rl.Take(req.Context(), scope+"|"+rateLimitKey(req), limit)
+	if err != nil {
+		r.l.Error("rate limit: %v", err)
 		next.ServeHTTP(w, req)
+		return
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("RateLimit-Reset", retryAfterSeconds(res.Reset))
+
+	if !res.Allowed {
+		r.rateLimitExceeded(w, req, res.RetryAfter, "Rate limit")
+		return
+	}
+
+	next.ServeHTTP(w, req)
+}
+
+// rateLimitKey returns key client's requests are limited by - user id if request
+// is authenticated, client IP otherwise
+func rateLimitKey(req *http.Request) string {
+	if caller, ok := req.Context().Value(callerContextKey).(entity.Caller); ok {
+		return "user:" + strconv.Itoa(caller.Id)
+	}
+
+	return "ip:" + clientIP(req)
+}
+
+// instrument is a middleware that records count and duration of requests in metrics by
+// pattern of the route that served request (not by raw path, e.g. /v1/user/profile/:id)
+// and response status. It must go right after trace, so responses of other middleware are recorded
+func (r *routes) instrument(next http.Handler) http.Handler {
+	if r.m == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+
+		req, route := withRoute(req)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, req)
+
+		r.m.ObserveRequest(req.Method, *route, rec.status, time.Since(start))
+	})
+}
+
+// trace is a middleware that records request as span named by method and pattern of the
+// route that served it. Trace context of the caller is taken from W3C traceparent header,
+// so the span continues caller's trace. It must be the first one, so spans of services
+// and repositories are nested in it
+func (r *routes) trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// Method outside of the standard set is named as other one, so clients could not make
+		// span names of their own. The method itself is kept in attribute
+		method := metrics.Method(req.Method)
+
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(method),
+			semconv.URLPath(req.URL.Path),
+			semconv.ClientAddress(clientIP(req)),
+			semconv.UserAgentOriginal(req.UserAgent()),
+		))
+		defer span.End()
+
+		if method != req.Method {
+			span.SetAttributes(semconv.HTTPRequestMethodOriginal(req.Method))
+		}
+
+		req, route := withRoute(req.WithContext(ctx))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, req)
+
+		span.SetName(method + " " + *route)
+		span.SetAttributes(semconv.HTTPRoute(*route), semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
 	})
 }
 
+// withRoute returns request with holder of route pattern in its context, matchedRoute sets
+// pattern of the route serving request into it. Holder put by outer middleware is reused
+func withRoute(req *http.Request) (*http.Request, *string) {
+	if route, ok := req.Context().Value(routeContextKey).(*string); ok {
+		return req, route
+	}
+
+	route := unmatchedRoute
+	return req.WithContext(context.WithValue(req.Context(), routeContextKey, &route)), &route
+}
+
+// matchedRoute sets pattern of the route serving request for instrument and trace middleware
+func matchedRoute(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if route, ok := req.Context().Value(routeContextKey).(*string); ok {
+			*route = pattern
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// statusRecorder is response writer keeping status of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach underlying response writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
 func (r *routes) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		defer func() {
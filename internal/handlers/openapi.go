@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"inditilla/internal/entity"
+	"inditilla/pkg/health"
+	"inditilla/pkg/jwks"
+	"inditilla/pkg/openapi"
+	"net/http"
+	"strconv"
+)
+
+// operation documents route in OpenAPI specification. Responses are response bodies by
+// status, nil for responses without body. Upload is form field of file uploaded as
+// multipart form, instead of JSON request. Errors are statuses of error responses, 401
+// of secured routes and 429, 500 of all routes are added to them
+type operation struct {
+	summary   string
+	tag       string
+	secured   bool
+	query     interface{}
+	request   interface{}
+	upload    string
+	responses map[int]interface{}
+	errors    []int
+}
+
+// operations document routes by route's 'METHOD /path'. Every registered route must be
+// documented here, otherwise it is left out of specification (and tests fail)
+var operations = map[string]operation{
+	"POST /v1/user/signup": {
+		summary:   "Sign up new user",
+		tag:       "user",
+		request:   entity.UserSignupForm{},
+		responses: map[int]interface{}{http.StatusOK: entity.SignupResponse{}},
+		errors:    []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+	},
+	"POST /v1/user/login": {
+		summary: "Log in with email and password, MFA challenge is returned with 202 if user has MFA enabled",
+		tag:     "user",
+		request: entity.UserLoginForm{},
+		responses: map[int]interface{}{
+			http.StatusCreated:  entity.LoginResponse{},
+			http.StatusAccepted: entity.MfaChallengeResponse{},
+		},
+		errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity},
+	},
+	"POST /v1/user/login/mfa": {
+		summary:   "Complete log in with TOTP or recovery code",
+		tag:       "user",
+		request:   entity.MfaLoginForm{},
+		responses: map[int]interface{}{http.StatusCreated: entity.LoginResponse{}},
+		errors:    []int{http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity},
+	},
+	"POST /v1/user/token/refresh": {
+		summary:   "Exchange refresh token for new token pair",
+		tag:       "user",
+		request:   entity.RefreshTokenForm{},
+		responses: map[int]interface{}{http.StatusCreated: entity.LoginResponse{}},
+		errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusUnprocessableEntity},
+	},
+	"POST /v1/user/password/forgot": {
+		summary:   "Send password reset token to email",
+		tag:       "user",
+		request:   entity.ForgotPasswordForm{},
+		responses: map[int]interface{}{http.StatusAccepted: entity.MessageResponse{}},
+		errors:    []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+	},
+	"POST /v1/user/password/reset": {
+		summary:   "Set new password with reset token",
+		tag:       "user",
+		request:   entity.ResetPasswordForm{},
+		responses: map[int]interface{}{http.StatusNoContent: nil},
+		errors:    []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+	},
+	"POST /v1/user/email/verify": {
+		summary:   "Verify email with token",
+		tag:       "user",
+		request:   entity.TokenForm{},
+		responses: map[int]interface{}{http.StatusNoContent: nil},
+		errors:    []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+	},
+	"POST /v1/user/email/verify/resend": {
+		summary:   "Send new email verification token",
+		tag:       "user",
+		request:   entity.EmailForm{},
+		responses: map[int]interface{}{http.StatusAccepted: entity.MessageResponse{}},
+		errors:    []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+	},
+	"GET /.well-known/jwks.json": {
+		summary:   "Public keys access tokens are signed with",
+		tag:       "keys",
+		responses: map[int]interface{}{http.StatusOK: jwks.Set{}},
+	},
+	"GET /openapi.json": {
+		summary:   "This specification",
+		tag:       "docs",
+		responses: map[int]interface{}{http.StatusOK: nil},
+	},
+	"GET /healthz": {
+		summary:   "Liveness probe, process is alive",
+		tag:       "health",
+		responses: map[int]interface{}{http.StatusOK: health.Report{}},
+	},
+	"GET /readyz": {
+		summary: "Readiness probe, result of every check (database, migrations, shutdown) is reported",
+		tag:     "health",
+		responses: map[int]interface{}{
+			http.StatusOK:                 health.Report{},
+			http.StatusServiceUnavailable: health.Report{},
+		},
+	},
+	"GET /v1/user/profile/:id": {
+		summary:   "Get user profile, profile's version is returned in ETag header",
+		tag:       "profile",
+		secured:   true,
+		responses: map[int]interface{}{http.StatusOK: entity.UserProfileResponse{}},
+		errors:    []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	"PATCH /v1/user/profile/:id": {
+		summary:   "Update user profile, conditional on If-Match header if it is given",
+		tag:       "profile",
+		secured:   true,
+		request:   entity.UserUpdateForm{},
+		responses: map[int]interface{}{http.StatusOK: entity.UserProfileResponse{}},
+		errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict,
+			http.StatusPreconditionFailed, http.StatusUnprocessableEntity, http.StatusPreconditionRequired},
+	},
+	"PUT /v1/user/profile/:id/avatar": {
+		summary:   "Upload avatar image (JPEG, PNG or WebP) as multipart form file 'avatar', square thumbnails are made of its center",
+		tag:       "profile",
+		secured:   true,
+		upload:    "avatar",
+		responses: map[int]interface{}{http.StatusOK: entity.UserProfileResponse{}},
+		errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict,
+			http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity},
+	},
+	"POST /v1/user/logout": {
+		summary:   "Log out current session",
+		tag:       "user",
+		secured:   true,
+		responses: map[int]interface{}{http.StatusNoContent: nil},
+	},
+	"POST /v1/user/logout-all": {
+		summary:   "Log out all sessions of the user",
+		tag:       "user",
+		secured:   true,
+		responses: map[int]interface{}{http.StatusNoContent: nil},
+	},
+	"POST /v1/user/mfa/totp/setup": {
+		summary:   "Start TOTP enrollment",
+		tag:       "mfa",
+		secured:   true,
+		responses: map[int]interface{}{http.StatusOK: entity.TotpSetupResponse{}},
+		errors:    []int{http.StatusBadRequest},
+	},
+	"POST /v1/user/mfa/totp/confirm": {
+		summary:   "Enable TOTP with code from authenticator app",
+		tag:       "mfa",
+		secured:   true,
+		request:   entity.MfaCodeForm{},
+		responses: map[int]interface{}{http.StatusOK: entity.RecoveryCodesResponse{}},
+		errors:    []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+	},
+	"GET /v1/admin/users": {
+		summary:   "List users",
+		tag:       "admin",
+		secured:   true,
+		query:     entity.UserListForm{},
+		responses: map[int]interface{}{http.StatusOK: entity.UserListResponse{}},
+		errors:    []int{http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity},
+	},
+	"POST /v1/admin/users/:id/disable": {
+		summary:   "Disable user and revoke user's sessions",
+		tag:       "admin",
+		secured:   true,
+		responses: map[int]interface{}{http.StatusNoContent: nil},
+		errors:    []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	"POST /v1/admin/users/:id/enable": {
+		summary:   "Enable disabled user",
+		tag:       "admin",
+		secured:   true,
+		responses: map[int]interface{}{http.StatusNoContent: nil},
+		errors:    []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	"POST /v1/admin/users/:id/unlock": {
+		summary:   "Unlock account locked out after failed log in attempts",
+		tag:       "admin",
+		secured:   true,
+		responses: map[int]interface{}{http.StatusNoContent: nil},
+		errors:    []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	"DELETE /v1/admin/users/:id": {
+		summary:   "Delete user",
+		tag:       "admin",
+		secured:   true,
+		responses: map[int]interface{}{http.StatusNoContent: nil},
+		errors:    []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	"GET /v1/admin/roles": {
+		summary:   "List roles with their permissions",
+		tag:       "admin",
+		secured:   true,
+		responses: map[int]interface{}{http.StatusOK: entity.RolesResponse{}},
+		errors:    []int{http.StatusForbidden},
+	},
+	"PUT /v1/admin/users/:id/roles/:role": {
+		summary:   "Assign role to user",
+		tag:       "admin",
+		secured:   true,
+		responses: map[int]interface{}{http.StatusNoContent: nil},
+		errors:    []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	"DELETE /v1/admin/users/:id/roles/:role": {
+		summary:   "Remove role from user and revoke user's sessions",
+		tag:       "admin",
+		secured:   true,
+		responses: map[int]interface{}{http.StatusNoContent: nil},
+		errors:    []int{http.StatusForbidden, http.StatusNotFound},
+	},
+}
+
+// buildOpenAPI builds OpenAPI specification of registered routes
+func (r *routes) buildOpenAPI() {
+	doc := openapi.New("inditilla", "v1")
+	doc.Components.SecuritySchemes["bearer"] = openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+
+	for _, rt := range r.table {
+		op, ok := operations[rt.method+" "+rt.path]
+		if !ok {
+			continue
+		}
+
+		spec := &openapi.Operation{
+			Summary:   op.summary,
+			Tags:      []string{op.tag},
+			Responses: make(map[string]*openapi.Response),
+		}
+
+		if op.secured {
+			spec.Security = []map[string][]string{{"bearer": {}}}
+		}
+		if op.query != nil {
+			spec.Parameters = doc.QueryParameters(op.query)
+		}
+		if op.request != nil {
+			spec.RequestBody = &openapi.RequestBody{Required: true, Content: doc.JSON(op.request)}
+		}
+		if op.upload != "" {
+			spec.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.File(op.upload)}
+		}
+
+		for status, body := range op.responses {
+			resp := &openapi.Response{Description: http.StatusText(status)}
+			if body != nil {
+				resp.Content = doc.JSON(body)
+			}
+			spec.Responses[strconv.Itoa(status)] = resp
+		}
+
+		errorStatuses := append([]int{http.StatusTooManyRequests, http.StatusInternalServerError}, op.errors...)
+		if op.secured {
+			errorStatuses = append(errorStatuses, http.StatusUnauthorized)
+		}
+
+		for _, status := range errorStatuses {
+			spec.Responses[strconv.Itoa(status)] = &openapi.Response{
+				Description: http.StatusText(status),
+				Content:     doc.JSON(entity.ErrorResponse{}),
+			}
+		}
+
+		doc.Add(rt.method, rt.path, spec)
+	}
+
+	r.spec = doc
+}
+
+// openAPI serves OpenAPI specification of the API
+func (r *routes) openAPI(w http.ResponseWriter, req *http.Request) {
+	r.sendResponse(w, req, http.StatusOK, r.spec)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"inditilla/pkg/logger"
+	"inditilla/pkg/openapi"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/form/v4"
+)
+
+func TestOpenAPIDocumentsAllRoutes(t *testing.T) {
+	r := &routes{
+		l:  logger.NewWriter(io.Discard),
+		fd: form.NewDecoder(),
+	}
+	h := r.handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusOK)
+	}
+
+	var doc openapi.Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode specification: %v", err)
+	}
+
+	registered := make(map[string]bool)
+	for _, rt := range r.table {
+		key := rt.method + " " + rt.path
+		registered[key] = true
+
+		item, ok := doc.Paths[specPath(rt.path)]
+		if !ok || (*item)[strings.ToLower(rt.method)] == nil {
+			t.Errorf("route %s is not documented, add it to operations", key)
+		}
+	}
+
+	for key := range operations {
+		if !registered[key] {
+			t.Errorf("operation %s documents route that is not registered", key)
+		}
+	}
+}
+
+func TestOpenAPIReferencesDefinedSchemas(t *testing.T) {
+	r := &routes{
+		l:  logger.NewWriter(io.Discard),
+		fd: form.NewDecoder(),
+	}
+	r.handler()
+
+	spec, err := json.Marshal(r.spec)
+	if err != nil {
+		t.Fatalf("encode specification: %v", err)
+	}
+
+	for _, name := range []string{"UserSignupForm", "UserLoginForm", "UserUpdateForm", "SignupResponse", "LoginResponse", "UserProfileResponse", "ErrorResponse"} {
+		if _, ok := r.spec.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s is not defined", name)
+		}
+	}
+
+	// Every reference must point to defined schema
+	const prefix = `"$ref":"#/components/schemas/`
+	for _, part := range strings.Split(string(spec), prefix)[1:] {
+		name := part[:strings.IndexByte(part, '"')]
+		if _, ok := r.spec.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s is referenced but not defined", name)
+		}
+	}
+}
+
+// specPath converts httprouter path to OpenAPI path
+func specPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
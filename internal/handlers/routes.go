@@ -1,9 +1,17 @@
 package handlers
 
 import (
+	"inditilla/internal/entity"
 	"inditilla/internal/service"
 	"inditilla/pkg/logger"
+	"inditilla/pkg/metrics"
+	"inditilla/pkg/openapi"
+	"inditilla/pkg/ratelimit"
+	"inditilla/pkg/static"
+	"inditilla/web"
+	"io/fs"
 	"net/http"
+	"strings"
 
 	"github.com/go-playground/form/v4"
 	"github.com/julienschmidt/httprouter"
@@ -14,24 +22,145 @@ type routes struct {
 	l  logger.ILogger
 	s  *service.Services
 	fd *form.Decoder
+	rl *ratelimit.Limiter
+	m  *metrics.Metrics
+
+	requireIfMatch bool
+
+	static    fs.FS
+	staticURL string
+
+	// table lists registered routes, OpenAPI specification is built from it
+	table []route
+	spec  *openapi.Document
 }
 
-func NewRouter(logger logger.ILogger, services *service.Services) http.Handler {
-	router := httprouter.New()
+// route is registered route's method and path in httprouter format
+type route struct {
+	method string
+	path   string
+}
 
+// Options are optional settings of the router
+type Options struct {
+	// Limiter limits request rates, requests are not limited if it is nil
+	Limiter *ratelimit.Limiter
+
+	// Metrics records requests and authentication events, nothing is recorded if it is nil
+	Metrics *metrics.Metrics
+
+	// RequireIfMatch makes profile updates without If-Match header rejected
+	RequireIfMatch bool
+
+	// Static files are served under StaticURL path, they are not served if it is nil
+	// or StaticURL is not a path (e.g. files are served by CDN)
+	Static    fs.FS
+	StaticURL string
+}
+
+// NewRouter returns application's http handler
+func NewRouter(logger logger.ILogger, services *service.Services, opts Options) http.Handler {
 	r := &routes{
-		l: logger,
-		s: services,
+		l:  logger,
+		s:  services,
+		fd: form.NewDecoder(),
+		rl: opts.Limiter,
+		m:  opts.Metrics,
+
+		requireIfMatch: opts.RequireIfMatch,
+
+		static:    opts.Static,
+		staticURL: opts.StaticURL,
 	}
 
-	router.HandlerFunc(http.MethodPost, "/v1/user/signup", r.userSignup)
-	router.HandlerFunc(http.MethodPost, "/v1/user/login", r.userLogin)
+	return r.handler()
+}
+
+// handler registers all routes and returns router wrapped with standard middleware
+func (r *routes) handler() http.Handler {
+	router := httprouter.New()
+
+	// handle registers route's handler with given chain followed by rate limit of the route
+	handle := func(method, path string, chain alice.Chain, h http.HandlerFunc) {
+		router.Handler(method, path, matchedRoute(path, chain.Append(r.rateLimit(method+" "+path)).ThenFunc(h)))
+		r.table = append(r.table, route{method: method, path: path})
+	}
+
+	public := alice.New()
+
+	handle(http.MethodPost, "/v1/user/signup", public, r.userSignup)
+	handle(http.MethodPost, "/v1/user/login", public, r.userLogin)
+	handle(http.MethodPost, "/v1/user/login/mfa", public, r.userLoginMfa)
+	handle(http.MethodPost, "/v1/user/token/refresh", public, r.userTokenRefresh)
+	handle(http.MethodPost, "/v1/user/password/forgot", public, r.userPasswordForgot)
+	handle(http.MethodPost, "/v1/user/password/reset", public, r.userPasswordReset)
+	handle(http.MethodPost, "/v1/user/email/verify", public, r.userEmailVerify)
+	handle(http.MethodPost, "/v1/user/email/verify/resend", public, r.userEmailVerifyResend)
+	handle(http.MethodGet, "/.well-known/jwks.json", public, r.jwks)
+	handle(http.MethodGet, "/openapi.json", public, r.openAPI)
+
+	// probe registers health probe, probes are not limited by global rate limit, so
+	// orchestrator polling them is not throttled together with clients
+	probes := make(map[string]bool)
+	probe := func(path string, h http.HandlerFunc) {
+		handle(http.MethodGet, path, public, h)
+		probes[path] = true
+	}
+
+	probe("/healthz", r.healthz)
+	probe("/readyz", r.readyz)
 
 	secured := alice.New(r.jwtAuth)
 
-	router.Handler(http.MethodGet, "/v1/user/profile/:id", secured.ThenFunc(r.userProfile))
-	router.Handler(http.MethodPatch, "/v1/user/profile/:id", secured.ThenFunc(r.userUpdate))
+	handle(http.MethodGet, "/v1/user/profile/:id", secured, r.userProfile)
+	handle(http.MethodPatch, "/v1/user/profile/:id", secured, r.userUpdate)
+	handle(http.MethodPut, "/v1/user/profile/:id/avatar", secured, r.userAvatarUpdate)
+	handle(http.MethodPost, "/v1/user/logout", secured, r.userLogout)
+	handle(http.MethodPost, "/v1/user/logout-all", secured, r.userLogoutAll)
+	handle(http.MethodPost, "/v1/user/mfa/totp/setup", secured, r.mfaTotpSetup)
+	handle(http.MethodPost, "/v1/user/mfa/totp/confirm", secured, r.mfaTotpConfirm)
+
+	usersReader := secured.Append(r.requirePermission(entity.PermUsersRead))
+	usersManager := secured.Append(r.requirePermission(entity.PermUsersManage))
+	rolesManager := secured.Append(r.requirePermission(entity.PermRolesManage))
+
+	handle(http.MethodGet, "/v1/admin/users", usersReader, r.adminUsers)
+	handle(http.MethodPost, "/v1/admin/users/:id/disable", usersManager, r.adminDisableUser)
+	handle(http.MethodPost, "/v1/admin/users/:id/enable", usersManager, r.adminEnableUser)
+	handle(http.MethodPost, "/v1/admin/users/:id/unlock", usersManager, r.adminUnlockUser)
+	handle(http.MethodDelete, "/v1/admin/users/:id", usersManager, r.adminDeleteUser)
+
+	handle(http.MethodGet, "/v1/admin/roles", rolesManager, r.adminRoles)
+	handle(http.MethodPut, "/v1/admin/users/:id/roles/:role", rolesManager, r.adminAssignRole)
+	handle(http.MethodDelete, "/v1/admin/users/:id/roles/:role", rolesManager, r.adminRemoveRole)
+
+	// Specification describes routes registered above, so it is built last
+	r.buildOpenAPI()
+
+	// Web UI and static files are not part of the API, so they are left out of route table
+	files := func(prefix string, h http.Handler) {
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			router.Handler(method, prefix+"/*filepath", matchedRoute(prefix+"/*filepath", http.StripPrefix(prefix, h)))
+		}
+	}
+
+	// UI is revalidated on every load, so new version is picked up right after deploy
+	files("/ui", static.New(web.UI(), "no-cache"))
+	router.Handler(http.MethodGet, "/", matchedRoute("/", http.RedirectHandler("/ui/", http.StatusFound)))
+
+	if r.static != nil && strings.HasPrefix(r.staticURL, "/") {
+		files(strings.TrimSuffix(r.staticURL, "/"), static.New(r.static, "public, max-age=3600"))
+	}
+
+	limited := r.globalRateLimit(router)
+
+	standard := alice.New(r.trace, r.instrument, r.recoverPanic, secureHeaders)
+	return standard.ThenFunc(func(w http.ResponseWriter, req *http.Request) {
+		if probes[req.URL.Path] {
+			router.ServeHTTP(w, req)
+			return
+		}
 
-	standard := alice.New(r.recoverPanic, secureHeaders)
-	return standard.Then(router)
+		limited.ServeHTTP(w, req)
+	})
 }
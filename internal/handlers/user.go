@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"inditilla/internal/entity"
+	"inditilla/pkg/metrics"
 	"net/http"
 	"time"
 )
@@ -33,6 +34,8 @@ func (r *routes) userSignup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	r.m.Signup()
+
 	signupResp := entity.SignupResponse{
 		UserID: id,
 	}
@@ -52,13 +55,27 @@ func (r *routes) userLogin(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	token, err := r.s.User.SignIn(req.Context(), &userLoginForm)
+	userLoginForm.IP = clientIP(req)
+
+	result, err := r.s.User.SignIn(req.Context(), &userLoginForm)
 	if err != nil {
+		var retryErr *entity.RetryError
+
 		switch {
+		case errors.As(err, &retryErr):
+			r.m.Login(metrics.LoginBlocked)
+			r.tooManyAttempts(w, req, retryErr.RetryAfter, "User login")
 		case errors.Is(err, entity.ErrInvalidInputData):
 			r.unprocessableEntity(w, req, userLoginForm.Validator.FieldErrors, "User login")
 		case errors.Is(err, entity.ErrInvalidCredentials):
+			r.m.Login(metrics.LoginFailure)
 			r.badRequest(w, req, err, "User login")
+		case errors.Is(err, entity.ErrUserDisabled):
+			r.m.Login(metrics.LoginFailure)
+			r.userDisabled(w, req, "User login")
+		case errors.Is(err, entity.ErrEmailNotVerified):
+			r.m.Login(metrics.LoginFailure)
+			r.emailNotVerified(w, req, "User login")
 		default:
 			r.serverError(w, req, err, "User login")
 		}
@@ -66,8 +83,19 @@ func (r *routes) userLogin(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Log in is completed with second factor by userLoginMfa
+	if result.MfaToken != "" {
+		r.sendResponse(w, req, http.StatusAccepted, entity.MfaChallengeResponse{
+			MfaToken: result.MfaToken,
+		})
+		return
+	}
+
+	r.m.Login(metrics.LoginSuccess)
+
 	loginResp := entity.LoginResponse{
-		AccessToken: token,
+		AccessToken:  result.Tokens.AccessToken,
+		RefreshToken: result.Tokens.RefreshToken,
 	}
 
 	r.sendResponse(w, req, http.StatusCreated, loginResp)
@@ -76,12 +104,240 @@ func (r *routes) userLogin(w http.ResponseWriter, req *http.Request) {
 	r.l.Info("user with email '%s' logged at %s", userLoginForm.Email, time.Now().Format(timeFormat))
 }
 
+func (r *routes) userLoginMfa(w http.ResponseWriter, req *http.Request) {
+	var mfaForm entity.MfaLoginForm
+
+	err := r.readJSON(w, req, &mfaForm)
+	if err != nil {
+		r.badRequest(w, req, err, "User login mfa")
+		return
+	}
+
+	mfaForm.IP = clientIP(req)
+
+	tokens, err := r.s.User.SignInMfa(req.Context(), &mfaForm)
+	if err != nil {
+		var retryErr *entity.RetryError
+
+		switch {
+		case errors.As(err, &retryErr):
+			r.m.Login(metrics.LoginBlocked)
+			r.tooManyAttempts(w, req, retryErr.RetryAfter, "User login mfa")
+		case errors.Is(err, entity.ErrInvalidInputData):
+			r.unprocessableEntity(w, req, mfaForm.Validator.FieldErrors, "User login mfa")
+		case errors.Is(err, entity.ErrInvalidToken):
+			r.badRequest(w, req, errors.New("invalid or expired mfa token"), "User login mfa")
+		case errors.Is(err, entity.ErrInvalidMfaCode):
+			r.m.Login(metrics.LoginFailure)
+			r.badRequest(w, req, errors.New("invalid mfa code, log in again"), "User login mfa")
+		case errors.Is(err, entity.ErrUserDisabled):
+			r.m.Login(metrics.LoginFailure)
+			r.userDisabled(w, req, "User login mfa")
+		default:
+			r.serverError(w, req, err, "User login mfa")
+		}
+
+		return
+	}
+
+	r.m.Login(metrics.LoginSuccess)
+
+	loginResp := entity.LoginResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}
+
+	r.sendResponse(w, req, http.StatusCreated, loginResp)
+
+	// Log user log in
+	r.l.Info("user logged with mfa at %s", time.Now().Format(timeFormat))
+}
+
+func (r *routes) userTokenRefresh(w http.ResponseWriter, req *http.Request) {
+	var refreshForm entity.RefreshTokenForm
+
+	err := r.readJSON(w, req, &refreshForm)
+	if err != nil {
+		r.badRequest(w, req, err, "Token refresh")
+		return
+	}
+
+	tokens, err := r.s.User.Refresh(req.Context(), &refreshForm)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrInvalidInputData):
+			r.unprocessableEntity(w, req, refreshForm.Validator.FieldErrors, "Token refresh")
+		case errors.Is(err, entity.ErrInvalidRefreshToken):
+			r.invalidRefreshToken(w, req, "Token refresh")
+		case errors.Is(err, entity.ErrRefreshTokenReuse):
+			r.l.Warn("refresh token reuse detected, token family revoked at %s", time.Now().Format(timeFormat))
+			r.invalidRefreshToken(w, req, "Token refresh")
+		default:
+			r.serverError(w, req, err, "Token refresh")
+		}
+
+		return
+	}
+
+	loginResp := entity.LoginResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}
+
+	r.sendResponse(w, req, http.StatusCreated, loginResp)
+}
+
+func (r *routes) userLogout(w http.ResponseWriter, req *http.Request) {
+	claims := r.claimsFromContext(req)
+
+	if err := r.s.User.Logout(req.Context(), claims); err != nil {
+		r.serverError(w, req, err, "User logout")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	// Log user log out
+	r.l.Info("user with id '%d' logged out at %s", claims.UserId, time.Now().Format(timeFormat))
+}
+
+func (r *routes) userLogoutAll(w http.ResponseWriter, req *http.Request) {
+	claims := r.claimsFromContext(req)
+
+	if err := r.s.User.LogoutAll(req.Context(), claims); err != nil {
+		r.serverError(w, req, err, "User logout all")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	// Log user log out from all sessions
+	r.l.Info("user with id '%d' logged out from all sessions at %s", claims.UserId, time.Now().Format(timeFormat))
+}
+
+func (r *routes) userPasswordForgot(w http.ResponseWriter, req *http.Request) {
+	var forgotForm entity.ForgotPasswordForm
+
+	err := r.readJSON(w, req, &forgotForm)
+	if err != nil {
+		r.badRequest(w, req, err, "Password forgot")
+		return
+	}
+
+	err = r.s.User.ForgotPassword(req.Context(), &forgotForm)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrInvalidInputData):
+			r.unprocessableEntity(w, req, forgotForm.Validator.FieldErrors, "Password forgot")
+		default:
+			r.serverError(w, req, err, "Password forgot")
+		}
+
+		return
+	}
+
+	// Response is the same whether such user exists or not
+	r.sendResponse(w, req, http.StatusAccepted, entity.MessageResponse{
+		Message: "if account with such email exists, password reset instructions were sent to it",
+	})
+}
+
+func (r *routes) userPasswordReset(w http.ResponseWriter, req *http.Request) {
+	var resetForm entity.ResetPasswordForm
+
+	err := r.readJSON(w, req, &resetForm)
+	if err != nil {
+		r.badRequest(w, req, err, "Password reset")
+		return
+	}
+
+	err = r.s.User.ResetPassword(req.Context(), &resetForm)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrInvalidInputData):
+			r.unprocessableEntity(w, req, resetForm.Validator.FieldErrors, "Password reset")
+		case errors.Is(err, entity.ErrInvalidToken):
+			r.badRequest(w, req, errors.New("invalid or expired password reset token"), "Password reset")
+		default:
+			r.serverError(w, req, err, "Password reset")
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	// Log password reset
+	r.l.Info("password reset at %s", time.Now().Format(timeFormat))
+}
+
+func (r *routes) userEmailVerify(w http.ResponseWriter, req *http.Request) {
+	var tokenForm entity.TokenForm
+
+	err := r.readJSON(w, req, &tokenForm)
+	if err != nil {
+		r.badRequest(w, req, err, "Email verify")
+		return
+	}
+
+	err = r.s.User.VerifyEmail(req.Context(), &tokenForm)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrInvalidInputData):
+			r.unprocessableEntity(w, req, tokenForm.Validator.FieldErrors, "Email verify")
+		case errors.Is(err, entity.ErrInvalidToken):
+			r.badRequest(w, req, errors.New("invalid or expired email verification token"), "Email verify")
+		case errors.Is(err, entity.ErrDuplicateEmail):
+			r.badRequest(w, req, err, "Email verify")
+		default:
+			r.serverError(w, req, err, "Email verify")
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	// Log email verification
+	r.l.Info("email verified at %s", time.Now().Format(timeFormat))
+}
+
+func (r *routes) userEmailVerifyResend(w http.ResponseWriter, req *http.Request) {
+	var emailForm entity.EmailForm
+
+	err := r.readJSON(w, req, &emailForm)
+	if err != nil {
+		r.badRequest(w, req, err, "Email verify resend")
+		return
+	}
+
+	err = r.s.User.ResendVerification(req.Context(), &emailForm)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrInvalidInputData):
+			r.unprocessableEntity(w, req, emailForm.Validator.FieldErrors, "Email verify resend")
+		default:
+			r.serverError(w, req, err, "Email verify resend")
+		}
+
+		return
+	}
+
+	// Response is the same whether such unverified user exists or not
+	r.sendResponse(w, req, http.StatusAccepted, entity.MessageResponse{
+		Message: "if account with such unverified email exists, verification instructions were sent to it",
+	})
+}
+
 func (r *routes) userProfile(w http.ResponseWriter, req *http.Request) {
 	id := r.retrieveParamId(req)
+	caller := r.callerFromContext(req)
 
-	user, err := r.s.User.GetById(req.Context(), id)
+	user, err := r.s.User.GetById(req.Context(), caller, id)
 	if err != nil {
 		switch {
+		case errors.Is(err, entity.ErrForbidden):
+			r.forbidden(w, req, "User profile")
 		case errors.Is(err, entity.ErrNoRecord):
 			r.notFound(w, req, "User profile")
 		case errors.Is(err, entity.ErrInvalidUserId):
@@ -93,21 +349,20 @@ func (r *routes) userProfile(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	userProfile := entity.UserProfileResponse{
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Email:     user.Email,
-	}
+	w.Header().Set("ETag", versionETag(user.Version))
 
-	r.sendResponse(w, req, http.StatusOK, userProfile)
+	r.sendResponse(w, req, http.StatusOK, profileResponse(user))
 }
 
 func (r *routes) userUpdate(w http.ResponseWriter, req *http.Request) {
 	id := r.retrieveParamId(req)
+	caller := r.callerFromContext(req)
 
-	user, err := r.s.User.GetById(req.Context(), id)
+	user, err := r.s.User.GetById(req.Context(), caller, id)
 	if err != nil {
 		switch {
+		case errors.Is(err, entity.ErrForbidden):
+			r.forbidden(w, req, "User update")
 		case errors.Is(err, entity.ErrNoRecord):
 			r.notFound(w, req, "User update")
 		case errors.Is(err, entity.ErrInvalidUserId):
@@ -119,13 +374,20 @@ func (r *routes) userUpdate(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	var input struct {
-		FirstName *string `json:"firstName"`
-		LastName  *string `json:"lastName"`
-		Email     *string `json:"email"`
-		Password  *string `json:"password"`
+	// Update is conditional on profile version client has seen, if client gives it
+	ifMatch := req.Header.Get("If-Match")
+	if ifMatch != "" {
+		if !matchesETag(ifMatch, user.Version) {
+			r.preconditionFailed(w, req, "User update")
+			return
+		}
+	} else if r.requireIfMatch {
+		r.preconditionRequired(w, req, "User update")
+		return
 	}
 
+	var input entity.UserUpdateForm
+
 	err = r.readJSON(w, req, &input)
 	if err != nil {
 		r.badRequest(w, req, err, "User update")
@@ -153,9 +415,37 @@ func (r *routes) userUpdate(w http.ResponseWriter, req *http.Request) {
 		user.Password = *input.Password
 	}
 
-	err = r.s.User.Update(req.Context(), &user, isPasswordChanged)
+	// Optional profile fields are logged by name only, they may hold personal data
+	profileFields := []struct {
+		name  string
+		input *string
+		field *string
+	}{
+		{"displayName", input.DisplayName, &user.DisplayName},
+		{"bio", input.Bio, &user.Bio},
+		{"phone", input.Phone, &user.Phone},
+		{"dateOfBirth", input.DateOfBirth, &user.DateOfBirth},
+		{"locale", input.Locale, &user.Locale},
+		{"timezone", input.Timezone, &user.Timezone},
+		{"website", input.Website, &user.Website},
+	}
+
+	for _, f := range profileFields {
+		if f.input != nil {
+			updatedFieldsLog = append(updatedFieldsLog, "updated "+f.name)
+			*f.field = *f.input
+		}
+	}
+
+	err = r.s.User.Update(req.Context(), caller, &user, isPasswordChanged)
 	if err != nil {
 		switch {
+		case errors.Is(err, entity.ErrForbidden):
+			r.forbidden(w, req, "User update")
+		case errors.Is(err, entity.ErrDuplicateEmail):
+			r.badRequest(w, req, err, "User update")
+		case errors.Is(err, entity.ErrEditConflict) && ifMatch != "":
+			r.preconditionFailed(w, req, "User update")
 		case errors.Is(err, entity.ErrEditConflict):
 			r.editConflict(w, req, user.FieldErrors, "User update")
 		case errors.Is(err, entity.ErrInvalidInputData):
@@ -167,14 +457,79 @@ func (r *routes) userUpdate(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	userProfile := entity.UserProfileResponse{
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Email:     user.Email,
-	}
+	w.Header().Set("ETag", versionETag(user.Version))
 
-	r.sendResponse(w, req, http.StatusOK, userProfile)
+	r.sendResponse(w, req, http.StatusOK, profileResponse(user))
 
 	// Log user profile changes
 	r.l.Info("user with id '%d' made next changes at %s in profile page: %v", user.Id, time.Now().Format(timeFormat), updatedFieldsLog)
 }
+
+func (r *routes) userAvatarUpdate(w http.ResponseWriter, req *http.Request) {
+	id := r.retrieveParamId(req)
+	caller := r.callerFromContext(req)
+
+	content, err := r.readFile(w, req, "avatar", entity.AvatarMaxBytes)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrImageTooLarge):
+			r.contentTooLarge(w, req, entity.AvatarMaxBytes, "User avatar update")
+		default:
+			r.badRequest(w, req, err, "User avatar update")
+		}
+
+		return
+	}
+
+	user, err := r.s.User.UpdateAvatar(req.Context(), caller, id, content)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrForbidden):
+			r.forbidden(w, req, "User avatar update")
+		case errors.Is(err, entity.ErrNoRecord):
+			r.notFound(w, req, "User avatar update")
+		case errors.Is(err, entity.ErrInvalidUserId):
+			r.notFound(w, req, "User avatar update")
+		case errors.Is(err, entity.ErrImageTooLarge):
+			r.contentTooLarge(w, req, entity.AvatarMaxBytes, "User avatar update")
+		case errors.Is(err, entity.ErrUnsupportedImage):
+			r.unsupportedMediaType(w, req, "avatar must be JPEG, PNG or WebP image", "User avatar update")
+		case errors.Is(err, entity.ErrInvalidImage):
+			r.unprocessableEntity(w, req, map[string]string{"avatar": "must be valid image of reasonable dimensions"}, "User avatar update")
+		case errors.Is(err, entity.ErrEditConflict):
+			r.editConflict(w, req, nil, "User avatar update")
+		default:
+			r.serverError(w, req, err, "User avatar update")
+		}
+
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(user.Version))
+
+	r.sendResponse(w, req, http.StatusOK, profileResponse(user))
+
+	// Log user avatar change
+	r.l.Info("user with id '%d' updated avatar at %s", user.Id, time.Now().Format(timeFormat))
+}
+
+// profileResponse returns profile of given user as it is sent to clients
+func profileResponse(user entity.UserEntity) entity.UserProfileResponse {
+	return entity.UserProfileResponse{
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		PendingEmail:  user.PendingEmail,
+		AvatarURL:     user.AvatarURL,
+		Profile:       user.Profile,
+	}
+}
+
+// jwks serves public keys access tokens are signed with, so other services
+// could verify tokens without shared secret
+func (r *routes) jwks(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	r.sendResponse(w, req, http.StatusOK, r.s.User.JWKS())
+}
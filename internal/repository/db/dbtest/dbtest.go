@@ -0,0 +1,108 @@
+// Package dbtest starts postgres database for tests
+package dbtest
+
+import (
+	"errors"
+	"fmt"
+	"inditilla/internal/repository/db"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// URLEnv is environment variable with URL of existing database tests are run against
+// instead of starting new one. Data in the database is deleted by tests
+const URLEnv = "TEST_DB_URL"
+
+// ErrUnavailable is returned when database URL is not set and postgres binaries are not found
+var ErrUnavailable = errors.New("dbtest: postgres is not available, set " + URLEnv + " or put initdb and pg_ctl on PATH")
+
+// Postgres returns URL of migrated database and function stopping it. Database set by URLEnv
+// is used if set, otherwise new postgres cluster is started in temporary directory
+func Postgres() (string, func(), error) {
+	url, stop, err := start()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := migrateUp(url); err != nil {
+		stop()
+		return "", nil, err
+	}
+
+	return url, stop, nil
+}
+
+func start() (string, func(), error) {
+	if url := os.Getenv(URLEnv); url != "" {
+		return url, func() {}, nil
+	}
+
+	initdb, err := exec.LookPath("initdb")
+	if err != nil {
+		return "", nil, ErrUnavailable
+	}
+	pgCtl, err := exec.LookPath("pg_ctl")
+	if err != nil {
+		return "", nil, ErrUnavailable
+	}
+
+	dir, err := os.MkdirTemp("", "inditilla-pg-")
+	if err != nil {
+		return "", nil, err
+	}
+	data := filepath.Join(dir, "data")
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("dbtest: initdb: %v: %s", err, out)
+	}
+
+	options := fmt.Sprintf("-p %d -h 127.0.0.1 -k %s -F", port, dir)
+	out, err = exec.Command(pgCtl, "-D", data, "-l", filepath.Join(dir, "postgres.log"), "-o", options, "-w", "start").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("dbtest: pg_ctl start: %v: %s", err, out)
+	}
+
+	stop := func() {
+		exec.Command(pgCtl, "-D", data, "-m", "immediate", "-w", "stop").Run()
+		os.RemoveAll(dir)
+	}
+
+	return "postgres://postgres@127.0.0.1:" + strconv.Itoa(port) + "/postgres", stop, nil
+}
+
+// migrateUp applies migrations of the project to database with given URL
+func migrateUp(url string) error {
+	m, err := db.NewMigrator(db.WithSSLMode(url, "disable"))
+	if err != nil {
+		return fmt.Errorf("dbtest: migrate: %v", err)
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil {
+		return fmt.Errorf("dbtest: migrate up: %v", err)
+	}
+
+	return nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
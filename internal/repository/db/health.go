@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"inditilla/pkg/health"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PingChecker checks that database accepts connections
+func PingChecker(pool *pgxpool.Pool) health.HealthChecker {
+	return health.CheckerFunc(pool.Ping)
+}
+
+// MigrationChecker checks that database is migrated to the latest embedded migration,
+// so queries of the application match database schema
+func MigrationChecker(db DB) health.HealthChecker {
+	return health.CheckerFunc(func(ctx context.Context) error {
+		latest, err := LatestVersion()
+		if err != nil {
+			return err
+		}
+
+		var (
+			version uint
+			dirty   bool
+		)
+
+		err = db.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+		if err != nil {
+			return fmt.Errorf("read database version: %w", err)
+		}
+
+		if dirty {
+			return fmt.Errorf("database is dirty at version %d", version)
+		}
+		if version != latest {
+			return fmt.Errorf("database is at version %d, expected %d", version, latest)
+		}
+
+		return nil
+	})
+}
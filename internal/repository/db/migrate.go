@@ -0,0 +1,150 @@
+package db
+
+import (
+	"errors"
+	"inditilla/migrations"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrateLockTimeout is how long migrator waits for advisory lock held by other migrator,
+// e.g. of replica applying migrations at the same time. Default timeout of golang-migrate
+// is 15 seconds, which long migration could outlast
+const migrateLockTimeout = 5 * time.Minute
+
+// Migration is database migration embedded into the binary
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// Migrator applies embedded migrations to postgres database. Every change is made under
+// advisory lock golang-migrate's postgres driver takes, so replicas starting at once apply
+// migrations one by one and the rest find database already migrated
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+// NewMigrator returns migrator of database with given URL, it should be closed after use
+func NewMigrator(url string) (*Migrator, error) {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", src, url)
+	if err != nil {
+		return nil, err
+	}
+	m.LockTimeout = migrateLockTimeout
+
+	return &Migrator{m: m}, nil
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up() error {
+	return noChange(m.m.Up())
+}
+
+// Steps applies 'n' next migrations, or rolls back -n migrations if 'n' is negative
+func (m *Migrator) Steps(n int) error {
+	return noChange(m.m.Steps(n))
+}
+
+// To migrates up or down to given version
+func (m *Migrator) To(version uint) error {
+	return noChange(m.m.Migrate(version))
+}
+
+// Force sets version without running migrations, it is used to recover from failed migration
+// that left database dirty once the database is fixed manually
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+// Version returns version of the database (0 if no migration is applied) and whether
+// the last migration failed leaving database dirty
+func (m *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
+
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+
+	return errors.Join(srcErr, dbErr)
+}
+
+// noChange returns nil if error is that there was no migration to apply. Change which
+// is not needed is not an error, e.g. when other replica has already applied migrations
+func noChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+
+	return err
+}
+
+// Migrations returns embedded migrations ordered by version
+func Migrations() ([]Migration, error) {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var list []Migration
+
+	version, err := src.First()
+	for err == nil {
+		list = append(list, Migration{Version: version, Name: migrationName(src, version)})
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// LatestVersion returns version of the last embedded migration, database is up to date at it
+func LatestVersion() (uint, error) {
+	list, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(list) == 0 {
+		return 0, nil
+	}
+
+	return list[len(list)-1].Version, nil
+}
+
+func migrationName(src source.Driver, version uint) string {
+	r, name, err := src.ReadUp(version)
+	if err != nil {
+		return ""
+	}
+	r.Close()
+
+	return name
+}
+
+// WithSSLMode returns database URL with given ssl mode
+func WithSSLMode(url, sslMode string) string {
+	if strings.Contains(url, "?") {
+		return url + "&sslmode=" + sslMode
+	}
+
+	return url + "?sslmode=" + sslMode
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"inditilla/pkg/metrics"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// repositoryPackage is import path prefix of repositories, operation of the query is
+// the repository method running it
+const repositoryPackage = "inditilla/internal/repository/"
+
+var tracer = otel.Tracer("inditilla/internal/repository/db")
+
+type queryStartKey struct{}
+
+// queryStart is query's operation and time it started at
+type queryStart struct {
+	operation string
+	at        time.Time
+}
+
+// QueryTracer records every query run over connection as span named by repository operation
+// (e.g. user.GetById) and records its duration in metrics, if they are not nil. It is set as
+// tracer of connection config
+type QueryTracer struct {
+	m *metrics.Metrics
+}
+
+var _ pgx.QueryTracer = (*QueryTracer)(nil)
+
+func NewQueryTracer(m *metrics.Metrics) *QueryTracer {
+	return &QueryTracer{m: m}
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	op := operation()
+
+	ctx, _ = tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemPostgreSQL,
+		semconv.DBStatement(data.SQL),
+	))
+
+	return context.WithValue(ctx, queryStartKey{}, queryStart{operation: op, at: time.Now()})
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+	span.End()
+
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+
+	t.m.ObserveQuery(start.operation, data.Err, time.Since(start.at))
+}
+
+// operation returns name of the repository method query is run by, e.g. user.GetById for
+// (*userRepo).GetById of user package. Queries run outside of repositories (e.g. transaction
+// begin and commit called by services) are 'other'
+func operation() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+
+		if name, ok := operationName(frame.Function); ok {
+			return name
+		}
+
+		if !more {
+			return "other"
+		}
+	}
+}
+
+// operationName returns operation name of repository function, e.g. user.GetById for
+// inditilla/internal/repository/user.(*userRepo).GetById. Functions of other packages are
+// not operations
+func operationName(function string) (string, bool) {
+	name, ok := strings.CutPrefix(function, repositoryPackage)
+	if !ok || strings.HasPrefix(name, "db.") {
+		return "", false
+	}
+
+	// Drop receiver type and closure suffixes: user.(*userRepo).GetById.func1 -> user.GetById
+	pkg, fn, _ := strings.Cut(name, ".")
+	if i := strings.LastIndex(fn, ")."); i >= 0 {
+		fn = fn[i+2:]
+	}
+	fn, _, _ = strings.Cut(fn, ".")
+
+	return pkg + "." + fn, true
+}
@@ -0,0 +1,82 @@
+package lockout
+
+import (
+	"context"
+	"inditilla/internal/repository/db"
+	"time"
+)
+
+type LockoutRepo interface {
+	GetBlockedUntil(context.Context, []string) (*time.Time, error)
+	RecordFailure(context.Context, string, time.Time) (int, error)
+	Block(context.Context, string, time.Time) error
+	Reset(context.Context, string) error
+	Prune(context.Context, time.Time) error
+}
+
+type lockoutRepo struct {
+	db db.DB
+}
+
+func NewLockoutRepo(db db.DB) *lockoutRepo {
+	return &lockoutRepo{
+		db: db,
+	}
+}
+
+// GetBlockedUntil returns the latest time log in is blocked until by any of given keys,
+// or nil if none of them was blocked
+func (r *lockoutRepo) GetBlockedUntil(ctx context.Context, keys []string) (*time.Time, error) {
+	var blockedUntil *time.Time
+
+	err := r.db.QueryRow(ctx, `SELECT MAX(blocked_until) FROM login_failures WHERE key = ANY($1)`, keys).Scan(&blockedUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	return blockedUntil, nil
+}
+
+// RecordFailure counts failed log in attempt by given key and returns number of failures
+// in a row. Failures made before 'windowStart' are forgotten and counting starts over
+func (r *lockoutRepo) RecordFailure(ctx context.Context, key string, windowStart time.Time) (int, error) {
+	query := `
+		INSERT INTO login_failures (key, failures, last_failed_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE WHEN login_failures.last_failed_at < $3 THEN 1 ELSE login_failures.failures + 1 END,
+			last_failed_at = EXCLUDED.last_failed_at
+		RETURNING failures
+		`
+
+	var failures int
+
+	err := r.db.QueryRow(ctx, query, key, time.Now().UTC(), windowStart.UTC()).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+// Block blocks log in by given key until given time
+func (r *lockoutRepo) Block(ctx context.Context, key string, until time.Time) error {
+	_, err := r.db.Exec(ctx, `UPDATE login_failures SET blocked_until = $1 WHERE key = $2`, until.UTC(), key)
+
+	return err
+}
+
+// Reset forgets failed log in attempts by given key and unblocks it
+func (r *lockoutRepo) Reset(ctx context.Context, key string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM login_failures WHERE key = $1`, key)
+
+	return err
+}
+
+// Prune deletes counters of failures made before 'windowStart' which are not blocking log in
+// anymore. They are forgotten by RecordFailure anyway, so it only keeps the table small
+func (r *lockoutRepo) Prune(ctx context.Context, windowStart time.Time) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM login_failures WHERE last_failed_at < $1 AND (blocked_until IS NULL OR blocked_until < $2)`,
+		windowStart.UTC(), time.Now().UTC())
+
+	return err
+}
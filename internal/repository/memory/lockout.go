@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"time"
+)
+
+type lockoutRepo struct {
+	c *Conn
+}
+
+func NewLockoutRepo(c *Conn) *lockoutRepo {
+	return &lockoutRepo{
+		c: c,
+	}
+}
+
+// GetBlockedUntil returns the latest time log in is blocked until by any of given keys,
+// or nil if none of them was blocked
+func (r *lockoutRepo) GetBlockedUntil(_ context.Context, keys []string) (*time.Time, error) {
+	defer r.c.lock()()
+
+	var blockedUntil *time.Time
+	for _, key := range keys {
+		f, ok := r.c.s.t.loginFailures[key]
+		if !ok || f.blockedUntil == nil {
+			continue
+		}
+
+		if blockedUntil == nil || f.blockedUntil.After(*blockedUntil) {
+			blockedUntil = ptr(*f.blockedUntil)
+		}
+	}
+
+	return blockedUntil, nil
+}
+
+// RecordFailure counts failed log in attempt by given key and returns number of failures
+// in a row. Failures made before 'windowStart' are forgotten and counting starts over
+func (r *lockoutRepo) RecordFailure(_ context.Context, key string, windowStart time.Time) (int, error) {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	f, ok := t.loginFailures[key]
+	if !ok || f.lastFailedAt.Before(windowStart) {
+		f.failures = 0
+	}
+	f.failures++
+	f.lastFailedAt = now()
+	t.loginFailures[key] = f
+
+	return f.failures, nil
+}
+
+// Block blocks log in by given key until given time
+func (r *lockoutRepo) Block(_ context.Context, key string, until time.Time) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	if f, ok := t.loginFailures[key]; ok {
+		f.blockedUntil = ptr(until.UTC())
+		t.loginFailures[key] = f
+	}
+
+	return nil
+}
+
+// Reset forgets failed log in attempts by given key and unblocks it
+func (r *lockoutRepo) Reset(_ context.Context, key string) error {
+	defer r.c.lock()()
+
+	delete(r.c.s.t.loginFailures, key)
+
+	return nil
+}
+
+// Prune deletes counters of failures made before 'windowStart' which are not blocking log in
+// anymore. They are forgotten by RecordFailure anyway, so it only keeps the store small
+func (r *lockoutRepo) Prune(_ context.Context, windowStart time.Time) error {
+	defer r.c.lock()()
+	current := now()
+
+	maps.DeleteFunc(r.c.s.t.loginFailures, func(_ string, f loginFailure) bool {
+		return f.lastFailedAt.Before(windowStart) && (f.blockedUntil == nil || f.blockedUntil.Before(current))
+	})
+
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package memory implements repositories keeping data in memory of the process. It mirrors
+// semantics of postgres repositories (unique emails, cascading deletes, conditional
+// updates, transactions) and is meant for tests and local development
+package memory
+
+import (
+	"errors"
+	"inditilla/internal/entity"
+	"maps"
+	"sync"
+	"time"
+)
+
+// errNoUser is returned when row references user that does not exist, as foreign key violation
+var errNoUser = errors.New("memory: user does not exist")
+
+// Store holds tables of all memory repositories
+type Store struct {
+	mu           sync.Mutex
+	t            *tables
+	passwordCost int
+}
+
+// Conn is handle memory repositories access the store with. Connection of transaction holds
+// store's lock for whole transaction, so its operations do not lock the store again
+type Conn struct {
+	s    *Store
+	inTx bool
+}
+
+type userRole struct {
+	userId int
+	role   string
+}
+
+type recoveryCode struct {
+	userId int
+	hash   string
+}
+
+type loginFailure struct {
+	failures     int
+	lastFailedAt time.Time
+	blockedUntil *time.Time
+}
+
+// tables are rows of the store. Rows are kept by value, so copy of maps is a snapshot
+type tables struct {
+	users         map[int]entity.UserEntity // Password is bcrypt hash
+	userSeq       int
+	refreshTokens map[int]entity.RefreshToken
+	refreshSeq    int
+	revokedTokens map[string]time.Time // Expiration dates by jti
+	roles         map[string]entity.Role
+	userRoles     map[userRole]struct{}
+	oneTimeTokens map[int]entity.OneTimeToken
+	oneTimeSeq    int
+	totps         map[int]entity.Totp
+	recoveryCodes map[recoveryCode]*time.Time // Used dates
+	loginFailures map[string]loginFailure
+}
+
+// New returns connection to new empty store with roles seeded as by migrations.
+// Passwords are hashed with given bcrypt cost
+func New(passwordCost int) *Conn {
+	t := &tables{
+		users:         make(map[int]entity.UserEntity),
+		refreshTokens: make(map[int]entity.RefreshToken),
+		revokedTokens: make(map[string]time.Time),
+		roles:         make(map[string]entity.Role),
+		userRoles:     make(map[userRole]struct{}),
+		oneTimeTokens: make(map[int]entity.OneTimeToken),
+		totps:         make(map[int]entity.Totp),
+		recoveryCodes: make(map[recoveryCode]*time.Time),
+		loginFailures: make(map[string]loginFailure),
+	}
+
+	t.roles[entity.RoleAdmin] = entity.Role{
+		Id:   1,
+		Name: entity.RoleAdmin,
+		Permissions: []string{
+			entity.PermProfileReadAny,
+			entity.PermProfileUpdateAny,
+			entity.PermRolesManage,
+			entity.PermUsersManage,
+			entity.PermUsersRead,
+		},
+	}
+	t.roles["support"] = entity.Role{
+		Id:          2,
+		Name:        "support",
+		Permissions: []string{entity.PermProfileReadAny, entity.PermUsersRead},
+	}
+
+	return &Conn{
+		s: &Store{
+			t:            t,
+			passwordCost: passwordCost,
+		},
+	}
+}
+
+// WithTx runs 'fn' with connection of transaction. Store is locked for whole transaction
+// and restored from snapshot if 'fn' returns error, so transactions are atomic and
+// serializable. Nested transactions are restored to the state they started with
+func (c *Conn) WithTx(fn func(*Conn) error) error {
+	if !c.inTx {
+		c.s.mu.Lock()
+		defer c.s.mu.Unlock()
+	}
+
+	snapshot := c.s.t.clone()
+
+	if err := fn(&Conn{s: c.s, inTx: true}); err != nil {
+		c.s.t = snapshot
+		return err
+	}
+
+	return nil
+}
+
+// lock locks the store unless connection is in transaction and returns unlock function
+func (c *Conn) lock() func() {
+	if c.inTx {
+		return func() {}
+	}
+
+	c.s.mu.Lock()
+	return c.s.mu.Unlock
+}
+
+func (t *tables) clone() *tables {
+	c := *t
+	c.users = maps.Clone(t.users)
+	c.refreshTokens = maps.Clone(t.refreshTokens)
+	c.revokedTokens = maps.Clone(t.revokedTokens)
+	c.roles = maps.Clone(t.roles)
+	c.userRoles = maps.Clone(t.userRoles)
+	c.oneTimeTokens = maps.Clone(t.oneTimeTokens)
+	c.totps = maps.Clone(t.totps)
+	c.recoveryCodes = maps.Clone(t.recoveryCodes)
+	c.loginFailures = maps.Clone(t.loginFailures)
+
+	return &c
+}
+
+// now returns current time as postgres stores it - in UTC with microsecond precision
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// ptr returns pointer to copy of given value
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"context"
+	"inditilla/internal/entity"
+	"maps"
+	"time"
+)
+
+type mfaRepo struct {
+	c *Conn
+}
+
+func NewMfaRepo(c *Conn) *mfaRepo {
+	return &mfaRepo{
+		c: c,
+	}
+}
+
+// SaveTotp saves new not confirmed TOTP secret of the user, replacing previous one
+// if it was not confirmed either. Confirmed TOTP is never replaced
+func (r *mfaRepo) SaveTotp(_ context.Context, userId int, secret string) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	if _, ok := t.users[userId]; !ok {
+		return errNoUser
+	}
+
+	if totp, ok := t.totps[userId]; ok && totp.ConfirmedAt != nil {
+		return entity.ErrMfaEnabled
+	}
+
+	t.totps[userId] = entity.Totp{
+		UserId:    userId,
+		Secret:    secret,
+		CreatedAt: now(),
+	}
+
+	return nil
+}
+
+func (r *mfaRepo) GetTotp(_ context.Context, userId int) (entity.Totp, error) {
+	defer r.c.lock()()
+
+	totp, ok := r.c.s.t.totps[userId]
+	if !ok {
+		return entity.Totp{}, entity.ErrNoRecord
+	}
+
+	return totp, nil
+}
+
+// ConfirmTotp enables user's TOTP confirmed with code of given time step and saves hashes
+// of recovery codes. It returns ErrMfaEnabled if TOTP was already confirmed
+func (r *mfaRepo) ConfirmTotp(_ context.Context, userId int, step int64, codeHashes []string) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	totp, ok := t.totps[userId]
+	if !ok || totp.ConfirmedAt != nil {
+		return entity.ErrMfaEnabled
+	}
+
+	totp.ConfirmedAt = ptr(now())
+	totp.LastUsedStep = step
+	t.totps[userId] = totp
+
+	maps.DeleteFunc(t.recoveryCodes, func(rc recoveryCode, _ *time.Time) bool { return rc.userId == userId })
+	for _, hash := range codeHashes {
+		t.recoveryCodes[recoveryCode{userId: userId, hash: hash}] = nil
+	}
+
+	return nil
+}
+
+// UseTotpStep records time step of TOTP code used by user, so the same code could not
+// be used twice. It returns ErrInvalidMfaCode if the same or later step was already used
+func (r *mfaRepo) UseTotpStep(_ context.Context, userId int, step int64) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	totp, ok := t.totps[userId]
+	if !ok || totp.ConfirmedAt == nil || totp.LastUsedStep >= step {
+		return entity.ErrInvalidMfaCode
+	}
+
+	totp.LastUsedStep = step
+	t.totps[userId] = totp
+
+	return nil
+}
+
+// UseRecoveryCode marks user's recovery code with given hash as used. It returns
+// ErrNoRecord if there is no such unused code
+func (r *mfaRepo) UseRecoveryCode(_ context.Context, userId int, hash string) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	key := recoveryCode{userId: userId, hash: hash}
+	usedAt, ok := t.recoveryCodes[key]
+	if !ok || usedAt != nil {
+		return entity.ErrNoRecord
+	}
+
+	t.recoveryCodes[key] = ptr(now())
+
+	return nil
+}
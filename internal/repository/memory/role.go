@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"inditilla/internal/entity"
+	"slices"
+	"strings"
+)
+
+type roleRepo struct {
+	c *Conn
+}
+
+func NewRoleRepo(c *Conn) *roleRepo {
+	return &roleRepo{
+		c: c,
+	}
+}
+
+// GetAll returns all roles with permissions granted by them
+func (r *roleRepo) GetAll(_ context.Context) ([]entity.Role, error) {
+	defer r.c.lock()()
+
+	roles := []entity.Role{}
+	for _, role := range r.c.s.t.roles {
+		role.Permissions = slices.Clone(role.Permissions)
+		roles = append(roles, role)
+	}
+
+	slices.SortFunc(roles, func(a, b entity.Role) int { return strings.Compare(a.Name, b.Name) })
+
+	return roles, nil
+}
+
+// GetUserRoles returns names of roles assigned to user with given id
+func (r *roleRepo) GetUserRoles(_ context.Context, userId int) ([]string, error) {
+	defer r.c.lock()()
+
+	roles := []string{}
+	for ur := range r.c.s.t.userRoles {
+		if ur.userId == userId {
+			roles = append(roles, ur.role)
+		}
+	}
+
+	slices.Sort(roles)
+
+	return roles, nil
+}
+
+// GetPermissions returns names of permissions granted by any of given roles
+func (r *roleRepo) GetPermissions(_ context.Context, roles []string) ([]string, error) {
+	defer r.c.lock()()
+
+	permissions := []string{}
+	for _, name := range roles {
+		if role, ok := r.c.s.t.roles[name]; ok {
+			permissions = append(permissions, role.Permissions...)
+		}
+	}
+
+	slices.Sort(permissions)
+
+	return slices.Compact(permissions), nil
+}
+
+// Assign assigns role with given name to user. It returns entity.ErrNoRecord if there is
+// no such role or user. Assigning role the user already has is not an error
+func (r *roleRepo) Assign(_ context.Context, userId int, role string) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	if _, ok := t.users[userId]; !ok {
+		return entity.ErrNoRecord
+	}
+	if _, ok := t.roles[role]; !ok {
+		return entity.ErrNoRecord
+	}
+
+	t.userRoles[userRole{userId: userId, role: role}] = struct{}{}
+
+	return nil
+}
+
+// Remove removes role with given name from user. It returns entity.ErrNoRecord if user
+// does not have such role
+func (r *roleRepo) Remove(_ context.Context, userId int, role string) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	key := userRole{userId: userId, role: role}
+	if _, ok := t.userRoles[key]; !ok {
+		return entity.ErrNoRecord
+	}
+
+	delete(t.userRoles, key)
+
+	return nil
+}
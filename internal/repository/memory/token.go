@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"context"
+	"inditilla/internal/entity"
+	"maps"
+	"time"
+)
+
+type tokenRepo struct {
+	c *Conn
+}
+
+func NewTokenRepo(c *Conn) *tokenRepo {
+	return &tokenRepo{
+		c: c,
+	}
+}
+
+func (r *tokenRepo) SaveRefreshToken(_ context.Context, rt *entity.RefreshToken) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	if _, ok := t.users[rt.UserId]; !ok {
+		return errNoUser
+	}
+
+	t.refreshSeq++
+	rt.Id = t.refreshSeq
+	rt.ExpiresAt = rt.ExpiresAt.UTC().Truncate(time.Microsecond)
+	rt.CreatedAt = now()
+	t.refreshTokens[rt.Id] = *rt
+
+	return nil
+}
+
+func (r *tokenRepo) GetRefreshToken(_ context.Context, hash string) (entity.RefreshToken, error) {
+	defer r.c.lock()()
+
+	for _, rt := range r.c.s.t.refreshTokens {
+		if rt.TokenHash == hash {
+			return rt, nil
+		}
+	}
+
+	return entity.RefreshToken{}, entity.ErrNoRecord
+}
+
+// MarkRefreshTokenUsed marks refresh token as used. It returns entity.ErrRefreshTokenReuse
+// if token was already used or revoked
+func (r *tokenRepo) MarkRefreshTokenUsed(_ context.Context, id int) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	rt, ok := t.refreshTokens[id]
+	if !ok || rt.UsedAt != nil || rt.RevokedAt != nil {
+		return entity.ErrRefreshTokenReuse
+	}
+
+	rt.UsedAt = ptr(now())
+	t.refreshTokens[id] = rt
+
+	return nil
+}
+
+func (r *tokenRepo) RevokeRefreshTokenFamily(_ context.Context, familyId string) error {
+	defer r.c.lock()()
+
+	r.c.s.t.revokeRefreshTokens(func(rt entity.RefreshToken) bool { return rt.FamilyId == familyId })
+
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes all active refresh tokens of given user and
+// returns ids of revoked families
+func (r *tokenRepo) RevokeUserRefreshTokens(_ context.Context, userId int) ([]string, error) {
+	defer r.c.lock()()
+
+	return r.c.s.t.revokeRefreshTokens(func(rt entity.RefreshToken) bool { return rt.UserId == userId }), nil
+}
+
+func (r *tokenRepo) IsFamilyRevoked(_ context.Context, familyId string) (bool, error) {
+	defer r.c.lock()()
+
+	for _, rt := range r.c.s.t.refreshTokens {
+		if rt.FamilyId == familyId && rt.RevokedAt != nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// RevokeAccessToken saves id of access token as revoked until its expiration date.
+// Revocations of already expired tokens are deleted along the way
+func (r *tokenRepo) RevokeAccessToken(_ context.Context, jti string, _ int, expiresAt time.Time) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	if _, ok := t.revokedTokens[jti]; !ok {
+		t.revokedTokens[jti] = expiresAt.UTC()
+	}
+
+	current := now()
+	maps.DeleteFunc(t.revokedTokens, func(_ string, expiresAt time.Time) bool { return expiresAt.Before(current) })
+
+	return nil
+}
+
+func (r *tokenRepo) IsAccessTokenRevoked(_ context.Context, jti string) (bool, error) {
+	defer r.c.lock()()
+
+	_, revoked := r.c.s.t.revokedTokens[jti]
+
+	return revoked, nil
+}
+
+func (r *tokenRepo) SaveOneTimeToken(_ context.Context, ot *entity.OneTimeToken) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	if _, ok := t.users[ot.UserId]; !ok {
+		return errNoUser
+	}
+
+	t.oneTimeSeq++
+	ot.Id = t.oneTimeSeq
+	ot.ExpiresAt = ot.ExpiresAt.UTC().Truncate(time.Microsecond)
+	ot.CreatedAt = now()
+	t.oneTimeTokens[ot.Id] = *ot
+
+	return nil
+}
+
+// ConsumeOneTimeToken marks token with given hash and purpose as used and returns it.
+// It returns entity.ErrNoRecord if there is no such token or it is expired or already used
+func (r *tokenRepo) ConsumeOneTimeToken(_ context.Context, hash, purpose string) (entity.OneTimeToken, error) {
+	defer r.c.lock()()
+	t := r.c.s.t
+	current := now()
+
+	for id, ot := range t.oneTimeTokens {
+		if ot.TokenHash != hash || ot.Purpose != purpose || ot.UsedAt != nil || !ot.ExpiresAt.After(current) {
+			continue
+		}
+
+		ot.UsedAt = ptr(current)
+		t.oneTimeTokens[id] = ot
+
+		return ot, nil
+	}
+
+	return entity.OneTimeToken{}, entity.ErrNoRecord
+}
+
+// DeleteOneTimeTokens deletes all tokens of given purpose issued to user
+func (r *tokenRepo) DeleteOneTimeTokens(_ context.Context, userId int, purpose string) error {
+	defer r.c.lock()()
+
+	maps.DeleteFunc(r.c.s.t.oneTimeTokens, func(_ int, ot entity.OneTimeToken) bool {
+		return ot.UserId == userId && ot.Purpose == purpose
+	})
+
+	return nil
+}
+
+// GetPendingEmail returns email of the latest valid email verification token issued to user
+// for an email other than user's current one, or empty string if there is no such token
+func (r *tokenRepo) GetPendingEmail(_ context.Context, userId int) (string, error) {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	u, ok := t.users[userId]
+	if !ok {
+		return "", nil
+	}
+
+	var latest *entity.OneTimeToken
+	current := now()
+
+	for _, ot := range t.oneTimeTokens {
+		if ot.UserId != userId || ot.Purpose != entity.TokenPurposeEmailVerification || ot.UsedAt != nil ||
+			!ot.ExpiresAt.After(current) || ot.Email == nil || *ot.Email == u.Email {
+			continue
+		}
+
+		if latest == nil || ot.CreatedAt.After(latest.CreatedAt) ||
+			(ot.CreatedAt.Equal(latest.CreatedAt) && ot.Id > latest.Id) {
+			latest = ptr(ot)
+		}
+	}
+
+	if latest == nil {
+		return "", nil
+	}
+
+	return *latest.Email, nil
+}
+
+// revokeRefreshTokens revokes active refresh tokens matching given condition and
+// returns family ids of revoked tokens
+func (t *tables) revokeRefreshTokens(match func(entity.RefreshToken) bool) []string {
+	families := []string{}
+	current := now()
+
+	for id, rt := range t.refreshTokens {
+		if rt.RevokedAt != nil || !match(rt) {
+			continue
+		}
+
+		rt.RevokedAt = ptr(current)
+		t.refreshTokens[id] = rt
+		families = append(families, rt.FamilyId)
+	}
+
+	return families
+}
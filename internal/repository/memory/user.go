@@ -0,0 +1,275 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"inditilla/internal/entity"
+	"inditilla/pkg/password"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type userRepo struct {
+	c *Conn
+}
+
+func NewUserRepo(c *Conn) *userRepo {
+	return &userRepo{
+		c: c,
+	}
+}
+
+func (r *userRepo) SaveUser(ctx context.Context, u entity.UserSignupForm) (int, error) {
+	hashedPassword, err := password.Hash(ctx, u.Password, r.c.s.passwordCost)
+	if err != nil {
+		return 0, err
+	}
+
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	if _, ok := t.userByEmail(u.Email); ok {
+		return 0, entity.ErrDuplicateEmail
+	}
+
+	t.userSeq++
+	t.users[t.userSeq] = entity.UserEntity{
+		Id:        t.userSeq,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.Email,
+		Password:  string(hashedPassword),
+		CreatedAt: now(),
+		Version:   1,
+	}
+
+	return t.userSeq, nil
+}
+
+func (r *userRepo) Authenticate(ctx context.Context, email string, plain string) (entity.UserEntity, error) {
+	unlock := r.c.lock()
+	user, ok := r.c.s.t.userByEmail(email)
+	unlock()
+
+	if !ok {
+		return entity.UserEntity{}, entity.ErrInvalidCredentials
+	}
+
+	err := password.Compare(ctx, []byte(user.Password), plain)
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return entity.UserEntity{}, entity.ErrInvalidCredentials
+		}
+		return entity.UserEntity{}, err
+	}
+
+	user.Password = ""
+
+	return user, nil
+}
+
+func (r *userRepo) Exists(_ context.Context, email string) (bool, error) {
+	defer r.c.lock()()
+
+	_, ok := r.c.s.t.userByEmail(email)
+
+	return ok, nil
+}
+
+func (r *userRepo) GetById(_ context.Context, id int) (entity.UserEntity, error) {
+	defer r.c.lock()()
+
+	user, ok := r.c.s.t.users[id]
+	if !ok {
+		return entity.UserEntity{}, entity.ErrNoRecord
+	}
+
+	return user, nil
+}
+
+func (r *userRepo) GetByEmail(_ context.Context, email string) (entity.UserEntity, error) {
+	defer r.c.lock()()
+
+	user, ok := r.c.s.t.userByEmail(email)
+	if !ok {
+		return entity.UserEntity{}, entity.ErrNoRecord
+	}
+
+	return user, nil
+}
+
+// Update updates user and increments user's version. It returns entity.ErrEditConflict if
+// user's version differs from given one, i.e. user was updated after it was read
+func (r *userRepo) Update(ctx context.Context, user *entity.UserEntity, isPasswordChanged bool) error {
+	hashedPassword := user.Password
+	if isPasswordChanged {
+		hash, err := password.Hash(ctx, user.Password, r.c.s.passwordCost)
+		if err != nil {
+			return err
+		}
+		hashedPassword = string(hash)
+	}
+
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	u, ok := t.users[user.Id]
+	if !ok || u.Version != user.Version {
+		return entity.ErrEditConflict
+	}
+
+	if other, ok := t.userByEmail(user.Email); ok && other.Id != user.Id {
+		return entity.ErrDuplicateEmail
+	}
+
+	u.FirstName = user.FirstName
+	u.LastName = user.LastName
+	u.Email = user.Email
+	u.Password = hashedPassword
+	u.Profile = user.Profile
+	u.Avatar = user.Avatar
+	u.Version++
+	t.users[u.Id] = u
+
+	user.Version = u.Version
+
+	return nil
+}
+
+// List returns users matching given filter. Users are ordered by sort column and id,
+// page starts right after the last user of previous page
+func (r *userRepo) List(_ context.Context, f entity.UserFilter) ([]entity.UserEntity, error) {
+	compare, ok := userComparators[f.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort column: %q", f.SortBy)
+	}
+
+	order := func(a, b entity.UserEntity) int {
+		c := compare(a, b)
+		if c == 0 {
+			c = cmp.Compare(a.Id, b.Id)
+		}
+		if f.Desc {
+			return -c
+		}
+		return c
+	}
+
+	defer r.c.lock()()
+
+	users := []entity.UserEntity{}
+	for _, u := range r.c.s.t.users {
+		if !strings.HasPrefix(u.Email, f.EmailPrefix) {
+			continue
+		}
+		if f.CreatedFrom != nil && u.CreatedAt.Before(*f.CreatedFrom) {
+			continue
+		}
+		if f.CreatedTo != nil && !u.CreatedAt.Before(*f.CreatedTo) {
+			continue
+		}
+		if f.Status == entity.UserStatusActive && u.DisabledAt != nil {
+			continue
+		}
+		if f.Status == entity.UserStatusDisabled && u.DisabledAt == nil {
+			continue
+		}
+		if f.After != nil && order(u, *f.After) <= 0 {
+			continue
+		}
+
+		users = append(users, u)
+	}
+
+	slices.SortFunc(users, order)
+
+	if len(users) > f.Limit {
+		users = users[:f.Limit]
+	}
+
+	return users, nil
+}
+
+// SetDisabled disables or enables user with given id
+func (r *userRepo) SetDisabled(_ context.Context, id int, disabled bool) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	u, ok := t.users[id]
+	if !ok {
+		return entity.ErrNoRecord
+	}
+
+	u.DisabledAt = nil
+	if disabled {
+		u.DisabledAt = ptr(now())
+	}
+	t.users[id] = u
+
+	return nil
+}
+
+// SetEmailVerified sets user's email to given verified email
+func (r *userRepo) SetEmailVerified(_ context.Context, id int, email string) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	u, ok := t.users[id]
+	if !ok {
+		return entity.ErrNoRecord
+	}
+
+	if other, ok := t.userByEmail(email); ok && other.Id != id {
+		return entity.ErrDuplicateEmail
+	}
+
+	u.Email = email
+	u.EmailVerifiedAt = ptr(now())
+	u.Version++
+	t.users[id] = u
+
+	return nil
+}
+
+// Delete deletes user with given id, all user's tokens and roles are deleted with it
+func (r *userRepo) Delete(_ context.Context, id int) error {
+	defer r.c.lock()()
+	t := r.c.s.t
+
+	if _, ok := t.users[id]; !ok {
+		return entity.ErrNoRecord
+	}
+
+	delete(t.users, id)
+
+	maps.DeleteFunc(t.refreshTokens, func(_ int, rt entity.RefreshToken) bool { return rt.UserId == id })
+	maps.DeleteFunc(t.userRoles, func(ur userRole, _ struct{}) bool { return ur.userId == id })
+	maps.DeleteFunc(t.oneTimeTokens, func(_ int, ot entity.OneTimeToken) bool { return ot.UserId == id })
+	maps.DeleteFunc(t.recoveryCodes, func(rc recoveryCode, _ *time.Time) bool { return rc.userId == id })
+	delete(t.totps, id)
+
+	return nil
+}
+
+// userComparators compare users by sort fields of users list
+var userComparators = map[string]func(a, b entity.UserEntity) int{
+	"id":        func(a, b entity.UserEntity) int { return cmp.Compare(a.Id, b.Id) },
+	"email":     func(a, b entity.UserEntity) int { return strings.Compare(a.Email, b.Email) },
+	"createdAt": func(a, b entity.UserEntity) int { return a.CreatedAt.Compare(b.CreatedAt) },
+}
+
+func (t *tables) userByEmail(email string) (entity.UserEntity, bool) {
+	for _, u := range t.users {
+		if u.Email == email {
+			return u, true
+		}
+	}
+
+	return entity.UserEntity{}, false
+}
@@ -0,0 +1,16 @@
+package memory_test
+
+import (
+	"inditilla/internal/repository/memory"
+	"inditilla/internal/repository/user"
+	"inditilla/internal/repository/user/usertest"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserRepo(t *testing.T) {
+	usertest.Run(t, func(t *testing.T) user.UserRepo {
+		return memory.NewUserRepo(memory.New(bcrypt.MinCost))
+	})
+}
@@ -0,0 +1,138 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"inditilla/internal/entity"
+	"inditilla/internal/repository/db"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type MfaRepo interface {
+	SaveTotp(context.Context, int, string) error
+	GetTotp(context.Context, int) (entity.Totp, error)
+	ConfirmTotp(context.Context, int, int64, []string) error
+	UseTotpStep(context.Context, int, int64) error
+	UseRecoveryCode(context.Context, int, string) error
+}
+
+type mfaRepo struct {
+	db db.DB
+}
+
+func NewMfaRepo(db db.DB) *mfaRepo {
+	return &mfaRepo{
+		db: db,
+	}
+}
+
+// SaveTotp saves new not confirmed TOTP secret of the user, replacing previous one
+// if it was not confirmed either. Confirmed TOTP is never replaced
+func (r *mfaRepo) SaveTotp(ctx context.Context, userId int, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = (now() AT TIME ZONE 'UTC')
+		WHERE user_totp.confirmed_at IS NULL
+		`
+
+	tag, err := r.db.Exec(ctx, query, userId, secret)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return entity.ErrMfaEnabled
+	}
+
+	return nil
+}
+
+func (r *mfaRepo) GetTotp(ctx context.Context, userId int) (entity.Totp, error) {
+	t := entity.Totp{}
+
+	query := `SELECT user_id, secret, confirmed_at, last_used_step, created_at FROM user_totp WHERE user_id = $1`
+
+	err := r.db.QueryRow(ctx, query, userId).Scan(&t.UserId, &t.Secret, &t.ConfirmedAt, &t.LastUsedStep, &t.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Totp{}, entity.ErrNoRecord
+		}
+		return entity.Totp{}, err
+	}
+
+	return t, nil
+}
+
+// ConfirmTotp enables user's TOTP confirmed with code of given time step and saves hashes
+// of recovery codes. It returns ErrMfaEnabled if TOTP was already confirmed
+func (r *mfaRepo) ConfirmTotp(ctx context.Context, userId int, step int64, codeHashes []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE user_totp SET confirmed_at = $1, last_used_step = $2
+		WHERE user_id = $3 AND confirmed_at IS NULL`
+
+	tag, err := tx.Exec(ctx, query, time.Now().UTC(), step, userId)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return entity.ErrMfaEnabled
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userId); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.Exec(ctx, `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userId, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseTotpStep records time step of TOTP code used by user, so the same code could not
+// be used twice. It returns ErrInvalidMfaCode if the same or later step was already used
+func (r *mfaRepo) UseTotpStep(ctx context.Context, userId int, step int64) error {
+	query := `UPDATE user_totp SET last_used_step = $1
+		WHERE user_id = $2 AND last_used_step < $1 AND confirmed_at IS NOT NULL`
+
+	tag, err := r.db.Exec(ctx, query, step, userId)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return entity.ErrInvalidMfaCode
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks user's recovery code with given hash as used. It returns
+// ErrNoRecord if there is no such unused code
+func (r *mfaRepo) UseRecoveryCode(ctx context.Context, userId int, hash string) error {
+	query := `UPDATE mfa_recovery_codes SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`
+
+	tag, err := r.db.Exec(ctx, query, time.Now().UTC(), userId, hash)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return entity.ErrNoRecord
+	}
+
+	return nil
+}
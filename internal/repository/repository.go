@@ -1,18 +1,90 @@
 package repository
 
 import (
+	"context"
+	"inditilla/internal/repository/db"
+	"inditilla/internal/repository/lockout"
+	"inditilla/internal/repository/memory"
+	"inditilla/internal/repository/mfa"
+	"inditilla/internal/repository/role"
+	"inditilla/internal/repository/token"
 	"inditilla/internal/repository/user"
+	"inditilla/pkg/health"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Repositories struct {
-	User user.UserRepo
+	User    user.UserRepo
+	Token   token.TokenRepo
+	Role    role.RoleRepo
+	Mfa     mfa.MfaRepo
+	Lockout lockout.LockoutRepo
+
+	// Checks are health checkers of the database by name, memory repositories have none
+	Checks map[string]health.HealthChecker
+
+	withTx func(context.Context, func(*Repositories) error) error
+}
+
+// New returns Repositories struct with all repositories initialized
+func New(pool *pgxpool.Pool) *Repositories {
+	r := newRepositories(pool)
+	r.Checks = map[string]health.HealthChecker{
+		"database":   db.PingChecker(pool),
+		"migrations": db.MigrationChecker(pool),
+	}
+
+	return r
+}
+
+// NewMemory returns Repositories struct with all repositories keeping data in memory.
+// Passwords are hashed with given bcrypt cost
+func NewMemory(passwordCost int) *Repositories {
+	return newMemoryRepositories(memory.New(passwordCost))
 }
 
-// New returns Repositories struct with all repositories initialized (only User repo in this case)
-func New(db *pgx.Conn) *Repositories {
+func newRepositories(db db.DB) *Repositories {
 	return &Repositories{
-		User: user.NewUserRepo(db),
+		User:    user.NewUserRepo(db),
+		Token:   token.NewTokenRepo(db),
+		Role:    role.NewRoleRepo(db),
+		Mfa:     mfa.NewMfaRepo(db),
+		Lockout: lockout.NewLockoutRepo(db),
+		withTx: func(ctx context.Context, fn func(*Repositories) error) error {
+			tx, err := db.Begin(ctx)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback(ctx)
+
+			if err := fn(newRepositories(tx)); err != nil {
+				return err
+			}
+
+			return tx.Commit(ctx)
+		},
+	}
+}
+
+func newMemoryRepositories(c *memory.Conn) *Repositories {
+	return &Repositories{
+		User:    memory.NewUserRepo(c),
+		Token:   memory.NewTokenRepo(c),
+		Role:    memory.NewRoleRepo(c),
+		Mfa:     memory.NewMfaRepo(c),
+		Lockout: memory.NewLockoutRepo(c),
+		withTx: func(_ context.Context, fn func(*Repositories) error) error {
+			return c.WithTx(func(tx *memory.Conn) error {
+				return fn(newMemoryRepositories(tx))
+			})
+		},
 	}
 }
+
+// WithTx runs 'fn' with repositories working within single transaction (unit of work).
+// Transaction is committed if 'fn' returns no error and rolled back otherwise. Nested
+// calls run within savepoints of the outer transaction
+func (r *Repositories) WithTx(ctx context.Context, fn func(*Repositories) error) error {
+	return r.withTx(ctx, fn)
+}
@@ -0,0 +1,171 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inditilla/internal/entity"
+	"inditilla/internal/repository"
+	"inditilla/internal/repository/db/dbtest"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	pool        *pgxpool.Pool
+	unavailable error
+)
+
+func TestMain(m *testing.M) {
+	url, stop, err := dbtest.Postgres()
+	if err != nil {
+		if !errors.Is(err, dbtest.ErrUnavailable) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		unavailable = err
+		os.Exit(m.Run())
+	}
+
+	pool, err = pgxpool.New(context.Background(), url)
+	if err != nil {
+		stop()
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+
+	pool.Close()
+	stop()
+	os.Exit(code)
+}
+
+func TestWithTx(t *testing.T) {
+	t.Run("postgres", func(t *testing.T) {
+		if unavailable != nil {
+			t.Skip(unavailable)
+		}
+
+		testWithTx(t, func(t *testing.T) *repository.Repositories {
+			if _, err := pool.Exec(context.Background(), `TRUNCATE users RESTART IDENTITY CASCADE`); err != nil {
+				t.Fatalf("truncate users: %v", err)
+			}
+
+			return repository.New(pool)
+		})
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		testWithTx(t, func(t *testing.T) *repository.Repositories {
+			return repository.NewMemory(bcrypt.MinCost)
+		})
+	})
+}
+
+var errAbort = errors.New("abort")
+
+func testWithTx(t *testing.T, newRepos func(t *testing.T) *repository.Repositories) {
+	ctx := context.Background()
+
+	t.Run("commits outer and nested changes", func(t *testing.T) {
+		r := newRepos(t)
+
+		err := r.WithTx(ctx, func(tx *repository.Repositories) error {
+			mustSave(t, tx, "outer@example.com")
+
+			return tx.WithTx(ctx, func(nested *repository.Repositories) error {
+				// nested transaction sees uncommitted changes of the outer one
+				assertExists(t, nested, "outer@example.com", true)
+				mustSave(t, nested, "nested@example.com")
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatalf("WithTx: %v", err)
+		}
+
+		assertExists(t, r, "outer@example.com", true)
+		assertExists(t, r, "nested@example.com", true)
+	})
+
+	t.Run("rolls back nested changes only", func(t *testing.T) {
+		r := newRepos(t)
+
+		err := r.WithTx(ctx, func(tx *repository.Repositories) error {
+			mustSave(t, tx, "outer@example.com")
+
+			err := tx.WithTx(ctx, func(nested *repository.Repositories) error {
+				mustSave(t, nested, "nested@example.com")
+				return errAbort
+			})
+			if !errors.Is(err, errAbort) {
+				t.Errorf("nested WithTx error = %v; want %v", err, errAbort)
+			}
+
+			// outer transaction stays usable after nested one is rolled back
+			assertExists(t, tx, "nested@example.com", false)
+			mustSave(t, tx, "after@example.com")
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WithTx: %v", err)
+		}
+
+		assertExists(t, r, "outer@example.com", true)
+		assertExists(t, r, "nested@example.com", false)
+		assertExists(t, r, "after@example.com", true)
+	})
+
+	t.Run("rolls back committed nested changes with outer", func(t *testing.T) {
+		r := newRepos(t)
+
+		err := r.WithTx(ctx, func(tx *repository.Repositories) error {
+			mustSave(t, tx, "outer@example.com")
+
+			if err := tx.WithTx(ctx, func(nested *repository.Repositories) error {
+				mustSave(t, nested, "nested@example.com")
+				return nil
+			}); err != nil {
+				t.Errorf("nested WithTx: %v", err)
+			}
+
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("WithTx error = %v; want %v", err, errAbort)
+		}
+
+		assertExists(t, r, "outer@example.com", false)
+		assertExists(t, r, "nested@example.com", false)
+	})
+}
+
+func mustSave(t *testing.T, r *repository.Repositories, email string) {
+	t.Helper()
+
+	_, err := r.User.SaveUser(context.Background(), entity.UserSignupForm{
+		FirstName: "Ann",
+		LastName:  "Lee",
+		Email:     email,
+		Password:  "Password-1",
+	})
+	if err != nil {
+		t.Fatalf("save user %s: %v", email, err)
+	}
+}
+
+func assertExists(t *testing.T, r *repository.Repositories, email string, want bool) {
+	t.Helper()
+
+	got, err := r.User.Exists(context.Background(), email)
+	if err != nil {
+		t.Fatalf("exists %s: %v", email, err)
+	}
+	if got != want {
+		t.Errorf("exists %s = %v; want %v", email, got, want)
+	}
+}
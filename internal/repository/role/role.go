@@ -0,0 +1,155 @@
+package role
+
+import (
+	"context"
+	"errors"
+	"inditilla/internal/entity"
+	"inditilla/internal/repository/db"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type RoleRepo interface {
+	GetAll(context.Context) ([]entity.Role, error)
+	GetUserRoles(context.Context, int) ([]string, error)
+	GetPermissions(context.Context, []string) ([]string, error)
+	Assign(context.Context, int, string) error
+	Remove(context.Context, int, string) error
+}
+
+type roleRepo struct {
+	db db.DB
+}
+
+func NewRoleRepo(db db.DB) *roleRepo {
+	return &roleRepo{
+		db: db,
+	}
+}
+
+// GetAll returns all roles with permissions granted by them
+func (r *roleRepo) GetAll(ctx context.Context) ([]entity.Role, error) {
+	query := `
+		SELECT r.id, r.name, COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		GROUP BY r.id, r.name
+		ORDER BY r.name
+		`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (entity.Role, error) {
+		var role entity.Role
+		err := row.Scan(&role.Id, &role.Name, &role.Permissions)
+		return role, err
+	})
+}
+
+// GetUserRoles returns names of roles assigned to user with given id
+func (r *roleRepo) GetUserRoles(ctx context.Context, userId int) ([]string, error) {
+	query := `
+		SELECT r.name
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY r.name
+		`
+
+	rows, err := r.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// GetPermissions returns names of permissions granted by any of given roles
+func (r *roleRepo) GetPermissions(ctx context.Context, roles []string) ([]string, error) {
+	query := `
+		SELECT DISTINCT p.name
+		FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		JOIN roles r ON r.id = rp.role_id
+		WHERE r.name = ANY($1)
+		ORDER BY p.name
+		`
+
+	rows, err := r.db.Query(ctx, query, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// Assign assigns role with given name to user. It returns entity.ErrNoRecord if there is
+// no such role or user. Assigning role the user already has is not an error
+func (r *roleRepo) Assign(ctx context.Context, userId int, role string) error {
+	query := `
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT u.id, r.id FROM users u, roles r
+		WHERE u.id = $1 AND r.name = $2
+		ON CONFLICT DO NOTHING
+		RETURNING user_id
+		`
+
+	var id int
+
+	err := r.db.QueryRow(ctx, query, userId, role).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return r.checkExists(ctx, userId, role)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Remove removes role with given name from user. It returns entity.ErrNoRecord if user
+// does not have such role
+func (r *roleRepo) Remove(ctx context.Context, userId int, role string) error {
+	query := `
+		DELETE FROM user_roles
+		WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)
+		`
+
+	tag, err := r.db.Exec(ctx, query, userId, role)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return entity.ErrNoRecord
+	}
+
+	return nil
+}
+
+// checkExists distinguishes already assigned role from missing user or role
+// when nothing was inserted by Assign
+func (r *roleRepo) checkExists(ctx context.Context, userId int, role string) error {
+	var exists bool
+
+	query := `SELECT EXISTS(
+		SELECT true
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1 AND r.name = $2
+		)`
+
+	if err := r.db.QueryRow(ctx, query, userId, role).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return entity.ErrNoRecord
+	}
+
+	return nil
+}
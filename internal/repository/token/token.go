@@ -0,0 +1,221 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"inditilla/internal/entity"
+	"inditilla/internal/repository/db"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type TokenRepo interface {
+	SaveRefreshToken(context.Context, *entity.RefreshToken) error
+	GetRefreshToken(context.Context, string) (entity.RefreshToken, error)
+	MarkRefreshTokenUsed(context.Context, int) error
+	RevokeRefreshTokenFamily(context.Context, string) error
+	RevokeUserRefreshTokens(context.Context, int) ([]string, error)
+	IsFamilyRevoked(context.Context, string) (bool, error)
+	RevokeAccessToken(context.Context, string, int, time.Time) error
+	IsAccessTokenRevoked(context.Context, string) (bool, error)
+	SaveOneTimeToken(context.Context, *entity.OneTimeToken) error
+	ConsumeOneTimeToken(context.Context, string, string) (entity.OneTimeToken, error)
+	DeleteOneTimeTokens(context.Context, int, string) error
+	GetPendingEmail(context.Context, int) (string, error)
+}
+
+type tokenRepo struct {
+	db db.DB
+}
+
+func NewTokenRepo(db db.DB) *tokenRepo {
+	return &tokenRepo{
+		db: db,
+	}
+}
+
+func (r *tokenRepo) SaveRefreshToken(ctx context.Context, t *entity.RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+
+	return r.db.QueryRow(ctx, query, t.UserId, t.FamilyId, t.TokenHash, t.ExpiresAt.UTC()).Scan(&t.Id, &t.CreatedAt)
+}
+
+func (r *tokenRepo) GetRefreshToken(ctx context.Context, hash string) (entity.RefreshToken, error) {
+	t := entity.RefreshToken{}
+
+	query := `SELECT id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at
+		FROM refresh_tokens WHERE token_hash = $1`
+
+	err := r.db.QueryRow(ctx, query, hash).Scan(&t.Id, &t.UserId, &t.FamilyId, &t.TokenHash, &t.ExpiresAt, &t.UsedAt, &t.RevokedAt, &t.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.RefreshToken{}, entity.ErrNoRecord
+		}
+		return entity.RefreshToken{}, err
+	}
+
+	return t, nil
+}
+
+// MarkRefreshTokenUsed marks refresh token as used. Update is conditional, so if
+// token was already used (e.g. two concurrent refreshes with the same token)
+// entity.ErrRefreshTokenReuse is returned
+func (r *tokenRepo) MarkRefreshTokenUsed(ctx context.Context, id int) error {
+	query := `
+		UPDATE refresh_tokens
+		SET used_at = $1
+		WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL
+		`
+
+	tag, err := r.db.Exec(ctx, query, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return entity.ErrRefreshTokenReuse
+	}
+
+	return nil
+}
+
+func (r *tokenRepo) RevokeRefreshTokenFamily(ctx context.Context, familyId string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE family_id = $2 AND revoked_at IS NULL
+		`
+
+	_, err := r.db.Exec(ctx, query, time.Now().UTC(), familyId)
+
+	return err
+}
+
+// RevokeUserRefreshTokens revokes all active refresh tokens of given user and
+// returns ids of revoked families
+func (r *tokenRepo) RevokeUserRefreshTokens(ctx context.Context, userId int) ([]string, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+		RETURNING family_id
+		`
+
+	rows, err := r.db.Query(ctx, query, time.Now().UTC(), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	families, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	return families, nil
+}
+
+func (r *tokenRepo) IsFamilyRevoked(ctx context.Context, familyId string) (bool, error) {
+	var revoked bool
+
+	query := `SELECT EXISTS(
+		SELECT true
+		FROM refresh_tokens
+		WHERE family_id = $1 AND revoked_at IS NOT NULL
+		)`
+
+	err := r.db.QueryRow(ctx, query, familyId).Scan(&revoked)
+
+	return revoked, err
+}
+
+// RevokeAccessToken saves id of access token as revoked until its expiration date.
+// Revocations of already expired tokens are deleted along the way
+func (r *tokenRepo) RevokeAccessToken(ctx context.Context, jti string, userId int, expiresAt time.Time) error {
+	query := `INSERT INTO revoked_tokens (jti, user_id, expires_at)
+		VALUES ($1, $2, $3) ON CONFLICT (jti) DO NOTHING`
+
+	_, err := r.db.Exec(ctx, query, jti, userId, expiresAt.UTC())
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1`, time.Now().UTC())
+
+	return err
+}
+
+func (r *tokenRepo) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+
+	query := `SELECT EXISTS(
+		SELECT true
+		FROM revoked_tokens
+		WHERE jti = $1
+		)`
+
+	err := r.db.QueryRow(ctx, query, jti).Scan(&revoked)
+
+	return revoked, err
+}
+
+func (r *tokenRepo) SaveOneTimeToken(ctx context.Context, t *entity.OneTimeToken) error {
+	query := `INSERT INTO one_time_tokens (user_id, purpose, token_hash, email, expires_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+
+	return r.db.QueryRow(ctx, query, t.UserId, t.Purpose, t.TokenHash, t.Email, t.ExpiresAt.UTC()).Scan(&t.Id, &t.CreatedAt)
+}
+
+// ConsumeOneTimeToken marks token with given hash and purpose as used and returns it.
+// It returns entity.ErrNoRecord if there is no such token or it is expired or already used
+func (r *tokenRepo) ConsumeOneTimeToken(ctx context.Context, hash, purpose string) (entity.OneTimeToken, error) {
+	t := entity.OneTimeToken{}
+	now := time.Now().UTC()
+
+	query := `
+		UPDATE one_time_tokens
+		SET used_at = $1
+		WHERE token_hash = $2 AND purpose = $3 AND used_at IS NULL AND expires_at > $1
+		RETURNING id, user_id, purpose, token_hash, email, expires_at, used_at, created_at
+		`
+
+	err := r.db.QueryRow(ctx, query, now, hash, purpose).Scan(&t.Id, &t.UserId, &t.Purpose, &t.TokenHash, &t.Email, &t.ExpiresAt, &t.UsedAt, &t.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.OneTimeToken{}, entity.ErrNoRecord
+		}
+		return entity.OneTimeToken{}, err
+	}
+
+	return t, nil
+}
+
+// DeleteOneTimeTokens deletes all tokens of given purpose issued to user, so only
+// the latest token sent to the user could be used
+func (r *tokenRepo) DeleteOneTimeTokens(ctx context.Context, userId int, purpose string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM one_time_tokens WHERE user_id = $1 AND purpose = $2`, userId, purpose)
+
+	return err
+}
+
+// GetPendingEmail returns email of the latest valid email verification token issued to user
+// for an email other than user's current one, or empty string if there is no such token
+func (r *tokenRepo) GetPendingEmail(ctx context.Context, userId int) (string, error) {
+	query := `SELECT t.email FROM one_time_tokens t JOIN users u ON u.id = t.user_id
+		WHERE t.user_id = $1 AND t.purpose = $2 AND t.used_at IS NULL AND t.expires_at > $3
+		AND t.email IS NOT NULL AND t.email <> u.email
+		ORDER BY t.created_at DESC LIMIT 1`
+
+	var email string
+
+	err := r.db.QueryRow(ctx, query, userId, entity.TokenPurposeEmailVerification, time.Now().UTC()).Scan(&email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return email, nil
+}
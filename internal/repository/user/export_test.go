@@ -0,0 +1,12 @@
+package user
+
+import "inditilla/internal/repository/db"
+
+// NewUserRepoWithCost returns user repository hashing passwords with given bcrypt cost,
+// so tests do not spend seconds on every hash
+func NewUserRepoWithCost(db db.DB, cost int) *userRepo {
+	r := NewUserRepo(db)
+	r.passwordCost = cost
+
+	return r
+}
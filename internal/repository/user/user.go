@@ -5,6 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"inditilla/internal/entity"
+	"inditilla/internal/repository/db"
+	"inditilla/pkg/password"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -17,21 +21,35 @@ type UserRepo interface {
 	Authenticate(context.Context, string, string) (entity.UserEntity, error)
 	Exists(context.Context, string) (bool, error)
 	GetById(context.Context, int) (entity.UserEntity, error)
+	GetByEmail(context.Context, string) (entity.UserEntity, error)
 	Update(context.Context, *entity.UserEntity, bool) error
+	List(context.Context, entity.UserFilter) ([]entity.UserEntity, error)
+	SetDisabled(context.Context, int, bool) error
+	SetEmailVerified(context.Context, int, string) error
+	Delete(context.Context, int) error
 }
 
+// PasswordCost is bcrypt cost passwords are hashed with
+const PasswordCost = 15
+
+// userColumns are users table columns selected into entity.UserEntity by scanUser
+const userColumns = `id, first_name, last_name, email, hashed_password, email_verified_at, disabled_at, created_at, version,
+	display_name, bio, phone, date_of_birth, locale, timezone, website, avatar`
+
 type userRepo struct {
-	db *pgx.Conn
+	db           db.DB
+	passwordCost int
 }
 
-func NewUserRepo(db *pgx.Conn) *userRepo {
+func NewUserRepo(db db.DB) *userRepo {
 	return &userRepo{
-		db: db,
+		db:           db,
+		passwordCost: PasswordCost,
 	}
 }
 
 func (r *userRepo) SaveUser(ctx context.Context, u entity.UserSignupForm) (int, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), 15)
+	hashedPassword, err := password.Hash(ctx, u.Password, r.passwordCost)
 	if err != nil {
 		return 0, err
 	}
@@ -57,13 +75,10 @@ func (r *userRepo) SaveUser(ctx context.Context, u entity.UserSignupForm) (int,
 	return id, nil
 }
 
-func (r *userRepo) Authenticate(ctx context.Context, email string, password string) (entity.UserEntity, error) {
-	user := entity.UserEntity{}
-	var hashedPassword []byte
-
-	query := `SELECT * FROM users WHERE email=$1`
+func (r *userRepo) Authenticate(ctx context.Context, email string, plain string) (entity.UserEntity, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email=$1`
 
-	err := r.db.QueryRow(ctx, query, email).Scan(&user.Id, &user.FirstName, &user.LastName, &user.Email, &hashedPassword, &user.CreatedAt)
+	user, err := scanUser(r.db.QueryRow(ctx, query, email))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return entity.UserEntity{}, entity.ErrInvalidCredentials
@@ -72,16 +87,17 @@ func (r *userRepo) Authenticate(ctx context.Context, email string, password stri
 		}
 	}
 
-	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	err = password.Compare(ctx, []byte(user.Password), plain)
 	if err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			fmt.Println("nah")
 			return entity.UserEntity{}, entity.ErrInvalidCredentials
 		} else {
 			return entity.UserEntity{}, err
 		}
 	}
 
+	user.Password = ""
+
 	return user, nil
 }
 
@@ -104,11 +120,9 @@ func (r *userRepo) Exists(ctx context.Context, email string) (bool, error) {
 }
 
 func (r *userRepo) GetById(ctx context.Context, id int) (entity.UserEntity, error) {
-	user := entity.UserEntity{}
-	var hashedPassword []byte
+	query := `SELECT ` + userColumns + ` FROM users WHERE id=$1`
 
-	query := `SELECT * FROM users WHERE id=$1`
-	err := r.db.QueryRow(ctx, query, id).Scan(&user.Id, &user.FirstName, &user.LastName, &user.Email, &hashedPassword, &user.CreatedAt)
+	user, err := scanUser(r.db.QueryRow(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return entity.UserEntity{}, entity.ErrNoRecord
@@ -116,16 +130,30 @@ func (r *userRepo) GetById(ctx context.Context, id int) (entity.UserEntity, erro
 		return entity.UserEntity{}, err
 	}
 
-	user.Password = string(hashedPassword)
+	return user, nil
+}
+
+func (r *userRepo) GetByEmail(ctx context.Context, email string) (entity.UserEntity, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email=$1`
+
+	user, err := scanUser(r.db.QueryRow(ctx, query, email))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.UserEntity{}, entity.ErrNoRecord
+		}
+		return entity.UserEntity{}, err
+	}
 
 	return user, nil
 }
 
+// Update updates user and increments user's version. It returns entity.ErrEditConflict if
+// user's version differs from given one, i.e. user was updated after it was read
 func (r *userRepo) Update(ctx context.Context, user *entity.UserEntity, isPasswordChanged bool) error {
 	var err error
 	hashedPassword := []byte(user.Password)
 	if isPasswordChanged {
-		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(user.Password), 15)
+		hashedPassword, err = password.Hash(ctx, user.Password, r.passwordCost)
 		if err != nil {
 			return err
 		}
@@ -133,14 +161,20 @@ func (r *userRepo) Update(ctx context.Context, user *entity.UserEntity, isPasswo
 
 	query := `
 		UPDATE users 
-		SET first_name = $1, last_name = $2, email = $3, hashed_password = $4
-		WHERE id = $5
+		SET first_name = $1, last_name = $2, email = $3, hashed_password = $4, version = version + 1,
+			display_name = $7, bio = $8, phone = $9, date_of_birth = NULLIF($10::text, '')::date, locale = $11, timezone = $12, website = $13,
+			avatar = $14
+		WHERE id = $5 AND version = $6
+		RETURNING version
 		`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	_, err = r.db.Exec(ctx, query, user.FirstName, user.LastName, user.Email, hashedPassword, user.Id)
+	p := user.Profile
+
+	err = r.db.QueryRow(ctx, query, user.FirstName, user.LastName, user.Email, hashedPassword, user.Id, user.Version,
+		p.DisplayName, p.Bio, p.Phone, p.DateOfBirth, p.Locale, p.Timezone, p.Website, user.Avatar).Scan(&user.Version)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return entity.ErrEditConflict
@@ -150,3 +184,168 @@ func (r *userRepo) Update(ctx context.Context, user *entity.UserEntity, isPasswo
 
 	return nil
 }
+
+// List returns users matching given filter. Keyset pagination is used - rows are
+// ordered by sort column and id, so page starts right after the last user of previous page
+func (r *userRepo) List(ctx context.Context, f entity.UserFilter) ([]entity.UserEntity, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if f.EmailPrefix != "" {
+		conditions = append(conditions, "email LIKE "+arg(escapeLike(f.EmailPrefix)+"%"))
+	}
+	if f.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= "+arg(f.CreatedFrom.UTC()))
+	}
+	if f.CreatedTo != nil {
+		conditions = append(conditions, "created_at < "+arg(f.CreatedTo.UTC()))
+	}
+
+	switch f.Status {
+	case entity.UserStatusActive:
+		conditions = append(conditions, "disabled_at IS NULL")
+	case entity.UserStatusDisabled:
+		conditions = append(conditions, "disabled_at IS NOT NULL")
+	}
+
+	column, ok := sortColumns[f.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort column: %q", f.SortBy)
+	}
+
+	direction, comparison := "ASC", ">"
+	if f.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if f.After != nil {
+		var value interface{}
+		switch column {
+		case "id":
+			value = f.After.Id
+		case "email":
+			value = f.After.Email
+		case "created_at":
+			value = f.After.CreatedAt
+		}
+
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)", column, comparison, arg(value), arg(f.After.Id)))
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", column, direction, direction, arg(f.Limit))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (entity.UserEntity, error) {
+		return scanUser(row)
+	})
+}
+
+// SetDisabled disables or enables user with given id
+func (r *userRepo) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	var disabledAt *time.Time
+	if disabled {
+		now := time.Now().UTC()
+		disabledAt = &now
+	}
+
+	tag, err := r.db.Exec(ctx, `UPDATE users SET disabled_at = $1 WHERE id = $2`, disabledAt, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return entity.ErrNoRecord
+	}
+
+	return nil
+}
+
+// SetEmailVerified sets user's email to given verified email
+func (r *userRepo) SetEmailVerified(ctx context.Context, id int, email string) error {
+	query := `UPDATE users SET email = $1, email_verified_at = $2, version = version + 1 WHERE id = $3`
+
+	tag, err := r.db.Exec(ctx, query, email, time.Now().UTC(), id)
+	if err != nil {
+		var pgError *pgconn.PgError
+
+		if errors.As(err, &pgError) {
+			if pgError.Code == "23505" {
+				return entity.ErrDuplicateEmail
+			}
+		}
+
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return entity.ErrNoRecord
+	}
+
+	return nil
+}
+
+// Delete deletes user with given id, all user's tokens and roles are deleted with it
+func (r *userRepo) Delete(ctx context.Context, id int) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return entity.ErrNoRecord
+	}
+
+	return nil
+}
+
+// sortColumns maps sort fields of users list to table columns
+var sortColumns = map[string]string{
+	"id":        "id",
+	"email":     "email",
+	"createdAt": "created_at",
+}
+
+// escapeLike escapes LIKE pattern special characters in given string
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// scanUser scans row selected with userColumns into user entity. Hashed password
+// is put into Password field
+func scanUser(row pgx.Row) (entity.UserEntity, error) {
+	user := entity.UserEntity{}
+	var (
+		hashedPassword []byte
+		dateOfBirth    *time.Time
+	)
+
+	p := &user.Profile
+
+	err := row.Scan(&user.Id, &user.FirstName, &user.LastName, &user.Email, &hashedPassword, &user.EmailVerifiedAt, &user.DisabledAt, &user.CreatedAt, &user.Version,
+		&p.DisplayName, &p.Bio, &p.Phone, &dateOfBirth, &p.Locale, &p.Timezone, &p.Website, &user.Avatar)
+	if err != nil {
+		return entity.UserEntity{}, err
+	}
+
+	user.Password = string(hashedPassword)
+	if dateOfBirth != nil {
+		p.DateOfBirth = dateOfBirth.Format(time.DateOnly)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,59 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inditilla/internal/repository/db/dbtest"
+	"inditilla/internal/repository/user"
+	"inditilla/internal/repository/user/usertest"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	pool        *pgxpool.Pool
+	unavailable error
+)
+
+func TestMain(m *testing.M) {
+	url, stop, err := dbtest.Postgres()
+	if err != nil {
+		if !errors.Is(err, dbtest.ErrUnavailable) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		unavailable = err
+		os.Exit(m.Run())
+	}
+
+	pool, err = pgxpool.New(context.Background(), url)
+	if err != nil {
+		stop()
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+
+	pool.Close()
+	stop()
+	os.Exit(code)
+}
+
+func TestUserRepo(t *testing.T) {
+	if unavailable != nil {
+		t.Skip(unavailable)
+	}
+
+	usertest.Run(t, func(t *testing.T) user.UserRepo {
+		if _, err := pool.Exec(context.Background(), `TRUNCATE users RESTART IDENTITY CASCADE`); err != nil {
+			t.Fatalf("truncate users: %v", err)
+		}
+
+		return user.NewUserRepoWithCost(pool, bcrypt.MinCost)
+	})
+}
@@ -0,0 +1,507 @@
+// Package usertest provides conformance tests of user.UserRepo contract. Every UserRepo
+// implementation is expected to pass them, so implementations are interchangeable
+package usertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inditilla/internal/entity"
+	"inditilla/internal/repository/user"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory returns new empty repository. It is called once per test
+type Factory func(t *testing.T) user.UserRepo
+
+// concurrency is number of goroutines racing in concurrency tests
+const concurrency = 8
+
+// Run runs conformance tests against repositories created by 'newRepo'. Contract pinned down by them:
+//
+//   - SaveUser assigns distinct positive ids and returns entity.ErrDuplicateEmail for taken email
+//   - emails are stored as given and matched exactly, so emails differing in case are different users
+//   - Authenticate returns entity.ErrInvalidCredentials both for unknown email and wrong password,
+//     password is never returned
+//   - Exists returns false without error for unknown email
+//   - GetById returns entity.ErrNoRecord for unknown id and hashed password in Password field
+//   - Update increments version and returns entity.ErrEditConflict for unknown id or stale version,
+//     password is hashed again only if it was changed, profile fields are stored as given
+//   - CreatedAt is time of SaveUser in UTC, List filters by it in UTC
+//   - concurrent calls are safe and conflicting ones fail with the errors above
+func Run(t *testing.T, newRepo Factory) {
+	t.Run("SaveUser", func(t *testing.T) { testSaveUser(t, newRepo) })
+	t.Run("Authenticate", func(t *testing.T) { testAuthenticate(t, newRepo) })
+	t.Run("Exists", func(t *testing.T) { testExists(t, newRepo) })
+	t.Run("GetById", func(t *testing.T) { testGetById(t, newRepo) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, newRepo) })
+	t.Run("List", func(t *testing.T) { testList(t, newRepo) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, newRepo) })
+}
+
+func testSaveUser(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+
+	t.Run("assigns distinct ids", func(t *testing.T) {
+		r := newRepo(t)
+
+		first := mustSave(t, r, signup("first@example.com"))
+		second := mustSave(t, r, signup("second@example.com"))
+
+		if first <= 0 || second <= 0 || first == second {
+			t.Fatalf("ids = %d, %d; want distinct positive ids", first, second)
+		}
+	})
+
+	t.Run("rejects duplicate email", func(t *testing.T) {
+		r := newRepo(t)
+		mustSave(t, r, signup("ann@example.com"))
+
+		_, err := r.SaveUser(ctx, signup("ann@example.com"))
+		if !errors.Is(err, entity.ErrDuplicateEmail) {
+			t.Fatalf("err = %v; want %v", err, entity.ErrDuplicateEmail)
+		}
+	})
+
+	t.Run("treats emails differing in case as different", func(t *testing.T) {
+		r := newRepo(t)
+		mustSave(t, r, signup("ann@example.com"))
+
+		if _, err := r.SaveUser(ctx, signup("Ann@Example.com")); err != nil {
+			t.Fatalf("err = %v; want nil", err)
+		}
+	})
+
+	t.Run("stores hashed password", func(t *testing.T) {
+		r := newRepo(t)
+		form := signup("ann@example.com")
+		id := mustSave(t, r, form)
+
+		u := mustGet(t, r, id)
+		if u.Password == "" || u.Password == form.Password {
+			t.Fatalf("password = %q; want hash of the password", u.Password)
+		}
+	})
+}
+
+func testAuthenticate(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+	form := signup("ann@example.com")
+
+	t.Run("returns user without password", func(t *testing.T) {
+		r := newRepo(t)
+		id := mustSave(t, r, form)
+
+		u, err := r.Authenticate(ctx, form.Email, form.Password)
+		if err != nil {
+			t.Fatalf("err = %v; want nil", err)
+		}
+
+		if u.Id != id || u.Email != form.Email || u.FirstName != form.FirstName || u.LastName != form.LastName {
+			t.Errorf("user = %+v; want user %d saved from %+v", u, id, form)
+		}
+		if u.Password != "" {
+			t.Errorf("password = %q; want empty", u.Password)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{"unknown email", "bob@example.com", form.Password},
+		{"wrong password", form.Email, "wrong-password"},
+		{"password in other case", form.Email, swapCase(form.Password)},
+		{"email in other case", swapCase(form.Email), form.Password},
+		{"empty password", form.Email, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			r := newRepo(t)
+			mustSave(t, r, form)
+
+			u, err := r.Authenticate(ctx, tt.email, tt.password)
+			if !errors.Is(err, entity.ErrInvalidCredentials) {
+				t.Fatalf("err = %v; want %v", err, entity.ErrInvalidCredentials)
+			}
+			if u.Id != 0 || u.Password != "" {
+				t.Errorf("user = %+v; want zero user", u)
+			}
+		})
+	}
+}
+
+func testExists(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+	r := newRepo(t)
+	mustSave(t, r, signup("ann@example.com"))
+
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"ann@example.com", true},
+		{"bob@example.com", false},
+		{"ANN@example.com", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		exists, err := r.Exists(ctx, tt.email)
+		if err != nil {
+			t.Errorf("Exists(%q): err = %v; want nil", tt.email, err)
+		}
+		if exists != tt.want {
+			t.Errorf("Exists(%q) = %t; want %t", tt.email, exists, tt.want)
+		}
+	}
+}
+
+func testGetById(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+
+	t.Run("returns saved user", func(t *testing.T) {
+		r := newRepo(t)
+		form := signup("ann@example.com")
+		id := mustSave(t, r, form)
+
+		u := mustGet(t, r, id)
+		if u.Id != id || u.Email != form.Email || u.FirstName != form.FirstName || u.LastName != form.LastName {
+			t.Errorf("user = %+v; want user %d saved from %+v", u, id, form)
+		}
+		if u.Version != 1 {
+			t.Errorf("version = %d; want 1", u.Version)
+		}
+		if u.CreatedAt.IsZero() {
+			t.Error("created at is zero")
+		}
+		if u.EmailVerifiedAt != nil || u.DisabledAt != nil {
+			t.Errorf("user = %+v; want not verified and not disabled user", u)
+		}
+	})
+
+	t.Run("returns ErrNoRecord for unknown id", func(t *testing.T) {
+		r := newRepo(t)
+		id := mustSave(t, r, signup("ann@example.com"))
+
+		_, err := r.GetById(ctx, id+1)
+		if !errors.Is(err, entity.ErrNoRecord) {
+			t.Fatalf("err = %v; want %v", err, entity.ErrNoRecord)
+		}
+	})
+}
+
+func testUpdate(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+	form := signup("ann@example.com")
+	profile := entity.Profile{
+		DisplayName: "Bobby",
+		Bio:         "Writes tests",
+		Phone:       "+14155552671",
+		DateOfBirth: "1990-02-28",
+		Locale:      "en-US",
+		Timezone:    "Europe/Berlin",
+		Website:     "https://example.com",
+	}
+
+	t.Run("updates user and increments version", func(t *testing.T) {
+		r := newRepo(t)
+		id := mustSave(t, r, form)
+
+		u := mustGet(t, r, id)
+		u.FirstName, u.LastName, u.Email = "Bob", "Stone", "bob@example.com"
+		u.Profile = profile
+		u.Avatar = "avatars/1/abc"
+
+		if err := r.Update(ctx, &u, false); err != nil {
+			t.Fatalf("err = %v; want nil", err)
+		}
+		if u.Version != 2 {
+			t.Errorf("version = %d; want 2", u.Version)
+		}
+
+		got := mustGet(t, r, id)
+		if got.FirstName != "Bob" || got.LastName != "Stone" || got.Email != "bob@example.com" || got.Version != 2 {
+			t.Errorf("user = %+v; want updated user with version 2", got)
+		}
+		if got.Profile != profile {
+			t.Errorf("profile = %+v; want %+v", got.Profile, profile)
+		}
+		if got.Avatar != "avatars/1/abc" {
+			t.Errorf("avatar = %q; want %q", got.Avatar, "avatars/1/abc")
+		}
+	})
+
+	t.Run("clears profile fields", func(t *testing.T) {
+		r := newRepo(t)
+		id := mustSave(t, r, form)
+
+		u := mustGet(t, r, id)
+		u.Profile = profile
+		if err := r.Update(ctx, &u, false); err != nil {
+			t.Fatalf("err = %v; want nil", err)
+		}
+
+		u.Profile = entity.Profile{}
+		if err := r.Update(ctx, &u, false); err != nil {
+			t.Fatalf("err = %v; want nil", err)
+		}
+
+		if got := mustGet(t, r, id); got.Profile != (entity.Profile{}) {
+			t.Errorf("profile = %+v; want empty profile", got.Profile)
+		}
+	})
+
+	t.Run("keeps password if it was not changed", func(t *testing.T) {
+		r := newRepo(t)
+		id := mustSave(t, r, form)
+
+		u := mustGet(t, r, id)
+		u.FirstName = "Bob"
+		if err := r.Update(ctx, &u, false); err != nil {
+			t.Fatalf("err = %v; want nil", err)
+		}
+
+		if _, err := r.Authenticate(ctx, form.Email, form.Password); err != nil {
+			t.Fatalf("authenticate with old password: err = %v; want nil", err)
+		}
+	})
+
+	t.Run("changes password", func(t *testing.T) {
+		r := newRepo(t)
+		id := mustSave(t, r, form)
+
+		u := mustGet(t, r, id)
+		u.Password = "New-password-1"
+		if err := r.Update(ctx, &u, true); err != nil {
+			t.Fatalf("err = %v; want nil", err)
+		}
+
+		if _, err := r.Authenticate(ctx, form.Email, "New-password-1"); err != nil {
+			t.Errorf("authenticate with new password: err = %v; want nil", err)
+		}
+		if _, err := r.Authenticate(ctx, form.Email, form.Password); !errors.Is(err, entity.ErrInvalidCredentials) {
+			t.Errorf("authenticate with old password: err = %v; want %v", err, entity.ErrInvalidCredentials)
+		}
+	})
+
+	t.Run("returns ErrEditConflict for stale version", func(t *testing.T) {
+		r := newRepo(t)
+		id := mustSave(t, r, form)
+
+		u := mustGet(t, r, id)
+		stale := u
+
+		if err := r.Update(ctx, &u, false); err != nil {
+			t.Fatalf("err = %v; want nil", err)
+		}
+
+		stale.FirstName = "Bob"
+		if err := r.Update(ctx, &stale, false); !errors.Is(err, entity.ErrEditConflict) {
+			t.Fatalf("err = %v; want %v", err, entity.ErrEditConflict)
+		}
+
+		if got := mustGet(t, r, id); got.FirstName != form.FirstName {
+			t.Errorf("first name = %q; want %q", got.FirstName, form.FirstName)
+		}
+	})
+
+	t.Run("returns ErrEditConflict for unknown id", func(t *testing.T) {
+		r := newRepo(t)
+		id := mustSave(t, r, form)
+
+		u := mustGet(t, r, id)
+		u.Id++
+		if err := r.Update(ctx, &u, false); !errors.Is(err, entity.ErrEditConflict) {
+			t.Fatalf("err = %v; want %v", err, entity.ErrEditConflict)
+		}
+	})
+}
+
+func testList(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+
+	t.Run("filters by creation time in UTC", func(t *testing.T) {
+		r := newRepo(t)
+
+		// Database clock may differ a bit from clock of the test
+		before := time.Now().Add(-time.Minute)
+		id := mustSave(t, r, signup("ann@example.com"))
+		after := time.Now().Add(time.Minute)
+
+		u := mustGet(t, r, id)
+		if u.CreatedAt.Before(before) || !u.CreatedAt.Before(after) {
+			t.Errorf("created at = %v; want between %v and %v", u.CreatedAt, before.UTC(), after.UTC())
+		}
+
+		// Bounds are given in other time zone, they are the same instants
+		zone := time.FixedZone("UTC+6", 6*60*60)
+		tests := []struct {
+			name     string
+			from, to time.Time
+			want     int
+		}{
+			{"within range", before.In(zone), after.In(zone), 1},
+			{"before range", after.In(zone), after.Add(time.Hour).In(zone), 0},
+			{"after range", before.Add(-time.Hour).In(zone), before.In(zone), 0},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				users, err := r.List(ctx, entity.UserFilter{CreatedFrom: &tt.from, CreatedTo: &tt.to, SortBy: "id", Limit: 10})
+				if err != nil {
+					t.Fatalf("List: %v", err)
+				}
+				if len(users) != tt.want {
+					t.Errorf("List returned %d users; want %d", len(users), tt.want)
+				}
+			})
+		}
+	})
+}
+
+func testConcurrency(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+
+	t.Run("saves users with distinct emails", func(t *testing.T) {
+		r := newRepo(t)
+
+		ids := make([]int, concurrency)
+		errs := race(func(i int) (err error) {
+			ids[i], err = r.SaveUser(ctx, signup(fmt.Sprintf("user%d@example.com", i)))
+			return err
+		})
+
+		seen := make(map[int]bool)
+		for i, err := range errs {
+			if err != nil {
+				t.Fatalf("save user %d: err = %v; want nil", i, err)
+			}
+			if seen[ids[i]] {
+				t.Fatalf("id %d is assigned twice", ids[i])
+			}
+			seen[ids[i]] = true
+		}
+	})
+
+	t.Run("saves only one user with the same email", func(t *testing.T) {
+		r := newRepo(t)
+
+		errs := race(func(int) error {
+			_, err := r.SaveUser(ctx, signup("ann@example.com"))
+			return err
+		})
+
+		checkOneWinner(t, errs, entity.ErrDuplicateEmail)
+	})
+
+	t.Run("applies only one update of the same version", func(t *testing.T) {
+		r := newRepo(t)
+		id := mustSave(t, r, signup("ann@example.com"))
+		u := mustGet(t, r, id)
+
+		errs := race(func(i int) error {
+			update := u
+			update.FirstName = fmt.Sprintf("Name%d", i)
+			return r.Update(ctx, &update, false)
+		})
+
+		checkOneWinner(t, errs, entity.ErrEditConflict)
+
+		if got := mustGet(t, r, id); got.Version != 2 {
+			t.Errorf("version = %d; want 2", got.Version)
+		}
+	})
+}
+
+// race runs 'fn' in concurrent goroutines started at once and returns their errors
+func race(fn func(i int) error) []error {
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		errs  = make([]error, concurrency)
+	)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = fn(i)
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	return errs
+}
+
+// checkOneWinner checks that exactly one of racing calls succeeded and others failed with 'want'
+func checkOneWinner(t *testing.T, errs []error, want error) {
+	t.Helper()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, want):
+			t.Errorf("err = %v; want nil or %v", err, want)
+		}
+	}
+
+	if succeeded != 1 {
+		t.Errorf("%d calls succeeded; want 1", succeeded)
+	}
+}
+
+func signup(email string) entity.UserSignupForm {
+	return entity.UserSignupForm{
+		FirstName: "Ann",
+		LastName:  "Lee",
+		Email:     email,
+		Password:  "Password-1",
+	}
+}
+
+func mustSave(t *testing.T, r user.UserRepo, form entity.UserSignupForm) int {
+	t.Helper()
+
+	id, err := r.SaveUser(context.Background(), form)
+	if err != nil {
+		t.Fatalf("save user: %v", err)
+	}
+
+	return id
+}
+
+func mustGet(t *testing.T, r user.UserRepo, id int) entity.UserEntity {
+	t.Helper()
+
+	u, err := r.GetById(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get user %d: %v", id, err)
+	}
+
+	return u
+}
+
+// swapCase swaps case of ASCII letters of given string
+func swapCase(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		switch {
+		case 'a' <= c && c <= 'z':
+			b[i] = c - 'a' + 'A'
+		case 'A' <= c && c <= 'Z':
+			b[i] = c - 'A' + 'a'
+		}
+	}
+
+	return string(b)
+}
@@ -0,0 +1,247 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inditilla/internal/entity"
+	"inditilla/internal/repository"
+	"inditilla/internal/repository/lockout"
+	"inditilla/internal/repository/role"
+	"inditilla/internal/repository/token"
+	"inditilla/internal/repository/user"
+	usersvc "inditilla/internal/service/user"
+	"strconv"
+)
+
+type AdminService interface {
+	Roles(context.Context) ([]entity.Role, error)
+	AssignRole(context.Context, string, string) error
+	RemoveRole(context.Context, string, string) error
+	SeedAdmin(context.Context, string, string) error
+	CreateUser(context.Context, *entity.UserSignupForm) (int, error)
+	ListUsers(context.Context, *entity.UserListForm) (entity.UserPage, error)
+	DisableUser(context.Context, entity.Caller, string) error
+	EnableUser(context.Context, entity.Caller, string) error
+	DeleteUser(context.Context, entity.Caller, string) error
+	UnlockUser(context.Context, entity.Caller, string) error
+}
+
+type adminService struct {
+	repos       *repository.Repositories
+	userRepo    user.UserRepo
+	tokenRepo   token.TokenRepo
+	roleRepo    role.RoleRepo
+	lockoutRepo lockout.LockoutRepo
+}
+
+func NewAdminService(repos *repository.Repositories) *adminService {
+	return &adminService{
+		repos:       repos,
+		userRepo:    repos.User,
+		tokenRepo:   repos.Token,
+		roleRepo:    repos.Role,
+		lockoutRepo: repos.Lockout,
+	}
+}
+
+// withTx runs 'fn' with copy of admin service whose repositories work within single
+// transaction, so all changes 'fn' makes are committed or rolled back together
+func (as *adminService) withTx(ctx context.Context, fn func(tx *adminService) error) error {
+	return as.repos.WithTx(ctx, func(r *repository.Repositories) error {
+		tx := *as
+		tx.repos = r
+		tx.userRepo = r.User
+		tx.tokenRepo = r.Token
+		tx.roleRepo = r.Role
+		tx.lockoutRepo = r.Lockout
+
+		return fn(&tx)
+	})
+}
+
+func (as *adminService) Roles(ctx context.Context) ([]entity.Role, error) {
+	return as.roleRepo.GetAll(ctx)
+}
+
+// AssignRole assigns role to the user. Role is put into tokens issued on next log in
+func (as *adminService) AssignRole(ctx context.Context, idStr, roleName string) error {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return entity.ErrInvalidUserId
+	}
+
+	return as.roleRepo.Assign(ctx, id, roleName)
+}
+
+// RemoveRole removes role from the user and revokes all user's sessions, so tokens
+// that still carry removed role could not be used anymore
+func (as *adminService) RemoveRole(ctx context.Context, idStr, roleName string) error {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return entity.ErrInvalidUserId
+	}
+
+	return as.withTx(ctx, func(tx *adminService) error {
+		if err := tx.roleRepo.Remove(ctx, id, roleName); err != nil {
+			return err
+		}
+
+		_, err := tx.tokenRepo.RevokeUserRefreshTokens(ctx, id)
+		return err
+	})
+}
+
+// SeedAdmin makes sure user with given email exists and has admin role. User is
+// created with given password and verified email if there is no such user yet,
+// both are validated as on sign up
+func (as *adminService) SeedAdmin(ctx context.Context, email, password string) error {
+	return as.withTx(ctx, func(tx *adminService) error {
+		u, err := tx.userRepo.GetByEmail(ctx, email)
+		if err != nil {
+			if !errors.Is(err, entity.ErrNoRecord) {
+				return err
+			}
+
+			form := entity.UserSignupForm{
+				FirstName: "Admin",
+				LastName:  "Admin",
+				Email:     email,
+				Password:  password,
+			}
+			if !usersvc.IsRightSignUp(&form) {
+				return fmt.Errorf("admin: invalid initial admin (%s): %w", fieldErrors(form.FieldErrors), entity.ErrInvalidInputData)
+			}
+
+			u.Id, err = tx.createUser(ctx, form)
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.roleRepo.Assign(ctx, u.Id, entity.RoleAdmin)
+	})
+}
+
+// CreateUser creates user with verified email and returns user's id. It is for users
+// created by operator, so no verification email is sent
+func (as *adminService) CreateUser(ctx context.Context, u *entity.UserSignupForm) (int, error) {
+	if !usersvc.IsRightSignUp(u) {
+		return 0, entity.ErrInvalidInputData
+	}
+
+	var id int
+
+	err := as.withTx(ctx, func(tx *adminService) error {
+		var err error
+
+		id, err = tx.createUser(ctx, *u)
+		return err
+	})
+
+	return id, err
+}
+
+// createUser saves user and marks user's email verified
+func (as *adminService) createUser(ctx context.Context, u entity.UserSignupForm) (int, error) {
+	id, err := as.userRepo.SaveUser(ctx, u)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, as.userRepo.SetEmailVerified(ctx, id, u.Email)
+}
+
+// ListUsers returns single page of users matching given query and cursor of the next
+// page (empty if there are no more users)
+func (as *adminService) ListUsers(ctx context.Context, f *entity.UserListForm) (entity.UserPage, error) {
+	filter, ok := parseUserList(f)
+	if !ok {
+		return entity.UserPage{}, entity.ErrInvalidInputData
+	}
+
+	// One more user is requested to know whether there is next page
+	limit := filter.Limit
+	filter.Limit++
+
+	users, err := as.userRepo.List(ctx, filter)
+	if err != nil {
+		return entity.UserPage{}, err
+	}
+
+	page := entity.UserPage{Users: users}
+	if len(users) > limit {
+		page.Users = users[:limit]
+		page.NextCursor = encodeCursor(f.SortOrDefault(), page.Users[limit-1])
+	}
+
+	return page, nil
+}
+
+// DisableUser disables user and revokes all user's sessions. Disabled user can
+// not log in. Admins can not disable themselves
+func (as *adminService) DisableUser(ctx context.Context, caller entity.Caller, idStr string) error {
+	id, err := as.targetId(caller, idStr)
+	if err != nil {
+		return err
+	}
+
+	return as.withTx(ctx, func(tx *adminService) error {
+		if err := tx.userRepo.SetDisabled(ctx, id, true); err != nil {
+			return err
+		}
+
+		_, err := tx.tokenRepo.RevokeUserRefreshTokens(ctx, id)
+		return err
+	})
+}
+
+func (as *adminService) EnableUser(ctx context.Context, caller entity.Caller, idStr string) error {
+	id, err := as.targetId(caller, idStr)
+	if err != nil {
+		return err
+	}
+
+	return as.userRepo.SetDisabled(ctx, id, false)
+}
+
+// DeleteUser deletes user with all user's data. Admins can not delete themselves
+func (as *adminService) DeleteUser(ctx context.Context, caller entity.Caller, idStr string) error {
+	id, err := as.targetId(caller, idStr)
+	if err != nil {
+		return err
+	}
+
+	return as.userRepo.Delete(ctx, id)
+}
+
+// UnlockUser forgets failed log in attempts to user's account, so locked out user
+// could log in right away
+func (as *adminService) UnlockUser(ctx context.Context, caller entity.Caller, idStr string) error {
+	id, err := as.targetId(caller, idStr)
+	if err != nil {
+		return err
+	}
+
+	u, err := as.userRepo.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return as.lockoutRepo.Reset(ctx, entity.LoginEmailKey(u.Email))
+}
+
+// targetId parses id of the user admin action is performed on. Actions on
+// caller's own account are forbidden
+func (as *adminService) targetId(caller entity.Caller, idStr string) (int, error) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, entity.ErrInvalidUserId
+	}
+
+	if id == caller.Id {
+		return 0, entity.ErrForbidden
+	}
+
+	return id, nil
+}
@@ -0,0 +1,114 @@
+package admin
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"inditilla/internal/entity"
+	"inditilla/internal/service/validator"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// listCursor is a position in users list. It is given to client as opaque base64 string
+type listCursor struct {
+	Sort      string    `json:"s"`
+	Id        int       `json:"i"`
+	Email     string    `json:"e,omitempty"`
+	CreatedAt time.Time `json:"c,omitempty"`
+}
+
+// parseUserList validates users list query and converts it into repository filter
+func parseUserList(f *entity.UserListForm) (entity.UserFilter, bool) {
+	filter := entity.UserFilter{
+		EmailPrefix: strings.TrimSpace(f.Email),
+		Limit:       defaultListLimit,
+	}
+
+	if f.Limit != "" {
+		limit, err := strconv.Atoi(f.Limit)
+		f.CheckField(err == nil && limit > 0 && limit <= maxListLimit, "limit", "This field should be a number from 1 to "+strconv.Itoa(maxListLimit))
+		filter.Limit = limit
+	}
+
+	filter.CreatedFrom = parseTime(f, f.CreatedFrom, "created_from")
+	filter.CreatedTo = parseTime(f, f.CreatedTo, "created_to")
+
+	f.CheckField(validator.PermittedValue(f.Status, "", entity.UserStatusActive, entity.UserStatusDisabled), "status", "This field should be one of: active, disabled")
+	filter.Status = f.Status
+
+	sort := f.SortOrDefault()
+	filter.SortBy, filter.Desc = strings.TrimPrefix(sort, "-"), strings.HasPrefix(sort, "-")
+	f.CheckField(validator.PermittedValue(filter.SortBy, "id", "email", "createdAt"), "sort", "This field should be one of: id, email, createdAt (prefixed with '-' for descending order)")
+
+	if f.Cursor != "" {
+		after, ok := decodeCursor(f.Cursor, sort)
+		f.CheckField(ok, "cursor", "Invalid cursor for given sort order")
+		filter.After = after
+	}
+
+	return filter, f.Valid()
+}
+
+func parseTime(f *entity.UserListForm, value, key string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	f.CheckField(err == nil, key, "This field should be RFC 3339 date-time")
+
+	return &t
+}
+
+func encodeCursor(sort string, u entity.UserEntity) string {
+	c := listCursor{Sort: sort, Id: u.Id}
+
+	switch strings.TrimPrefix(sort, "-") {
+	case "email":
+		c.Email = u.Email
+	case "createdAt":
+		c.CreatedAt = u.CreatedAt
+	}
+
+	b, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor decodes cursor into the last user of previous page. Cursor must be
+// created for the same sort order
+func decodeCursor(raw, sort string) (*entity.UserEntity, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, false
+	}
+
+	var c listCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort {
+		return nil, false
+	}
+
+	return &entity.UserEntity{
+		Id:        c.Id,
+		Email:     c.Email,
+		CreatedAt: c.CreatedAt,
+	}, true
+}
+
+// fieldErrors formats validation errors of form fields as single line sorted by field
+func fieldErrors(errs map[string]string) string {
+	fields := make([]string, 0, len(errs))
+	for field, msg := range errs {
+		fields = append(fields, field+": "+msg)
+	}
+	sort.Strings(fields)
+
+	return strings.Join(fields, "; ")
+}
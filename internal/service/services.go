@@ -3,16 +3,33 @@ package service
 import (
 	"inditilla/internal/data"
 	"inditilla/internal/repository"
+	"inditilla/internal/service/admin"
 	"inditilla/internal/service/user"
+	"inditilla/pkg/blob"
+	"inditilla/pkg/health"
+	"inditilla/pkg/logger"
+	"time"
 )
 
+// healthCheckTimeout is time every health check must complete within
+const healthCheckTimeout = 2 * time.Second
+
 type Services struct {
-	User user.UserService
+	User   user.UserService
+	Admin  admin.AdminService
+	Health *health.Registry
 }
 
-// New returns Services struct with all services initialized (only User service in this case)
-func New(r *repository.Repositories, auth *user.Authorizer, tokenModel *data.TokenModel) *Services {
+// New returns Services struct with all services initialized
+func New(l logger.ILogger, r *repository.Repositories, auth *user.Authorizer, tokenModel *data.TokenModel, notifier *user.Notifier, blobs blob.BlobStore, policy user.Policy) *Services {
+	checks := health.New(healthCheckTimeout)
+	for name, checker := range r.Checks {
+		checks.Register(name, checker)
+	}
+
 	return &Services{
-		User: user.NewUserService(r.User, auth, tokenModel),
+		User:   user.WithTracing(user.NewUserService(l, r, auth, tokenModel, notifier, blobs, policy)),
+		Admin:  admin.NewAdminService(r),
+		Health: checks,
 	}
 }
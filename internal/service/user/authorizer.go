@@ -0,0 +1,104 @@
+package user
+
+import (
+	"fmt"
+	"inditilla/pkg/jwks"
+	"time"
+
+	"github.com/dgrijalva/jwt-go/v4"
+)
+
+// Authorizer signs and verifies access tokens. Tokens are signed either with shared
+// secret (HS256) or with private key (RS256, EdDSA). In the latter case 'kid' header
+// of the token selects one of verification keys, so signing key could be rotated
+// without invalidating tokens that are already issued
+type Authorizer struct {
+	method          jwt.SigningMethod
+	signingKey      interface{}
+	signingKeyId    string
+	verifyKeys      map[string]interface{}
+	jwks            jwks.Set
+	deadline        time.Duration
+	refreshDeadline time.Duration
+}
+
+// NewAuthorizer returns authorizer signing and verifying tokens with shared secret (HS256)
+func NewAuthorizer(signingKey []byte, deadline, refreshDeadline time.Duration) *Authorizer {
+	return &Authorizer{
+		method:          jwt.SigningMethodHS256,
+		signingKey:      signingKey,
+		jwks:            jwks.Set{Keys: []jwks.JWK{}},
+		deadline:        deadline,
+		refreshDeadline: refreshDeadline,
+	}
+}
+
+// NewKeyAuthorizer returns authorizer signing tokens with private key 'signingKeyId' and
+// verifying them with any of given keys. All keys must be of given algorithm
+func NewKeyAuthorizer(alg string, keys []jwks.Key, signingKeyId string, deadline, refreshDeadline time.Duration) (*Authorizer, error) {
+	a := &Authorizer{
+		signingKeyId:    signingKeyId,
+		verifyKeys:      make(map[string]interface{}, len(keys)),
+		deadline:        deadline,
+		refreshDeadline: refreshDeadline,
+	}
+
+	for _, key := range keys {
+		if key.Method.Alg() != alg {
+			return nil, fmt.Errorf("key %q is for %s signing method, expected %s", key.Id, key.Method.Alg(), alg)
+		}
+
+		if key.Id == signingKeyId {
+			if key.Signer == nil {
+				return nil, fmt.Errorf("signing key %q has no private key", key.Id)
+			}
+			a.method = key.Method
+			a.signingKey = key.Signer
+		}
+
+		a.verifyKeys[key.Id] = key.Public
+	}
+
+	if a.signingKey == nil {
+		return nil, fmt.Errorf("signing key %q not found", signingKeyId)
+	}
+
+	set, err := jwks.NewSet(keys)
+	if err != nil {
+		return nil, err
+	}
+	a.jwks = set
+
+	return a, nil
+}
+
+// sign signs given token with current signing key and sets its 'kid' header
+func (a *Authorizer) sign(token *jwt.Token) (string, error) {
+	if a.signingKeyId != "" {
+		token.Header["kid"] = a.signingKeyId
+	}
+
+	return token.SignedString(a.signingKey)
+}
+
+// keyfunc returns key the token should be verified with. Tokens signed with any
+// other method than the configured one are rejected
+func (a *Authorizer) keyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != a.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	// Shared secret is used for both signing and verification
+	if a.verifyKeys == nil {
+		return a.signingKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := a.verifyKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key id: %q", kid)
+	}
+
+	return key, nil
+}
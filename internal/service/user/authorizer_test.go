@@ -0,0 +1,214 @@
+package user
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"inditilla/pkg/jwks"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go/v4"
+)
+
+func TestKeyAuthorizerRotation(t *testing.T) {
+	old := ed25519Key("2024-01", 1)
+	current := ed25519Key("2024-02", 2)
+
+	before := mustKeyAuthorizer(t, "EdDSA", []jwks.Key{old, current}, old.Id)
+	after := mustKeyAuthorizer(t, "EdDSA", []jwks.Key{old, current}, current.Id)
+	retired := mustKeyAuthorizer(t, "EdDSA", []jwks.Key{current}, current.Id)
+
+	issuedBefore := mustSign(t, before)
+	issuedAfter := mustSign(t, after)
+
+	tests := []struct {
+		name    string
+		a       *Authorizer
+		token   string
+		wantKid string
+		wantErr string
+	}{
+		{"current key", after, issuedAfter, "2024-02", ""},
+		{"previous key kept for verification", after, issuedBefore, "2024-01", ""},
+		{"next key published before rotation", before, issuedAfter, "2024-02", ""},
+		{"retired key", retired, issuedBefore, "", "unknown signing key id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := verify(tt.a, tt.token)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse error = %v; want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if kid := token.Header["kid"]; kid != tt.wantKid {
+				t.Errorf("kid = %v; want %s", kid, tt.wantKid)
+			}
+		})
+	}
+}
+
+func TestKeyAuthorizerRejects(t *testing.T) {
+	key := ed25519Key("2024-01", 1)
+	unknown := ed25519Key("2024-01", 3)
+	a := mustKeyAuthorizer(t, "EdDSA", []jwks.Key{key}, key.Id)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{
+			"unknown kid",
+			signed(t, jwks.SigningMethodEdDSA, "2024-02", key.Signer),
+			"unknown signing key id",
+		},
+		{
+			"missing kid",
+			signed(t, jwks.SigningMethodEdDSA, "", key.Signer),
+			"unknown signing key id",
+		},
+		{
+			"known kid signed with other key",
+			signed(t, jwks.SigningMethodEdDSA, "2024-01", unknown.Signer),
+			"signature is invalid",
+		},
+		{
+			"RS256 token",
+			signed(t, jwt.SigningMethodRS256, "2024-01", rsaKey),
+			"unexpected signing method",
+		},
+		{
+			// Public key must not be accepted as shared secret
+			"HS256 token signed with public key",
+			signed(t, jwt.SigningMethodHS256, "2024-01", []byte(key.Public.(ed25519.PublicKey))),
+			"unexpected signing method",
+		},
+		{
+			"unsigned token",
+			signed(t, jwt.SigningMethodNone, "2024-01", jwt.UnsafeAllowNoneSignatureType),
+			"unexpected signing method",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verify(a, tt.token)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse error = %v; want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizerRejectsOtherMethod(t *testing.T) {
+	a := NewAuthorizer([]byte("secret"), time.Hour, time.Hour)
+	key := ed25519Key("2024-01", 1)
+
+	_, err := verify(a, signed(t, jwks.SigningMethodEdDSA, key.Id, key.Signer))
+	if err == nil || !strings.Contains(err.Error(), "unexpected signing method") {
+		t.Errorf("Parse error = %v; want unexpected signing method", err)
+	}
+}
+
+func TestNewKeyAuthorizer(t *testing.T) {
+	key := ed25519Key("2024-01", 1)
+	public := jwks.Key{Id: "2023-12", Method: jwks.SigningMethodEdDSA, Public: ed25519Key("", 2).Public}
+
+	tests := []struct {
+		name      string
+		alg       string
+		keys      []jwks.Key
+		signingId string
+		wantErr   string
+	}{
+		{"other algorithm", "RS256", []jwks.Key{key}, key.Id, "is for EdDSA signing method"},
+		{"signing key not found", "EdDSA", []jwks.Key{key}, "2024-02", "not found"},
+		{"signing key without private key", "EdDSA", []jwks.Key{key, public}, public.Id, "has no private key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyAuthorizer(tt.alg, tt.keys, tt.signingId, time.Hour, time.Hour)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewKeyAuthorizer error = %v; want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// verify parses token verifying it with key selected by authorizer. Key function is called
+// first on its own, as jwt package drops its error when wrapping it
+func verify(a *Authorizer, raw string) (*jwt.Token, error) {
+	token, _, err := jwt.NewParser().ParseUnverified(raw, jwt.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.keyfunc(token); err != nil {
+		return nil, err
+	}
+
+	return jwt.Parse(raw, a.keyfunc)
+}
+
+// ed25519Key returns Ed25519 key with given id derived from given seed byte
+func ed25519Key(id string, seed byte) jwks.Key {
+	private := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+
+	return jwks.Key{Id: id, Method: jwks.SigningMethodEdDSA, Public: private.Public(), Signer: private}
+}
+
+func mustKeyAuthorizer(t *testing.T, alg string, keys []jwks.Key, signingId string) *Authorizer {
+	t.Helper()
+
+	a, err := NewKeyAuthorizer(alg, keys, signingId, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeyAuthorizer: %v", err)
+	}
+
+	return a
+}
+
+func mustSign(t *testing.T, a *Authorizer) string {
+	t.Helper()
+
+	token, err := a.sign(jwt.NewWithClaims(a.method, jwt.MapClaims{"sub": "1"}))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	return token
+}
+
+// signed returns token signed with given method and key, with 'kid' header unless it is empty
+func signed(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, jwt.MapClaims{"sub": "1"})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign %s token: %v", method.Alg(), err)
+	}
+
+	return raw
+}
@@ -0,0 +1,137 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"inditilla/internal/entity"
+	"inditilla/pkg/thumbnail"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	// Decoders of supported upload formats
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// AvatarSizes are sizes of square avatar images made of uploaded image. The largest one
+// is profile's avatar URL, others are served by the same URL with their size suffix
+var AvatarSizes = []int{256, 128, 64}
+
+const (
+	avatarMaxPixels = 4096 * 4096 // Larger images are rejected before they are decoded
+	avatarQuality   = 85          // JPEG quality of avatar images
+)
+
+// avatarTypes are accepted content types of uploaded image, sniffed from its content
+var avatarTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// UpdateAvatar replaces user's avatar with thumbnails of uploaded image and returns updated user.
+// Previous avatar images are deleted
+func (us *userService) UpdateAvatar(ctx context.Context, caller entity.Caller, idStr string, content []byte) (entity.UserEntity, error) {
+	user, err := us.GetById(ctx, caller, idStr)
+	if err != nil {
+		return entity.UserEntity{}, err
+	}
+
+	if err := authorize(caller, ActionUpdateProfile, user.Id); err != nil {
+		return entity.UserEntity{}, err
+	}
+
+	thumbnails, err := avatarThumbnails(content)
+	if err != nil {
+		return entity.UserEntity{}, err
+	}
+
+	// Every upload gets new key, so cached images of previous avatar are never served for it
+	prefix := fmt.Sprintf("avatars/%d/%s", user.Id, uuid.NewString())
+	for i, size := range AvatarSizes {
+		if err := us.blobs.Put(ctx, avatarKey(prefix, size), bytes.NewReader(thumbnails[i])); err != nil {
+			us.deleteAvatar(ctx, prefix)
+			return entity.UserEntity{}, err
+		}
+	}
+
+	previous := user.Avatar
+	user.Avatar = prefix
+
+	if err := us.userRepo.Update(ctx, &user, false); err != nil {
+		us.deleteAvatar(ctx, prefix)
+		return entity.UserEntity{}, err
+	}
+
+	// Profile already points to new images, so previous ones are left behind if deleting fails
+	if previous != "" {
+		us.deleteAvatar(ctx, previous)
+	}
+
+	user.AvatarURL = us.avatarURL(user.Avatar)
+
+	return user, nil
+}
+
+// avatarThumbnails checks uploaded image and returns JPEG encoded thumbnails of AvatarSizes
+func avatarThumbnails(content []byte) ([][]byte, error) {
+	if len(content) > entity.AvatarMaxBytes {
+		return nil, entity.ErrImageTooLarge
+	}
+
+	if !avatarTypes[http.DetectContentType(content)] {
+		return nil, entity.ErrUnsupportedImage
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, entity.ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > avatarMaxPixels {
+		return nil, entity.ErrInvalidImage
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, entity.ErrInvalidImage
+	}
+
+	thumbnails := make([][]byte, len(AvatarSizes))
+	for i, size := range AvatarSizes {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, thumbnail.Square(img, size), &jpeg.Options{Quality: avatarQuality}); err != nil {
+			return nil, err
+		}
+		thumbnails[i] = buf.Bytes()
+	}
+
+	return thumbnails, nil
+}
+
+// deleteAvatar deletes avatar images with given key prefix. Errors are ignored, since
+// images left behind are only wasted space
+func (us *userService) deleteAvatar(ctx context.Context, prefix string) {
+	for _, size := range AvatarSizes {
+		_ = us.blobs.Delete(ctx, avatarKey(prefix, size))
+	}
+}
+
+// avatarURL returns URL of the largest avatar image with given key prefix, or empty string if avatar is not set
+func (us *userService) avatarURL(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+
+	return us.blobs.URL(avatarKey(prefix, AvatarSizes[0]))
+}
+
+// avatarKey returns blob key of avatar image of given size
+func avatarKey(prefix string, size int) string {
+	return prefix + "-" + strconv.Itoa(size) + ".jpg"
+}
@@ -4,19 +4,42 @@ import (
 	"fmt"
 	"inditilla/internal/entity"
 	"inditilla/internal/service/validator"
+	"net/url"
 	"regexp"
+	"time"
+	_ "time/tzdata" // Time zones are validated the same way whether system has tz database or not
+
+	"golang.org/x/text/language"
 )
 
 const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 24 * time.Hour
+
 	maxInitialsLen = 255
 	maxEmailLen    = 255
 	minPasswordLen = 8
 	maxPasswordLen = 500
+
+	maxDisplayNameLen = 100
+	maxBioLen         = 500
+	maxLocaleLen      = 35
+	maxTimezoneLen    = 64
+	maxWebsiteLen     = 2048
+
+	dateOfBirthLayout = "2006-01-02"
 )
 
+// PhoneRX matches phone numbers in E.164 format
+var PhoneRX = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// minDateOfBirth is the earliest date of birth accepted
+var minDateOfBirth = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:.[a-zA-Z0-9](?:[a-zA-Z0-9]{0, 61}[a-zA-Z0-9])?)*$")
 
-func isRightSignUp(u *entity.UserSignupForm) bool {
+// IsRightSignUp validates sign up form. It is exported for users created by admin service
+func IsRightSignUp(u *entity.UserSignupForm) bool {
 	u.CheckField(validator.NotBlank(u.FirstName), "firstName", "This field cannot be blank")
 	u.CheckField(validator.MaxChar(u.FirstName, maxInitialsLen), "firstName", fmt.Sprintf("Maximum characters length exceeded - %d", maxInitialsLen))
 	u.CheckField(validator.NotBlank(u.LastName), "lastName", "This field cannot be blank")
@@ -39,6 +62,62 @@ func isRightLogin(u *entity.UserLoginForm) bool {
 	return u.Valid()
 }
 
+func isRightRefresh(f *entity.RefreshTokenForm) bool {
+	f.CheckField(validator.NotBlank(f.RefreshToken), "refresh_token", "This field cannot be blank")
+
+	return f.Valid()
+}
+
+func isRightForgotPassword(f *entity.ForgotPasswordForm) bool {
+	f.CheckField(validator.NotBlank(f.Email), "email", "This field cannot be blank")
+	f.CheckField(validator.Matches(f.Email, EmailRX), "email", "This should valid email address")
+
+	return f.Valid()
+}
+
+func isRightResetPassword(f *entity.ResetPasswordForm) bool {
+	f.CheckField(validator.NotBlank(f.Token), "token", "This field cannot be blank")
+	f.CheckField(validator.NotBlank(f.Password), "password", "This field cannot be blank")
+	f.CheckField(validator.MinChar(f.Password, minPasswordLen), "password", fmt.Sprintf("This field should be %d characters length minimum", minPasswordLen))
+	f.CheckField(validator.MaxChar(f.Password, maxPasswordLen), "password", fmt.Sprintf("Maximum characters length exceeded - %d", maxPasswordLen))
+
+	return f.Valid()
+}
+
+func isRightPassword(f *entity.PasswordForm) bool {
+	f.CheckField(validator.NotBlank(f.Password), "password", "This field cannot be blank")
+	f.CheckField(validator.MinChar(f.Password, minPasswordLen), "password", fmt.Sprintf("This field should be %d characters length minimum", minPasswordLen))
+	f.CheckField(validator.MaxChar(f.Password, maxPasswordLen), "password", fmt.Sprintf("Maximum characters length exceeded - %d", maxPasswordLen))
+
+	return f.Valid()
+}
+
+func isRightToken(f *entity.TokenForm) bool {
+	f.CheckField(validator.NotBlank(f.Token), "token", "This field cannot be blank")
+
+	return f.Valid()
+}
+
+func isRightEmail(f *entity.EmailForm) bool {
+	f.CheckField(validator.NotBlank(f.Email), "email", "This field cannot be blank")
+	f.CheckField(validator.Matches(f.Email, EmailRX), "email", "This should valid email address")
+
+	return f.Valid()
+}
+
+func isRightMfaCode(f *entity.MfaCodeForm) bool {
+	f.CheckField(validator.NotBlank(f.Code), "code", "This field cannot be blank")
+
+	return f.Valid()
+}
+
+func isRightMfaLogin(f *entity.MfaLoginForm) bool {
+	f.CheckField(validator.NotBlank(f.MfaToken), "mfa_token", "This field cannot be blank")
+	f.CheckField(validator.NotBlank(f.Code), "code", "This field cannot be blank")
+
+	return f.Valid()
+}
+
 func isRightUser(u *entity.UserEntity) bool {
 	u.CheckField(validator.NotBlank(u.FirstName), "firstName", "must be provided")
 	u.CheckField(validator.MaxChar(u.FirstName, 255), "firstName", "must not be more than 255 bytes long")
@@ -46,6 +125,60 @@ func isRightUser(u *entity.UserEntity) bool {
 	u.CheckField(validator.MaxChar(u.LastName, 255), "lastName", "must not be more than 255 bytes long")
 	u.CheckField(validator.NotBlank(u.Email), "email", "must be provided")
 	u.CheckField(validator.MaxChar(u.Email, 255), "email", "must not be more than 255 bytes long")
+	u.CheckField(validator.Matches(u.Email, EmailRX), "email", "must be valid email address")
+
+	u.CheckField(validator.MaxChar(u.DisplayName, maxDisplayNameLen), "displayName", fmt.Sprintf("must not be more than %d characters long", maxDisplayNameLen))
+	u.CheckField(validator.MaxChar(u.Bio, maxBioLen), "bio", fmt.Sprintf("must not be more than %d characters long", maxBioLen))
+	u.CheckField(u.Phone == "" || validator.Matches(u.Phone, PhoneRX), "phone", "must be phone number in E.164 format, e.g. +14155552671")
+	u.CheckField(u.DateOfBirth == "" || isDateOfBirth(u.DateOfBirth), "dateOfBirth", "must be past date in YYYY-MM-DD format")
+	u.CheckField(u.Locale == "" || isLocale(u.Locale), "locale", "must be BCP 47 language tag, e.g. en-US")
+	u.CheckField(u.Timezone == "" || isTimezone(u.Timezone), "timezone", "must be IANA time zone name, e.g. Europe/Berlin")
+	u.CheckField(u.Website == "" || isWebsite(u.Website), "website", fmt.Sprintf("must be http or https URL not more than %d characters long", maxWebsiteLen))
 
 	return u.Valid()
 }
+
+// isDateOfBirth reports whether given date in YYYY-MM-DD format is past date not earlier than 1900
+func isDateOfBirth(s string) bool {
+	date, err := time.Parse(dateOfBirthLayout, s)
+	if err != nil {
+		return false
+	}
+
+	return !date.Before(minDateOfBirth) && date.Before(time.Now().UTC())
+}
+
+func isLocale(s string) bool {
+	if !validator.MaxChar(s, maxLocaleLen) {
+		return false
+	}
+
+	_, err := language.Parse(s)
+
+	return err == nil
+}
+
+// isTimezone reports whether given name is IANA time zone. 'Local' zone depends on
+// server's settings, so it is not accepted
+func isTimezone(s string) bool {
+	if s == "Local" || !validator.MaxChar(s, maxTimezoneLen) {
+		return false
+	}
+
+	_, err := time.LoadLocation(s)
+
+	return err == nil
+}
+
+func isWebsite(s string) bool {
+	if !validator.MaxChar(s, maxWebsiteLen) {
+		return false
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,94 @@
+package user
+
+import (
+	"context"
+	"inditilla/internal/entity"
+	"time"
+)
+
+// LockoutPolicy defines how failed log in attempts are throttled. Every failure after
+// BackoffAfter ones doubles the delay before next attempt (starting with BackoffBase, up
+// to BackoffMax), both for the account and for client IP. After MaxFailures the account
+// is locked for Duration. Failures older than Duration are forgotten
+type LockoutPolicy struct {
+	MaxFailures  int
+	Duration     time.Duration
+	BackoffAfter int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+}
+
+// delay returns time log in is blocked for after given number of failures in a row
+func (p LockoutPolicy) delay(failures int, isAccount bool) time.Duration {
+	if isAccount && p.MaxFailures > 0 && failures >= p.MaxFailures {
+		return p.Duration
+	}
+
+	if failures < p.BackoffAfter || p.BackoffBase <= 0 {
+		return 0
+	}
+
+	d := p.BackoffBase
+	for i := p.BackoffAfter; i < failures && d < p.BackoffMax; i++ {
+		d *= 2
+	}
+
+	if d > p.BackoffMax {
+		d = p.BackoffMax
+	}
+
+	return d
+}
+
+// checkLockout returns entity.RetryError if log in to given email or from given IP is blocked
+func (us *userService) checkLockout(ctx context.Context, email, ip string) error {
+	blockedUntil, err := us.lockoutRepo.GetBlockedUntil(ctx, loginKeys(email, ip))
+	if err != nil {
+		return err
+	}
+
+	if blockedUntil != nil {
+		if retryAfter := time.Until(*blockedUntil); retryAfter > 0 {
+			return &entity.RetryError{Err: entity.ErrTooManyAttempts, RetryAfter: retryAfter}
+		}
+	}
+
+	return nil
+}
+
+// recordLoginFailure counts failed log in attempt to given email from given IP and
+// blocks next attempts by policy
+func (us *userService) recordLoginFailure(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	windowStart := now.Add(-us.policy.Lockout.Duration)
+
+	for _, key := range loginKeys(email, ip) {
+		failures, err := us.lockoutRepo.RecordFailure(ctx, key, windowStart)
+		if err != nil {
+			return err
+		}
+
+		if d := us.policy.Lockout.delay(failures, key == entity.LoginEmailKey(email)); d > 0 {
+			if err := us.lockoutRepo.Block(ctx, key, now.Add(d)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// PruneLoginFailures deletes failed log in attempts forgotten by policy. It is expected
+// to be called periodically, so counters of keys that stopped failing do not pile up
+func (us *userService) PruneLoginFailures(ctx context.Context) error {
+	return us.lockoutRepo.Prune(ctx, time.Now().Add(-us.policy.Lockout.Duration))
+}
+
+func loginKeys(email, ip string) []string {
+	keys := []string{entity.LoginEmailKey(email)}
+	if ip != "" {
+		keys = append(keys, entity.LoginIPKey(ip))
+	}
+
+	return keys
+}
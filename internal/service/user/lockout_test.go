@@ -0,0 +1,48 @@
+package user
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutPolicyDelay(t *testing.T) {
+	policy := LockoutPolicy{
+		MaxFailures:  10,
+		Duration:     15 * time.Minute,
+		BackoffAfter: 3,
+		BackoffBase:  time.Second,
+		BackoffMax:   5 * time.Second,
+	}
+
+	tests := []struct {
+		name      string
+		policy    LockoutPolicy
+		failures  int
+		isAccount bool
+		want      time.Duration
+	}{
+		{"no failures", policy, 0, true, 0},
+		{"below backoff", policy, 2, true, 0},
+		{"first backoff", policy, 3, true, time.Second},
+		{"doubled", policy, 4, true, 2 * time.Second},
+		{"doubled twice", policy, 5, true, 4 * time.Second},
+		{"capped by max", policy, 6, true, 5 * time.Second},
+		{"capped far beyond max", policy, 9, true, 5 * time.Second},
+		{"account locked", policy, 10, true, 15 * time.Minute},
+		{"account stays locked", policy, 11, true, 15 * time.Minute},
+		{"IP is never locked", policy, 10, false, 5 * time.Second},
+		{"IP backoff", policy, 3, false, time.Second},
+		{"backoff disabled", LockoutPolicy{MaxFailures: 3, Duration: time.Minute, BackoffAfter: 1}, 2, true, 0},
+		{"lock without backoff", LockoutPolicy{MaxFailures: 3, Duration: time.Minute}, 3, true, time.Minute},
+		{"lock disabled", LockoutPolicy{Duration: time.Minute}, 100, true, 0},
+		{"max below base", LockoutPolicy{BackoffAfter: 1, BackoffBase: time.Minute, BackoffMax: time.Second}, 1, false, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.delay(tt.failures, tt.isAccount); got != tt.want {
+				t.Errorf("delay(%d, %v) = %v; want %v", tt.failures, tt.isAccount, got, tt.want)
+			}
+		})
+	}
+}
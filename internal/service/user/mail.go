@@ -0,0 +1,50 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"inditilla/pkg/mailer"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Notifier composes and sends emails to users. Links in emails point to pages
+// under given base url of the application
+type Notifier struct {
+	mailer  mailer.Mailer
+	baseURL string
+}
+
+func NewNotifier(m mailer.Mailer, baseURL string) *Notifier {
+	return &Notifier{
+		mailer:  m,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (n *Notifier) sendPasswordReset(ctx context.Context, email, token string, ttl time.Duration) error {
+	link := n.baseURL + "/ui/reset-password.html?token=" + url.QueryEscape(token)
+
+	return n.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Someone requested password reset for your account.\n\n"+
+			"To set new password follow the link below within %s:\n%s\n\n"+
+			"Or use this token: %s\n\n"+
+			"If it was not you, just ignore this email.", ttl, link, token),
+	})
+}
+
+func (n *Notifier) sendEmailVerification(ctx context.Context, email, token string, ttl time.Duration) error {
+	link := n.baseURL + "/ui/verify-email.html?token=" + url.QueryEscape(token)
+
+	return n.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Email verification",
+		Body: fmt.Sprintf("Please confirm this email address for your account.\n\n"+
+			"To confirm it follow the link below within %s:\n%s\n\n"+
+			"Or use this token: %s\n\n"+
+			"If it was not you, just ignore this email.", ttl, link, token),
+	})
+}
@@ -0,0 +1,229 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"inditilla/internal/data"
+	"inditilla/internal/entity"
+	"inditilla/pkg/totp"
+	"strings"
+	"time"
+)
+
+const (
+	mfaChallengeTTL = 5 * time.Minute
+
+	// totpSkew is number of time steps code may be late or early for (clock drift)
+	totpSkew = 1
+
+	recoveryCodesCount = 10
+	recoveryCodeBytes  = 5
+)
+
+// SetupTotp starts TOTP enrollment of the caller - new secret is generated and returned
+// with otpauth URI for authenticator app. TOTP is enabled only after it is confirmed
+func (us *userService) SetupTotp(ctx context.Context, caller entity.Caller) (entity.TotpSetupResponse, error) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return entity.TotpSetupResponse{}, fmt.Errorf("totp secret generation error: %v", err)
+	}
+
+	if err := us.mfaRepo.SaveTotp(ctx, caller.Id, secret); err != nil {
+		return entity.TotpSetupResponse{}, err
+	}
+
+	return entity.TotpSetupResponse{
+		Secret: secret,
+		URI:    totp.URI(us.policy.MfaIssuer, caller.Email, secret),
+	}, nil
+}
+
+// ConfirmTotp enables caller's TOTP if given code is valid and returns recovery codes.
+// They are shown only once, only their hashes are stored
+func (us *userService) ConfirmTotp(ctx context.Context, caller entity.Caller, f *entity.MfaCodeForm) ([]string, error) {
+	if !isRightMfaCode(f) {
+		return nil, entity.ErrInvalidInputData
+	}
+
+	t, err := us.mfaRepo.GetTotp(ctx, caller.Id)
+	if err != nil {
+		if errors.Is(err, entity.ErrNoRecord) {
+			return nil, entity.ErrMfaNotSetUp
+		}
+		return nil, err
+	}
+
+	if t.ConfirmedAt != nil {
+		return nil, entity.ErrMfaEnabled
+	}
+
+	step, ok := totp.Validate(t.Secret, f.Code, time.Now(), totpSkew)
+	if !ok {
+		return nil, entity.ErrInvalidMfaCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("recovery codes generation error: %v", err)
+	}
+
+	if err := us.mfaRepo.ConfirmTotp(ctx, caller.Id, step, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// SignInMfa completes log in started by SignIn with TOTP code or recovery code. Challenge
+// token is single use, so log in has to be started over after wrong code. Wrong codes
+// count as failed log in attempts of the user's email and client IP
+func (us *userService) SignInMfa(ctx context.Context, f *entity.MfaLoginForm) (entity.TokenPair, error) {
+	if !isRightMfaLogin(f) {
+		return entity.TokenPair{}, entity.ErrInvalidInputData
+	}
+
+	challenge, err := us.tokenRepo.ConsumeOneTimeToken(ctx, data.HashToken(f.MfaToken), entity.TokenPurposeMfaChallenge)
+	if err != nil {
+		if errors.Is(err, entity.ErrNoRecord) {
+			return entity.TokenPair{}, entity.ErrInvalidToken
+		}
+		return entity.TokenPair{}, err
+	}
+
+	user, err := us.userRepo.GetById(ctx, challenge.UserId)
+	if err != nil {
+		if errors.Is(err, entity.ErrNoRecord) {
+			return entity.TokenPair{}, entity.ErrInvalidToken
+		}
+		return entity.TokenPair{}, err
+	}
+
+	if user.DisabledAt != nil {
+		return entity.TokenPair{}, entity.ErrUserDisabled
+	}
+
+	// Wrong codes are throttled by the same keys as wrong passwords
+	if err := us.checkLockout(ctx, user.Email, f.IP); err != nil {
+		return entity.TokenPair{}, err
+	}
+
+	user.Password = ""
+
+	// Code is spent only if tokens are issued
+	var tokens entity.TokenPair
+
+	err = us.withTx(ctx, func(tx *userService) error {
+		if err := tx.verifyMfaCode(ctx, user.Id, f.Code); err != nil {
+			return err
+		}
+
+		tokens, err = tx.issueTokens(ctx, user, newSessionId())
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, entity.ErrInvalidMfaCode) {
+			if err := us.recordLoginFailure(ctx, user.Email, f.IP); err != nil {
+				return entity.TokenPair{}, err
+			}
+		}
+		return entity.TokenPair{}, err
+	}
+
+	if err := us.lockoutRepo.Reset(ctx, entity.LoginEmailKey(user.Email)); err != nil {
+		return entity.TokenPair{}, err
+	}
+
+	return tokens, nil
+}
+
+// mfaEnabled reports whether user has confirmed TOTP
+func (us *userService) mfaEnabled(ctx context.Context, userId int) (bool, error) {
+	t, err := us.mfaRepo.GetTotp(ctx, userId)
+	if err != nil {
+		if errors.Is(err, entity.ErrNoRecord) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return t.ConfirmedAt != nil, nil
+}
+
+// mfaChallenge returns new challenge token log in of given user is completed with
+func (us *userService) mfaChallenge(ctx context.Context, userId int) (string, error) {
+	plain, hash, err := us.token.NewOpaque()
+	if err != nil {
+		return "", fmt.Errorf("mfa token generation error: %v", err)
+	}
+
+	err = us.tokenRepo.SaveOneTimeToken(ctx, &entity.OneTimeToken{
+		UserId:    userId,
+		Purpose:   entity.TokenPurposeMfaChallenge,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return plain, nil
+}
+
+// verifyMfaCode checks given code as TOTP code first and as recovery code then.
+// Each code could be used only once
+func (us *userService) verifyMfaCode(ctx context.Context, userId int, code string) error {
+	t, err := us.mfaRepo.GetTotp(ctx, userId)
+	if err != nil {
+		if errors.Is(err, entity.ErrNoRecord) {
+			return entity.ErrInvalidMfaCode
+		}
+		return err
+	}
+
+	if t.ConfirmedAt == nil {
+		return entity.ErrInvalidMfaCode
+	}
+
+	if step, ok := totp.Validate(t.Secret, code, time.Now(), totpSkew); ok {
+		return us.mfaRepo.UseTotpStep(ctx, userId, step)
+	}
+
+	err = us.mfaRepo.UseRecoveryCode(ctx, userId, data.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		if errors.Is(err, entity.ErrNoRecord) {
+			return entity.ErrInvalidMfaCode
+		}
+		return err
+	}
+
+	return nil
+}
+
+// newRecoveryCodes returns recovery codes formatted as 'xxxx-xxxx' and their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+
+	for i := 0; i < recoveryCodesCount; i++ {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(encoding.EncodeToString(b))
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, data.HashToken(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode makes recovery code typed by user comparable with generated one
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
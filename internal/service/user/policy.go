@@ -0,0 +1,34 @@
+package user
+
+import "inditilla/internal/entity"
+
+// Action is an operation on user resource that is subject to authorization
+type Action string
+
+const (
+	ActionReadProfile   Action = "profile:read"
+	ActionUpdateProfile Action = "profile:update"
+	ActionIssueTokens   Action = "tokens:issue"
+)
+
+// authorize checks whether caller may perform given action on user with given id.
+// Users may read and update their own profile, other profiles may be accessed only
+// with permission granted by caller's roles. Tokens are issued only to operator
+func authorize(caller entity.Caller, action Action, userId int) error {
+	switch action {
+	case ActionReadProfile:
+		if caller.Id == userId || caller.Can(entity.PermProfileReadAny) {
+			return nil
+		}
+	case ActionUpdateProfile:
+		if caller.Id == userId || caller.Can(entity.PermProfileUpdateAny) {
+			return nil
+		}
+	case ActionIssueTokens:
+		if caller.Can(entity.PermTokensIssue) {
+			return nil
+		}
+	}
+
+	return entity.ErrForbidden
+}
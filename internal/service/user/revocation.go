@@ -0,0 +1,87 @@
+package user
+
+import (
+	"sync"
+	"time"
+)
+
+const revocationCacheTTL = 30 * time.Second
+
+type revocationEntry struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+// revocationCache keeps results of revocation lookups in memory, so database
+// is not queried on every authenticated request. Revoked entries are kept until
+// the token itself expires, since revocation can not be undone. Not revoked entries
+// are kept for revocationCacheTTL only - this is the longest time revocation made
+// by another server instance may stay unnoticed
+type revocationCache struct {
+	mu        sync.RWMutex
+	entries   map[string]revocationEntry
+	lastPrune time.Time
+}
+
+func newRevocationCache() *revocationCache {
+	return &revocationCache{
+		entries:   make(map[string]revocationEntry),
+		lastPrune: time.Now(),
+	}
+}
+
+// get returns cached revocation state of given key and whether it was found
+func (c *revocationCache) get(key string) (bool, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expiresAt) {
+		return false, false
+	}
+
+	return e.revoked, true
+}
+
+// set caches revocation state of given key. Revoked keys are cached until 'tokenExpiresAt'
+func (c *revocationCache) set(key string, revoked bool, tokenExpiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(revocationCacheTTL)
+	if revoked && tokenExpiresAt.After(expiresAt) {
+		expiresAt = tokenExpiresAt
+	}
+
+	c.entries[key] = revocationEntry{
+		revoked:   revoked,
+		expiresAt: expiresAt,
+	}
+
+	c.prune()
+}
+
+// prune deletes expired entries, it is done at most once per revocationCacheTTL.
+// Must be called with mutex locked
+func (c *revocationCache) prune() {
+	now := time.Now()
+	if now.Sub(c.lastPrune) < revocationCacheTTL {
+		return
+	}
+
+	for key, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+
+	c.lastPrune = now
+}
+
+func jtiKey(jti string) string {
+	return "jti:" + jti
+}
+
+func sessionKey(sessionId string) string {
+	return "sid:" + sessionId
+}
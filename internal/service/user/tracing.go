@@ -0,0 +1,198 @@
+package user
+
+import (
+	"context"
+	"inditilla/internal/data"
+	"inditilla/internal/entity"
+	"inditilla/pkg/jwks"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("inditilla/internal/service/user")
+
+// tracedUserService records every call of UserService method taking context as span
+// named 'UserService.<Method>', so repository queries and hashing are nested in it
+type tracedUserService struct {
+	next UserService
+}
+
+var _ UserService = (*tracedUserService)(nil)
+
+// WithTracing returns UserService recording calls of given service as spans
+func WithTracing(next UserService) UserService {
+	return &tracedUserService{next: next}
+}
+
+// startSpan starts span of service method
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "UserService."+method)
+}
+
+// endSpan ends span, marking it failed if method returned error
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (s *tracedUserService) SignUp(ctx context.Context, form *entity.UserSignupForm) (id int, err error) {
+	ctx, span := startSpan(ctx, "SignUp")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.SignUp(ctx, form)
+}
+
+func (s *tracedUserService) SignIn(ctx context.Context, form *entity.UserLoginForm) (result entity.LoginResult, err error) {
+	ctx, span := startSpan(ctx, "SignIn")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.SignIn(ctx, form)
+}
+
+func (s *tracedUserService) SignInMfa(ctx context.Context, form *entity.MfaLoginForm) (tokens entity.TokenPair, err error) {
+	ctx, span := startSpan(ctx, "SignInMfa")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.SignInMfa(ctx, form)
+}
+
+func (s *tracedUserService) SetupTotp(ctx context.Context, caller entity.Caller) (setup entity.TotpSetupResponse, err error) {
+	ctx, span := startSpan(ctx, "SetupTotp")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.SetupTotp(ctx, caller)
+}
+
+func (s *tracedUserService) ConfirmTotp(ctx context.Context, caller entity.Caller, form *entity.MfaCodeForm) (recoveryCodes []string, err error) {
+	ctx, span := startSpan(ctx, "ConfirmTotp")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.ConfirmTotp(ctx, caller, form)
+}
+
+func (s *tracedUserService) Refresh(ctx context.Context, form *entity.RefreshTokenForm) (tokens entity.TokenPair, err error) {
+	ctx, span := startSpan(ctx, "Refresh")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.Refresh(ctx, form)
+}
+
+func (s *tracedUserService) Logout(ctx context.Context, claims *data.Claims) (err error) {
+	ctx, span := startSpan(ctx, "Logout")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.Logout(ctx, claims)
+}
+
+func (s *tracedUserService) LogoutAll(ctx context.Context, claims *data.Claims) (err error) {
+	ctx, span := startSpan(ctx, "LogoutAll")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.LogoutAll(ctx, claims)
+}
+
+func (s *tracedUserService) IsRevoked(ctx context.Context, claims *data.Claims) (revoked bool, err error) {
+	ctx, span := startSpan(ctx, "IsRevoked")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.IsRevoked(ctx, claims)
+}
+
+// ParseToken is not traced, it takes no context and only verifies signature
+func (s *tracedUserService) ParseToken(token string) (*data.Claims, error) {
+	return s.next.ParseToken(token)
+}
+
+// JWKS is not traced, it takes no context and returns keys kept in memory
+func (s *tracedUserService) JWKS() jwks.Set {
+	return s.next.JWKS()
+}
+
+func (s *tracedUserService) ForgotPassword(ctx context.Context, form *entity.ForgotPasswordForm) (err error) {
+	ctx, span := startSpan(ctx, "ForgotPassword")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.ForgotPassword(ctx, form)
+}
+
+func (s *tracedUserService) ResetPassword(ctx context.Context, form *entity.ResetPasswordForm) (err error) {
+	ctx, span := startSpan(ctx, "ResetPassword")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.ResetPassword(ctx, form)
+}
+
+func (s *tracedUserService) SetPassword(ctx context.Context, caller entity.Caller, id string, form *entity.PasswordForm) (err error) {
+	ctx, span := startSpan(ctx, "SetPassword")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.SetPassword(ctx, caller, id, form)
+}
+
+func (s *tracedUserService) IssueTokens(ctx context.Context, caller entity.Caller, id string) (tokens entity.TokenPair, err error) {
+	ctx, span := startSpan(ctx, "IssueTokens")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.IssueTokens(ctx, caller, id)
+}
+
+func (s *tracedUserService) VerifyEmail(ctx context.Context, form *entity.TokenForm) (err error) {
+	ctx, span := startSpan(ctx, "VerifyEmail")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.VerifyEmail(ctx, form)
+}
+
+func (s *tracedUserService) ResendVerification(ctx context.Context, form *entity.EmailForm) (err error) {
+	ctx, span := startSpan(ctx, "ResendVerification")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.ResendVerification(ctx, form)
+}
+
+func (s *tracedUserService) Exists(ctx context.Context, email string) (exists bool, err error) {
+	ctx, span := startSpan(ctx, "Exists")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.Exists(ctx, email)
+}
+
+func (s *tracedUserService) GetById(ctx context.Context, caller entity.Caller, id string) (user entity.UserEntity, err error) {
+	ctx, span := startSpan(ctx, "GetById")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.GetById(ctx, caller, id)
+}
+
+func (s *tracedUserService) Update(ctx context.Context, caller entity.Caller, user *entity.UserEntity, isPasswordChanged bool) (err error) {
+	ctx, span := startSpan(ctx, "Update")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.Update(ctx, caller, user, isPasswordChanged)
+}
+
+func (s *tracedUserService) UpdateAvatar(ctx context.Context, caller entity.Caller, id string, content []byte) (user entity.UserEntity, err error) {
+	ctx, span := startSpan(ctx, "UpdateAvatar")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.UpdateAvatar(ctx, caller, id, content)
+}
+
+func (s *tracedUserService) ResolveCaller(ctx context.Context, claims *data.Claims) (caller entity.Caller, err error) {
+	ctx, span := startSpan(ctx, "ResolveCaller")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.ResolveCaller(ctx, claims)
+}
+
+func (s *tracedUserService) PruneLoginFailures(ctx context.Context) (err error) {
+	ctx, span := startSpan(ctx, "PruneLoginFailures")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.PruneLoginFailures(ctx)
+}
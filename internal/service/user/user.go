@@ -6,52 +6,133 @@ import (
 	"fmt"
 	"inditilla/internal/data"
 	"inditilla/internal/entity"
+	"inditilla/internal/repository"
+	"inditilla/internal/repository/lockout"
+	"inditilla/internal/repository/mfa"
+	"inditilla/internal/repository/role"
+	"inditilla/internal/repository/token"
 	"inditilla/internal/repository/user"
 	"inditilla/internal/service/validator"
+	"inditilla/pkg/blob"
+	"inditilla/pkg/jwks"
+	"inditilla/pkg/logger"
+	"inditilla/pkg/parser"
 	"strconv"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type UserService interface {
 	SignUp(context.Context, *entity.UserSignupForm) (int, error)
-	SignIn(context.Context, *entity.UserLoginForm) (string, error)
+	SignIn(context.Context, *entity.UserLoginForm) (entity.LoginResult, error)
+	SignInMfa(context.Context, *entity.MfaLoginForm) (entity.TokenPair, error)
+	SetupTotp(context.Context, entity.Caller) (entity.TotpSetupResponse, error)
+	ConfirmTotp(context.Context, entity.Caller, *entity.MfaCodeForm) ([]string, error)
+	Refresh(context.Context, *entity.RefreshTokenForm) (entity.TokenPair, error)
+	Logout(context.Context, *data.Claims) error
+	LogoutAll(context.Context, *data.Claims) error
+	IsRevoked(context.Context, *data.Claims) (bool, error)
+	ParseToken(string) (*data.Claims, error)
+	JWKS() jwks.Set
+	ForgotPassword(context.Context, *entity.ForgotPasswordForm) error
+	ResetPassword(context.Context, *entity.ResetPasswordForm) error
+	SetPassword(context.Context, entity.Caller, string, *entity.PasswordForm) error
+	IssueTokens(context.Context, entity.Caller, string) (entity.TokenPair, error)
+	VerifyEmail(context.Context, *entity.TokenForm) error
+	ResendVerification(context.Context, *entity.EmailForm) error
 	Exists(context.Context, string) (bool, error)
-	GetById(context.Context, string) (entity.UserEntity, error)
-	Update(context.Context, *entity.UserEntity, bool) error
+	GetById(context.Context, entity.Caller, string) (entity.UserEntity, error)
+	Update(context.Context, entity.Caller, *entity.UserEntity, bool) error
+	UpdateAvatar(context.Context, entity.Caller, string, []byte) (entity.UserEntity, error)
+	ResolveCaller(context.Context, *data.Claims) (entity.Caller, error)
+	PruneLoginFailures(context.Context) error
 }
 
-type Authorizer struct {
-	signingKey []byte
-	deadline   time.Duration
+type userService struct {
+	l           logger.ILogger
+	repos       *repository.Repositories
+	userRepo    user.UserRepo
+	tokenRepo   token.TokenRepo
+	roleRepo    role.RoleRepo
+	mfaRepo     mfa.MfaRepo
+	lockoutRepo lockout.LockoutRepo
+	auth        *Authorizer
+	token       *data.TokenModel
+	notifier    *Notifier
+	blobs       blob.BlobStore
+	policy      Policy
+	revoked     *revocationCache
 }
 
-func NewAuthorizer(signingKey []byte, deadline time.Duration) *Authorizer {
-	return &Authorizer{
-		signingKey: signingKey,
-		deadline:   deadline,
-	}
-}
+// Policy holds account security settings of user service
+type Policy struct {
+	// RequireVerifiedEmail denies log in until user verifies email address
+	RequireVerifiedEmail bool
 
-type userService struct {
-	userRepo user.UserRepo
-	auth     *Authorizer
-	token    *data.TokenModel
+	// MfaIssuer is name accounts are shown with in authenticator apps
+	MfaIssuer string
+
+	// Lockout throttles failed log in attempts
+	Lockout LockoutPolicy
 }
 
-func NewUserService(u user.UserRepo, auth *Authorizer, tokenModel *data.TokenModel) *userService {
+func NewUserService(l logger.ILogger, repos *repository.Repositories, auth *Authorizer, tokenModel *data.TokenModel, notifier *Notifier, blobs blob.BlobStore, policy Policy) *userService {
 	return &userService{
-		userRepo: u,
-		auth:     auth,
-		token:    tokenModel,
+		l:           l,
+		repos:       repos,
+		userRepo:    repos.User,
+		tokenRepo:   repos.Token,
+		roleRepo:    repos.Role,
+		mfaRepo:     repos.Mfa,
+		lockoutRepo: repos.Lockout,
+		auth:        auth,
+		token:       tokenModel,
+		notifier:    notifier,
+		blobs:       blobs,
+		policy:      policy,
+		revoked:     newRevocationCache(),
 	}
 }
 
+// withTx runs 'fn' with copy of user service whose repositories work within single
+// transaction, so all changes 'fn' makes are committed or rolled back together
+func (us *userService) withTx(ctx context.Context, fn func(tx *userService) error) error {
+	return us.repos.WithTx(ctx, func(r *repository.Repositories) error {
+		tx := *us
+		tx.repos = r
+		tx.userRepo = r.User
+		tx.tokenRepo = r.Token
+		tx.roleRepo = r.Role
+		tx.mfaRepo = r.Mfa
+		tx.lockoutRepo = r.Lockout
+
+		return fn(&tx)
+	})
+}
+
 func (us *userService) SignUp(ctx context.Context, u *entity.UserSignupForm) (int, error) {
-	if !isRightSignUp(u) {
+	if !IsRightSignUp(u) {
 		return 0, entity.ErrInvalidInputData
 	}
 
-	id, err := us.userRepo.SaveUser(ctx, *u)
+	var (
+		id                int
+		verificationToken string
+	)
+
+	// User is saved only together with verification token
+	err := us.withTx(ctx, func(tx *userService) error {
+		var err error
+
+		id, err = tx.userRepo.SaveUser(ctx, *u)
+		if err != nil {
+			return err
+		}
+
+		verificationToken, err = tx.newVerificationToken(ctx, id, u.Email)
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, entity.ErrDuplicateEmail) {
 			return 0, entity.ErrDuplicateEmail
@@ -59,27 +140,406 @@ func (us *userService) SignUp(ctx context.Context, u *entity.UserSignupForm) (in
 		return 0, err
 	}
 
+	// User is already saved, so failed verification email is not a sign up error -
+	// it could be sent again by user's request
+	if err := us.notifier.sendEmailVerification(ctx, u.Email, verificationToken, emailVerificationTTL); err != nil {
+		us.l.Error("sending verification email to user with id '%d': %v", id, err)
+	}
+
 	return id, nil
 }
 
-func (us *userService) SignIn(ctx context.Context, u *entity.UserLoginForm) (string, error) {
+// SignIn authenticates user by email and password. Failed attempts are throttled by lockout
+// policy. If user has MFA enabled, log in is not complete yet - challenge token is returned
+// to be exchanged for token pair by SignInMfa
+func (us *userService) SignIn(ctx context.Context, u *entity.UserLoginForm) (entity.LoginResult, error) {
 	if !isRightLogin(u) {
-		return "", entity.ErrInvalidInputData
+		return entity.LoginResult{}, entity.ErrInvalidInputData
+	}
+
+	// Blocked attempts are rejected before password is checked, it is what makes guessing expensive
+	if err := us.checkLockout(ctx, u.Email, u.IP); err != nil {
+		return entity.LoginResult{}, err
 	}
 
 	user, err := us.userRepo.Authenticate(ctx, u.Email, u.Password)
 	if err != nil {
-		return "", err
+		if errors.Is(err, entity.ErrInvalidCredentials) {
+			if err := us.recordLoginFailure(ctx, u.Email, u.IP); err != nil {
+				return entity.LoginResult{}, err
+			}
+		}
+		return entity.LoginResult{}, err
+	}
+
+	enabled, err := us.mfaEnabled(ctx, user.Id)
+	if err != nil {
+		return entity.LoginResult{}, err
+	}
+
+	// Failures of the account are forgotten on success, failures of IP are not - otherwise
+	// attacker could reset them by logging into own account. With MFA enabled log in is not
+	// complete yet, so failures are forgotten by SignInMfa
+	if !enabled {
+		if err := us.lockoutRepo.Reset(ctx, entity.LoginEmailKey(u.Email)); err != nil {
+			return entity.LoginResult{}, err
+		}
+	}
+
+	if user.DisabledAt != nil {
+		return entity.LoginResult{}, entity.ErrUserDisabled
+	}
+
+	if us.policy.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return entity.LoginResult{}, entity.ErrEmailNotVerified
+	}
+
+	if enabled {
+		mfaToken, err := us.mfaChallenge(ctx, user.Id)
+		if err != nil {
+			return entity.LoginResult{}, err
+		}
+
+		return entity.LoginResult{MfaToken: mfaToken}, nil
+	}
+
+	tokens, err := us.issueTokens(ctx, user, newSessionId())
+	if err != nil {
+		return entity.LoginResult{}, err
+	}
+
+	return entity.LoginResult{Tokens: tokens}, nil
+}
+
+// Refresh rotates given refresh token - it is marked as used and new token pair
+// from the same family is issued. If already used token is presented again, whole
+// family is revoked, since either the user or an attacker holds a stolen token
+func (us *userService) Refresh(ctx context.Context, f *entity.RefreshTokenForm) (entity.TokenPair, error) {
+	if !isRightRefresh(f) {
+		return entity.TokenPair{}, entity.ErrInvalidInputData
+	}
+
+	rt, err := us.tokenRepo.GetRefreshToken(ctx, data.HashToken(f.RefreshToken))
+	if err != nil {
+		if errors.Is(err, entity.ErrNoRecord) {
+			return entity.TokenPair{}, entity.ErrInvalidRefreshToken
+		}
+		return entity.TokenPair{}, err
+	}
+
+	if rt.RevokedAt != nil {
+		return entity.TokenPair{}, entity.ErrInvalidRefreshToken
+	}
+
+	if rt.UsedAt != nil {
+		return entity.TokenPair{}, us.revokeFamily(ctx, rt.FamilyId)
+	}
+
+	if time.Now().After(rt.ExpiresAt) {
+		return entity.TokenPair{}, entity.ErrInvalidRefreshToken
+	}
+
+	// Token is marked as used only if new pair is issued. Concurrent rotation of the same
+	// token waits for this transaction and is detected as reuse
+	var tokens entity.TokenPair
+
+	err = us.withTx(ctx, func(tx *userService) error {
+		if err := tx.tokenRepo.MarkRefreshTokenUsed(ctx, rt.Id); err != nil {
+			return err
+		}
+
+		user, err := tx.userRepo.GetById(ctx, rt.UserId)
+		if err != nil {
+			if errors.Is(err, entity.ErrNoRecord) {
+				return entity.ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if user.DisabledAt != nil {
+			return entity.ErrInvalidRefreshToken
+		}
+
+		tokens, err = tx.issueTokens(ctx, user, rt.FamilyId)
+		return err
+	})
+	if err != nil {
+		// Family is revoked outside of rolled back transaction
+		if errors.Is(err, entity.ErrRefreshTokenReuse) {
+			return entity.TokenPair{}, us.revokeFamily(ctx, rt.FamilyId)
+		}
+		return entity.TokenPair{}, err
+	}
+
+	return tokens, nil
+}
+
+// Logout revokes given access token and the session (refresh token family) it was issued for
+func (us *userService) Logout(ctx context.Context, claims *data.Claims) error {
+	if err := us.revokeAccessToken(ctx, claims); err != nil {
+		return err
+	}
+
+	if err := us.tokenRepo.RevokeRefreshTokenFamily(ctx, claims.SessionId); err != nil {
+		return err
+	}
+	us.revoked.set(sessionKey(claims.SessionId), true, time.Now().Add(us.auth.deadline))
+
+	return nil
+}
+
+// LogoutAll revokes given access token and all sessions of the user, so every
+// access token issued to the user before is rejected too
+func (us *userService) LogoutAll(ctx context.Context, claims *data.Claims) error {
+	if err := us.revokeAccessToken(ctx, claims); err != nil {
+		return err
+	}
+
+	families, err := us.tokenRepo.RevokeUserRefreshTokens(ctx, claims.UserId)
+	if err != nil {
+		return err
+	}
+	for _, familyId := range families {
+		us.revoked.set(sessionKey(familyId), true, time.Now().Add(us.auth.deadline))
+	}
+
+	return nil
+}
+
+// IsRevoked reports whether given access token or its session was revoked
+func (us *userService) IsRevoked(ctx context.Context, claims *data.Claims) (bool, error) {
+	revoked, ok := us.revoked.get(jtiKey(claims.ID))
+	if !ok {
+		var err error
+		revoked, err = us.tokenRepo.IsAccessTokenRevoked(ctx, claims.ID)
+		if err != nil {
+			return false, err
+		}
+		us.revoked.set(jtiKey(claims.ID), revoked, claims.ExpiresAt.Time)
+	}
+
+	if revoked {
+		return true, nil
+	}
+
+	revoked, ok = us.revoked.get(sessionKey(claims.SessionId))
+	if !ok {
+		var err error
+		revoked, err = us.tokenRepo.IsFamilyRevoked(ctx, claims.SessionId)
+		if err != nil {
+			return false, err
+		}
+		us.revoked.set(sessionKey(claims.SessionId), revoked, claims.ExpiresAt.Time)
+	}
+
+	return revoked, nil
+}
+
+// ParseToken verifies given access token and returns its claims
+func (us *userService) ParseToken(accessToken string) (*data.Claims, error) {
+	return parser.ParseToken(accessToken, us.auth.keyfunc)
+}
+
+// JWKS returns public keys access tokens could be verified with
+func (us *userService) JWKS() jwks.Set {
+	return us.auth.jwks
+}
+
+// ForgotPassword sends password reset token to given email. Nothing is sent if there is
+// no active user with such email, but no error is returned, so registered emails are not disclosed
+func (us *userService) ForgotPassword(ctx context.Context, f *entity.ForgotPasswordForm) error {
+	if !isRightForgotPassword(f) {
+		return entity.ErrInvalidInputData
+	}
+
+	user, err := us.userRepo.GetByEmail(ctx, f.Email)
+	if err != nil {
+		if errors.Is(err, entity.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+
+	if user.DisabledAt != nil {
+		return nil
 	}
 
-	token := us.token.New(user.Email, us.auth.deadline)
+	plain, hash, err := us.token.NewOpaque()
+	if err != nil {
+		return fmt.Errorf("reset token generation error: %v", err)
+	}
+
+	// Previously sent tokens are invalidated, only the latest one could be used
+	err = us.withTx(ctx, func(tx *userService) error {
+		if err := tx.tokenRepo.DeleteOneTimeTokens(ctx, user.Id, entity.TokenPurposePasswordReset); err != nil {
+			return err
+		}
 
-	tkn, err := token.SignedString(us.auth.signingKey)
+		return tx.tokenRepo.SaveOneTimeToken(ctx, &entity.OneTimeToken{
+			UserId:    user.Id,
+			Purpose:   entity.TokenPurposePasswordReset,
+			TokenHash: hash,
+			ExpiresAt: time.Now().Add(passwordResetTTL),
+		})
+	})
 	if err != nil {
-		return "", fmt.Errorf("token signing error: %v", err)
+		return err
 	}
 
-	return tkn, nil
+	return us.notifier.sendPasswordReset(ctx, user.Email, plain, passwordResetTTL)
+}
+
+// ResetPassword sets new password of the user given reset token was sent to. Token can be used
+// only once, all sessions of the user are revoked after the password is changed
+func (us *userService) ResetPassword(ctx context.Context, f *entity.ResetPasswordForm) error {
+	if !isRightResetPassword(f) {
+		return entity.ErrInvalidInputData
+	}
+
+	var families []string
+
+	// Token is consumed only if password is changed and sessions are revoked
+	err := us.withTx(ctx, func(tx *userService) error {
+		t, err := tx.tokenRepo.ConsumeOneTimeToken(ctx, data.HashToken(f.Token), entity.TokenPurposePasswordReset)
+		if err != nil {
+			if errors.Is(err, entity.ErrNoRecord) {
+				return entity.ErrInvalidToken
+			}
+			return err
+		}
+
+		user, err := tx.userRepo.GetById(ctx, t.UserId)
+		if err != nil {
+			if errors.Is(err, entity.ErrNoRecord) {
+				return entity.ErrInvalidToken
+			}
+			return err
+		}
+
+		user.Password = f.Password
+		if err := tx.userRepo.Update(ctx, &user, true); err != nil {
+			return err
+		}
+
+		families, err = tx.tokenRepo.RevokeUserRefreshTokens(ctx, user.Id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, familyId := range families {
+		us.revoked.set(sessionKey(familyId), true, time.Now().Add(us.auth.deadline))
+	}
+
+	return nil
+}
+
+// SetPassword sets new password of user without reset token, revokes all user's sessions
+// and unlocks the account
+func (us *userService) SetPassword(ctx context.Context, caller entity.Caller, idStr string, f *entity.PasswordForm) error {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return entity.ErrInvalidUserId
+	}
+
+	if err := authorize(caller, ActionUpdateProfile, id); err != nil {
+		return err
+	}
+
+	if !isRightPassword(f) {
+		return entity.ErrInvalidInputData
+	}
+
+	var families []string
+
+	err = us.withTx(ctx, func(tx *userService) error {
+		user, err := tx.userRepo.GetById(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		user.Password = f.Password
+		if err := tx.userRepo.Update(ctx, &user, true); err != nil {
+			return err
+		}
+
+		families, err = tx.tokenRepo.RevokeUserRefreshTokens(ctx, user.Id)
+		if err != nil {
+			return err
+		}
+
+		// Failures were made against the old password, so the account is unlocked
+		return tx.lockoutRepo.Reset(ctx, entity.LoginEmailKey(user.Email))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, familyId := range families {
+		us.revoked.set(sessionKey(familyId), true, time.Now().Add(us.auth.deadline))
+	}
+
+	return nil
+}
+
+// VerifyEmail verifies email address given token was sent to. If it was sent on email
+// change, this is when user's email is actually changed
+func (us *userService) VerifyEmail(ctx context.Context, f *entity.TokenForm) error {
+	if !isRightToken(f) {
+		return entity.ErrInvalidInputData
+	}
+
+	return us.withTx(ctx, func(tx *userService) error {
+		t, err := tx.tokenRepo.ConsumeOneTimeToken(ctx, data.HashToken(f.Token), entity.TokenPurposeEmailVerification)
+		if err != nil {
+			if errors.Is(err, entity.ErrNoRecord) {
+				return entity.ErrInvalidToken
+			}
+			return err
+		}
+
+		if t.Email == nil {
+			return entity.ErrInvalidToken
+		}
+
+		err = tx.userRepo.SetEmailVerified(ctx, t.UserId, *t.Email)
+		if err != nil {
+			if errors.Is(err, entity.ErrNoRecord) {
+				return entity.ErrInvalidToken
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ResendVerification sends new verification token to given email if there is active user with
+// such unverified email. As with ForgotPassword, no error is returned if there is no such user
+func (us *userService) ResendVerification(ctx context.Context, f *entity.EmailForm) error {
+	if !isRightEmail(f) {
+		return entity.ErrInvalidInputData
+	}
+
+	user, err := us.userRepo.GetByEmail(ctx, f.Email)
+	if err != nil {
+		if errors.Is(err, entity.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+
+	if user.DisabledAt != nil || user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	token, err := us.newVerificationToken(ctx, user.Id, user.Email)
+	if err != nil {
+		return err
+	}
+
+	return us.notifier.sendEmailVerification(ctx, user.Email, token, emailVerificationTTL)
 }
 
 func (us *userService) Exists(ctx context.Context, email string) (bool, error) {
@@ -89,24 +549,239 @@ func (us *userService) Exists(ctx context.Context, email string) (bool, error) {
 	return us.userRepo.Exists(ctx, email)
 }
 
-func (us *userService) GetById(ctx context.Context, idStr string) (entity.UserEntity, error) {
+// ResolveCaller returns user the token with given claims was issued to along with permissions
+// granted by roles in the token. User is looked up by id, token is rejected if user's email
+// was changed after the token was issued or user was disabled
+func (us *userService) ResolveCaller(ctx context.Context, claims *data.Claims) (entity.Caller, error) {
+	user, err := us.userRepo.GetById(ctx, claims.UserId)
+	if err != nil {
+		if errors.Is(err, entity.ErrNoRecord) {
+			return entity.Caller{}, entity.ErrInvalidAccessToken
+		}
+		return entity.Caller{}, err
+	}
+
+	if user.Email != claims.Email || user.DisabledAt != nil {
+		return entity.Caller{}, entity.ErrInvalidAccessToken
+	}
+
+	caller := entity.Caller{
+		Id:    user.Id,
+		Email: user.Email,
+		Roles: claims.Roles,
+	}
+
+	if len(claims.Roles) > 0 {
+		caller.Permissions, err = us.roleRepo.GetPermissions(ctx, claims.Roles)
+		if err != nil {
+			return entity.Caller{}, err
+		}
+	}
+
+	return caller, nil
+}
+
+func (us *userService) GetById(ctx context.Context, caller entity.Caller, idStr string) (entity.UserEntity, error) {
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return entity.UserEntity{}, entity.ErrInvalidUserId
 	}
 
+	if err := authorize(caller, ActionReadProfile, id); err != nil {
+		return entity.UserEntity{}, err
+	}
+
 	userEntity, err := us.userRepo.GetById(ctx, id)
 	if err != nil {
 		return entity.UserEntity{}, err
 	}
 
-	return userEntity, err
+	userEntity.PendingEmail, err = us.tokenRepo.GetPendingEmail(ctx, id)
+	if err != nil {
+		return entity.UserEntity{}, err
+	}
+
+	userEntity.AvatarURL = us.avatarURL(userEntity.Avatar)
+
+	return userEntity, nil
 }
 
-func (us *userService) Update(ctx context.Context, user *entity.UserEntity, isPasswordChanged bool) error {
+// Update updates user's profile if it was not updated since user's version was read,
+// otherwise entity.ErrEditConflict is returned
+func (us *userService) Update(ctx context.Context, caller entity.Caller, user *entity.UserEntity, isPasswordChanged bool) error {
+	if err := authorize(caller, ActionUpdateProfile, user.Id); err != nil {
+		return err
+	}
+
 	if !isRightUser(user) {
 		return entity.ErrInvalidInputData
 	}
 
-	return us.userRepo.Update(ctx, user, isPasswordChanged)
+	current, err := us.userRepo.GetById(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+
+	if current.Version != user.Version {
+		return entity.ErrEditConflict
+	}
+
+	// New email becomes user's email only after it is verified, until then the old one stays active.
+	// Pending email loaded with the user is left as is, so its token is issued only on actual change
+	var newEmail string
+	if user.Email != current.Email {
+		exists, err := us.userRepo.Exists(ctx, user.Email)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return entity.ErrDuplicateEmail
+		}
+
+		newEmail, user.Email = user.Email, current.Email
+	}
+
+	var verificationToken string
+
+	err = us.withTx(ctx, func(tx *userService) error {
+		if err := tx.userRepo.Update(ctx, user, isPasswordChanged); err != nil {
+			return err
+		}
+
+		if newEmail != "" {
+			verificationToken, err = tx.newVerificationToken(ctx, user.Id, newEmail)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if newEmail == "" {
+		return nil
+	}
+	user.PendingEmail = newEmail
+
+	// Profile is already updated, so failed verification email is not an update error -
+	// it could be sent again by user's request
+	if err := us.notifier.sendEmailVerification(ctx, newEmail, verificationToken, emailVerificationTTL); err != nil {
+		us.l.Error("sending verification email to user with id '%d': %v", user.Id, err)
+	}
+
+	return nil
+}
+
+// newVerificationToken saves and returns new email verification token for given email.
+// Previously issued tokens are invalidated, so only the latest email could be verified
+func (us *userService) newVerificationToken(ctx context.Context, userId int, email string) (string, error) {
+	plain, hash, err := us.token.NewOpaque()
+	if err != nil {
+		return "", fmt.Errorf("verification token generation error: %v", err)
+	}
+
+	err = us.withTx(ctx, func(tx *userService) error {
+		if err := tx.tokenRepo.DeleteOneTimeTokens(ctx, userId, entity.TokenPurposeEmailVerification); err != nil {
+			return err
+		}
+
+		return tx.tokenRepo.SaveOneTimeToken(ctx, &entity.OneTimeToken{
+			UserId:    userId,
+			Purpose:   entity.TokenPurposeEmailVerification,
+			TokenHash: hash,
+			Email:     &email,
+			ExpiresAt: time.Now().Add(emailVerificationTTL),
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return plain, nil
+}
+
+// IssueTokens starts new session of user without credentials. It is meant for operators
+// debugging the application from command line, so caller must have entity.PermTokensIssue
+// permission no role grants
+func (us *userService) IssueTokens(ctx context.Context, caller entity.Caller, idStr string) (entity.TokenPair, error) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return entity.TokenPair{}, entity.ErrInvalidUserId
+	}
+
+	if err := authorize(caller, ActionIssueTokens, id); err != nil {
+		return entity.TokenPair{}, err
+	}
+
+	user, err := us.userRepo.GetById(ctx, id)
+	if err != nil {
+		return entity.TokenPair{}, err
+	}
+
+	if user.DisabledAt != nil {
+		return entity.TokenPair{}, entity.ErrUserDisabled
+	}
+
+	return us.issueTokens(ctx, user, newSessionId())
+}
+
+// issueTokens signs new access token and creates new refresh token in given family
+func (us *userService) issueTokens(ctx context.Context, user entity.UserEntity, familyId string) (entity.TokenPair, error) {
+	roles, err := us.roleRepo.GetUserRoles(ctx, user.Id)
+	if err != nil {
+		return entity.TokenPair{}, err
+	}
+
+	token := us.token.New(us.auth.method, user.Id, user.Email, roles, familyId, us.auth.deadline)
+
+	accessToken, err := us.auth.sign(token)
+	if err != nil {
+		return entity.TokenPair{}, fmt.Errorf("token signing error: %v", err)
+	}
+
+	refreshToken, hash, err := us.token.NewOpaque()
+	if err != nil {
+		return entity.TokenPair{}, fmt.Errorf("refresh token generation error: %v", err)
+	}
+
+	err = us.tokenRepo.SaveRefreshToken(ctx, &entity.RefreshToken{
+		UserId:    user.Id,
+		FamilyId:  familyId,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(us.auth.refreshDeadline),
+	})
+	if err != nil {
+		return entity.TokenPair{}, err
+	}
+
+	return entity.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// newSessionId returns id of new log in session - every log in starts new refresh token family
+func newSessionId() string {
+	return uuid.NewString()
+}
+
+// revokeFamily revokes all refresh tokens of given family (and so access tokens issued
+// within it) and returns entity.ErrRefreshTokenReuse if revocation succeeded
+func (us *userService) revokeFamily(ctx context.Context, familyId string) error {
+	if err := us.tokenRepo.RevokeRefreshTokenFamily(ctx, familyId); err != nil {
+		return err
+	}
+	us.revoked.set(sessionKey(familyId), true, time.Now().Add(us.auth.deadline))
+
+	return entity.ErrRefreshTokenReuse
+}
+
+func (us *userService) revokeAccessToken(ctx context.Context, claims *data.Claims) error {
+	if err := us.tokenRepo.RevokeAccessToken(ctx, claims.ID, claims.UserId, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+	us.revoked.set(jtiKey(claims.ID), true, claims.ExpiresAt.Time)
+
+	return nil
 }
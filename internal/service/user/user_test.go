@@ -0,0 +1,286 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"inditilla/internal/data"
+	"inditilla/internal/entity"
+	"inditilla/internal/repository"
+	"inditilla/pkg/logger"
+	"inditilla/pkg/mailer"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "Password-1"
+
+// operator is caller with all permissions, like operator of the command line
+var operator = entity.Caller{
+	Email: "operator",
+	Permissions: []string{
+		entity.PermProfileReadAny,
+		entity.PermProfileUpdateAny,
+		entity.PermUsersRead,
+		entity.PermUsersManage,
+		entity.PermRolesManage,
+		entity.PermTokensIssue,
+	},
+}
+
+// newTestService returns user service with memory repositories, locking account after
+// 3 failed log in attempts
+func newTestService(t *testing.T) *userService {
+	t.Helper()
+
+	auth := NewAuthorizer([]byte("test-signing-key"), time.Hour, 24*time.Hour)
+	notifier := NewNotifier(mailer.NewWriter(io.Discard, "test@example.com"), "http://inditilla.test")
+
+	l := logger.NewWriter(io.Discard)
+
+	return NewUserService(l, repository.NewMemory(bcrypt.MinCost), auth, &data.TokenModel{Log: l}, notifier, nil, Policy{
+		Lockout: LockoutPolicy{MaxFailures: 3, Duration: 15 * time.Minute},
+	})
+}
+
+// signUp signs up user with given email and testPassword and returns user's id
+func signUp(t *testing.T, us *userService, email string) int {
+	t.Helper()
+
+	id, err := us.SignUp(context.Background(), &entity.UserSignupForm{
+		FirstName: "Ann",
+		LastName:  "Lee",
+		Email:     email,
+		Password:  testPassword,
+	})
+	if err != nil {
+		t.Fatalf("sign up %s: %v", email, err)
+	}
+
+	return id
+}
+
+func signIn(us *userService, email, password string) (entity.LoginResult, error) {
+	return us.SignIn(context.Background(), &entity.UserLoginForm{Email: email, Password: password, IP: "192.0.2.1"})
+}
+
+func TestSetPassword(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("validation", func(t *testing.T) {
+		us := newTestService(t)
+		id := strconv.Itoa(signUp(t, us, "ann@example.com"))
+
+		tests := []struct {
+			name     string
+			caller   entity.Caller
+			id       string
+			password string
+			wantErr  error
+			field    string
+		}{
+			{"invalid id", operator, "abc", "New-password-1", entity.ErrInvalidUserId, ""},
+			{"unknown user", operator, "999", "New-password-1", entity.ErrNoRecord, ""},
+			{"other user", entity.Caller{Id: 999}, id, "New-password-1", entity.ErrForbidden, ""},
+			{"blank password", operator, id, "", entity.ErrInvalidInputData, "password"},
+			{"short password", operator, id, "short", entity.ErrInvalidInputData, "password"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				form := entity.PasswordForm{Password: tt.password}
+
+				err := us.SetPassword(ctx, tt.caller, tt.id, &form)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("SetPassword error = %v; want %v", err, tt.wantErr)
+				}
+				if tt.field != "" && form.FieldErrors[tt.field] == "" {
+					t.Errorf("no validation error of field %q, errors: %v", tt.field, form.FieldErrors)
+				}
+			})
+		}
+
+		// Password is left as is after failed attempts
+		if _, err := signIn(us, "ann@example.com", testPassword); err != nil {
+			t.Errorf("sign in with old password: %v", err)
+		}
+	})
+
+	t.Run("changes password and revokes sessions", func(t *testing.T) {
+		us := newTestService(t)
+		id := signUp(t, us, "ann@example.com")
+
+		first, err := signIn(us, "ann@example.com", testPassword)
+		if err != nil {
+			t.Fatalf("sign in: %v", err)
+		}
+		second, err := signIn(us, "ann@example.com", testPassword)
+		if err != nil {
+			t.Fatalf("sign in: %v", err)
+		}
+
+		// Refresh token rotated before is revoked along with the current one
+		rotated, err := us.Refresh(ctx, &entity.RefreshTokenForm{RefreshToken: second.Tokens.RefreshToken})
+		if err != nil {
+			t.Fatalf("refresh: %v", err)
+		}
+
+		if err := us.SetPassword(ctx, operator, strconv.Itoa(id), &entity.PasswordForm{Password: "New-password-1"}); err != nil {
+			t.Fatalf("SetPassword: %v", err)
+		}
+
+		for name, refreshToken := range map[string]string{
+			"first session":  first.Tokens.RefreshToken,
+			"second session": rotated.RefreshToken,
+		} {
+			if _, err := us.Refresh(ctx, &entity.RefreshTokenForm{RefreshToken: refreshToken}); !errors.Is(err, entity.ErrInvalidRefreshToken) {
+				t.Errorf("refresh of %s error = %v; want %v", name, err, entity.ErrInvalidRefreshToken)
+			}
+		}
+
+		for name, accessToken := range map[string]string{
+			"first session":  first.Tokens.AccessToken,
+			"second session": rotated.AccessToken,
+		} {
+			claims, err := us.ParseToken(accessToken)
+			if err != nil {
+				t.Fatalf("parse access token of %s: %v", name, err)
+			}
+			if revoked, err := us.IsRevoked(ctx, claims); err != nil || !revoked {
+				t.Errorf("access token of %s revoked = %v, %v; want true", name, revoked, err)
+			}
+		}
+
+		if _, err := signIn(us, "ann@example.com", testPassword); !errors.Is(err, entity.ErrInvalidCredentials) {
+			t.Errorf("sign in with old password error = %v; want %v", err, entity.ErrInvalidCredentials)
+		}
+		if _, err := signIn(us, "ann@example.com", "New-password-1"); err != nil {
+			t.Errorf("sign in with new password: %v", err)
+		}
+	})
+
+	t.Run("user sets own password", func(t *testing.T) {
+		us := newTestService(t)
+		id := signUp(t, us, "ann@example.com")
+
+		if err := us.SetPassword(ctx, entity.Caller{Id: id}, strconv.Itoa(id), &entity.PasswordForm{Password: "New-password-1"}); err != nil {
+			t.Fatalf("SetPassword: %v", err)
+		}
+	})
+
+	t.Run("unlocks account", func(t *testing.T) {
+		us := newTestService(t)
+		id := signUp(t, us, "ann@example.com")
+
+		for i := 0; i < 3; i++ {
+			if _, err := signIn(us, "ann@example.com", "wrong-password"); !errors.Is(err, entity.ErrInvalidCredentials) {
+				t.Fatalf("sign in with wrong password error = %v; want %v", err, entity.ErrInvalidCredentials)
+			}
+		}
+
+		var retryErr *entity.RetryError
+		if _, err := signIn(us, "ann@example.com", testPassword); !errors.As(err, &retryErr) {
+			t.Fatalf("sign in of locked account error = %v; want RetryError", err)
+		}
+
+		if err := us.SetPassword(ctx, operator, strconv.Itoa(id), &entity.PasswordForm{Password: "New-password-1"}); err != nil {
+			t.Fatalf("SetPassword: %v", err)
+		}
+
+		blockedUntil, err := us.lockoutRepo.GetBlockedUntil(ctx, []string{entity.LoginEmailKey("ann@example.com")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if blockedUntil != nil {
+			t.Errorf("account is blocked until %v after password is set", blockedUntil)
+		}
+
+		if _, err := signIn(us, "ann@example.com", "New-password-1"); err != nil {
+			t.Errorf("sign in with new password: %v", err)
+		}
+	})
+}
+
+func TestIssueTokens(t *testing.T) {
+	ctx := context.Background()
+	us := newTestService(t)
+	id := signUp(t, us, "ann@example.com")
+
+	// Admin is granted every permission a role could grant, but not the operator's one
+	admin := entity.Caller{
+		Id:          999,
+		Roles:       []string{entity.RoleAdmin},
+		Permissions: []string{entity.PermProfileReadAny, entity.PermProfileUpdateAny, entity.PermUsersRead, entity.PermUsersManage, entity.PermRolesManage},
+	}
+
+	tests := []struct {
+		name    string
+		caller  entity.Caller
+		id      string
+		wantErr error
+	}{
+		{"operator", operator, strconv.Itoa(id), nil},
+		{"invalid id", operator, "abc", entity.ErrInvalidUserId},
+		{"unknown user", operator, "999", entity.ErrNoRecord},
+		{"admin", admin, strconv.Itoa(id), entity.ErrForbidden},
+		{"user itself", entity.Caller{Id: id}, strconv.Itoa(id), entity.ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := us.IssueTokens(ctx, tt.caller, tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IssueTokens error = %v; want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			claims, err := us.ParseToken(tokens.AccessToken)
+			if err != nil {
+				t.Fatalf("parse issued token: %v", err)
+			}
+			if claims.UserId != id {
+				t.Errorf("token is issued to user %d; want %d", claims.UserId, id)
+			}
+		})
+	}
+}
+
+// failingMailer is mailer every message fails to be sent with
+type failingMailer struct{}
+
+func (failingMailer) Send(context.Context, mailer.Message) error {
+	return errors.New("mail server is down")
+}
+
+func TestUpdateEmailMailFailure(t *testing.T) {
+	ctx := context.Background()
+	us := newTestService(t)
+	id := signUp(t, us, "ann@example.com")
+	caller := entity.Caller{Id: id}
+
+	us.notifier = NewNotifier(failingMailer{}, "http://inditilla.test")
+
+	user, err := us.GetById(ctx, caller, strconv.Itoa(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Email = "anna@example.com"
+
+	// Update is committed before email is sent, so failed email does not fail it
+	if err := us.Update(ctx, caller, &user, false); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	user, err = us.GetById(ctx, caller, strconv.Itoa(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "ann@example.com" || user.PendingEmail != "anna@example.com" {
+		t.Errorf("email = %q, pending email = %q; want ann@example.com, anna@example.com", user.Email, user.PendingEmail)
+	}
+}
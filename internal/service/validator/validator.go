@@ -47,3 +47,13 @@ func MinChar(str string, n int) bool {
 func Matches(str string, rx *regexp.Regexp) bool {
 	return rx.MatchString(str)
 }
+
+func PermittedValue(value string, permittedValues ...string) bool {
+	for i := range permittedValues {
+		if value == permittedValues[i] {
+			return true
+		}
+	}
+
+	return false
+}
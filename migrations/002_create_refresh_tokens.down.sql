@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS refresh_tokens_user_index;
+DROP INDEX IF EXISTS refresh_tokens_family_index;
+DROP TABLE IF EXISTS refresh_tokens;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS revoked_tokens_expires_index;
+DROP TABLE IF EXISTS revoked_tokens;
\ No newline at end of file
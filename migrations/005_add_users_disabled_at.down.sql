@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_created_at_index;
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS one_time_tokens_user_index;
+DROP TABLE IF EXISTS one_time_tokens;
\ No newline at end of file
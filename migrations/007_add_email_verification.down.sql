@@ -0,0 +1,2 @@
+ALTER TABLE one_time_tokens DROP COLUMN IF EXISTS email;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
\ No newline at end of file
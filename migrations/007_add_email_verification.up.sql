@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITHOUT TIME ZONE;
+
+-- Email the token was sent to, used for email verification tokens
+ALTER TABLE one_time_tokens ADD COLUMN IF NOT EXISTS email VARCHAR(255);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS login_failures_last_failed_index;
+DROP TABLE IF EXISTS login_failures;
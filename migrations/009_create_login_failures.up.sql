@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS login_failures (
+    key VARCHAR(300) PRIMARY KEY NOT NULL,
+    failures INTEGER DEFAULT 0 NOT NULL,
+    last_failed_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    blocked_until TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS login_failures_last_failed_index ON login_failures (last_failed_at);
@@ -0,0 +1,7 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(100) DEFAULT '' NOT NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio VARCHAR(500) DEFAULT '' NOT NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone VARCHAR(16) DEFAULT '' NOT NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS date_of_birth DATE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(35) DEFAULT '' NOT NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) DEFAULT '' NOT NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS website VARCHAR(2048) DEFAULT '' NOT NULL;
@@ -0,0 +1,3 @@
+UPDATE users SET created_at = created_at + INTERVAL '6 hours';
+
+ALTER TABLE users ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC' + INTERVAL '6 hours');
\ No newline at end of file
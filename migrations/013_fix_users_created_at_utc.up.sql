@@ -0,0 +1,4 @@
+-- Creation time was stored 6 hours ahead of UTC, unlike other timestamps
+ALTER TABLE users ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+
+UPDATE users SET created_at = created_at - INTERVAL '6 hours';
\ No newline at end of file
@@ -0,0 +1,10 @@
+// Package migrations embeds SQL migrations of the database, so the binary
+// migrates database without migrations directory next to it
+package migrations
+
+import "embed"
+
+// FS holds migrations named '<version>_<name>.<up|down>.sql'
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,22 @@
+// Package blob stores binary objects (e.g. uploaded images) by key. Keys are slash
+// separated paths like 'avatars/12/abc-64.jpg'
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrInvalidKey = errors.New("blob: invalid key")
+
+type BlobStore interface {
+	// Put stores content read from 'r' by key, replacing existing blob
+	Put(ctx context.Context, key string, r io.Reader) error
+
+	// Delete removes blob by key, missing blob is not an error
+	Delete(ctx context.Context, key string) error
+
+	// URL returns URL blob is publicly served by
+	URL(key string) string
+}
@@ -0,0 +1,102 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore stores blobs as files under root directory, so they could be served
+// by static file server at base URL
+type LocalStore struct {
+	root    string
+	baseURL string
+}
+
+// This ensures that LocalStore struct implements BlobStore interface
+var _ BlobStore = (*LocalStore)(nil)
+
+// NewLocal returns store keeping blobs in 'root' directory, which is created if missing.
+// Blob URLs are its key appended to 'baseURL', e.g. '/static/'
+func NewLocal(root, baseURL string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/") + "/",
+	}, nil
+}
+
+// Put writes blob to temporary file first and renames it, so readers never see partly written blob
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *LocalStore) URL(key string) string {
+	return s.baseURL + key
+}
+
+// path returns file path of blob by key. Keys must be clean relative paths naming file
+// under root directory, so blobs could not be written outside of it or replace it.
+// Backslashes and NUL bytes are rejected as well, since they are special in file paths
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || key == "." || !fs.ValidPath(key) || path.Clean(key) != key || strings.ContainsAny(key, "\\\x00") {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
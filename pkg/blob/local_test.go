@@ -0,0 +1,127 @@
+package blob_test
+
+import (
+	"context"
+	"errors"
+	"inditilla/pkg/blob"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	root := filepath.Join(t.TempDir(), "static")
+
+	s, err := blob.NewLocal(root, "/static/")
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	key := "avatars/12/abc-64.jpg"
+	name := filepath.Join(root, "avatars", "12", "abc-64.jpg")
+
+	if err := s.Put(ctx, key, strings.NewReader("first")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := s.Put(ctx, key, strings.NewReader("second")); err != nil {
+		t.Fatalf("Put replacing blob: %v", err)
+	}
+
+	content, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("read blob file: %v", err)
+	}
+	if string(content) != "second" {
+		t.Errorf("blob content = %q; want %q", content, "second")
+	}
+
+	// Temporary upload files are not left behind
+	entries, err := os.ReadDir(filepath.Dir(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d files; want 1", len(entries))
+	}
+
+	if url := s.URL(key); url != "/static/avatars/12/abc-64.jpg" {
+		t.Errorf("URL = %q; want %q", url, "/static/avatars/12/abc-64.jpg")
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("blob file exists after Delete: %v", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of missing blob: %v", err)
+	}
+}
+
+func TestLocalStoreInvalidKey(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	root := filepath.Join(dir, "static")
+
+	s, err := blob.NewLocal(root, "/static")
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	// File next to root directory, which keys must not reach
+	outside := filepath.Join(dir, "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{
+		"",
+		".",
+		"..",
+		"../secret",
+		"avatars/../../secret",
+		"/etc/passwd",
+		"avatars/",
+		"avatars//abc.jpg",
+		"avatars/./abc.jpg",
+		"./avatars/abc.jpg",
+		"avatars\x00/abc.jpg",
+		`avatars\..\..\secret`,
+	}
+
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			if err := s.Put(ctx, key, strings.NewReader("evil")); !errors.Is(err, blob.ErrInvalidKey) {
+				t.Errorf("Put error = %v; want %v", err, blob.ErrInvalidKey)
+			}
+			if err := s.Delete(ctx, key); !errors.Is(err, blob.ErrInvalidKey) {
+				t.Errorf("Delete error = %v; want %v", err, blob.ErrInvalidKey)
+			}
+		})
+	}
+
+	content, err := os.ReadFile(outside)
+	if err != nil || string(content) != "secret" {
+		t.Errorf("file outside of root = %q, %v; want it untouched", content, err)
+	}
+}
+
+func TestLocalStoreCanceled(t *testing.T) {
+	s, err := blob.NewLocal(t.TempDir(), "/static/")
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.Put(ctx, "abc.jpg", strings.NewReader("abc")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Put error = %v; want %v", err, context.Canceled)
+	}
+	if err := s.Delete(ctx, "abc.jpg"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Delete error = %v; want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,150 @@
+// Package health checks whether application and its dependencies (e.g. database)
+// are able to serve requests. Dependencies register their checkers in Registry
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOk   = "ok"
+	StatusFail = "fail"
+)
+
+// ErrShuttingDown is result of readiness check of application that is shutting down
+var ErrShuttingDown = errors.New("health: application is shutting down")
+
+// HealthChecker checks single dependency. Check returns error if dependency is not
+// usable, it must return once context is done
+type HealthChecker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is function used as HealthChecker
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Report is result of all checks, it is ok only if every check is ok
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Result is result of single check
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Registry holds checkers of dependencies by name. Checks run concurrently, each is
+// failed if it does not complete within timeout
+type Registry struct {
+	timeout time.Duration
+
+	mu       sync.RWMutex
+	checkers map[string]HealthChecker
+
+	shuttingDown atomic.Bool
+}
+
+func New(timeout time.Duration) *Registry {
+	return &Registry{
+		timeout:  timeout,
+		checkers: make(map[string]HealthChecker),
+	}
+}
+
+// Register adds checker with given name, checker registered with the same name is replaced
+func (r *Registry) Register(name string, checker HealthChecker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkers[name] = checker
+}
+
+// SetShuttingDown makes application not ready, so no new requests are routed to it while
+// requests in flight complete
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Ready runs all checks and reports whether application is ready to serve requests
+func (r *Registry) Ready(ctx context.Context) Report {
+	r.mu.RLock()
+	checkers := make(map[string]HealthChecker, len(r.checkers)+1)
+	for name, checker := range r.checkers {
+		checkers[name] = checker
+	}
+	r.mu.RUnlock()
+
+	checkers["shutdown"] = CheckerFunc(func(context.Context) error {
+		if r.shuttingDown.Load() {
+			return ErrShuttingDown
+		}
+		return nil
+	})
+
+	report := Report{Status: StatusOk, Checks: make(map[string]Result, len(checkers))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker HealthChecker) {
+			defer wg.Done()
+
+			result := r.check(ctx, checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[name] = result
+			if result.Status != StatusOk {
+				report.Status = StatusFail
+			}
+		}(name, checker)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// check runs single check with timeout
+func (r *Registry) check(ctx context.Context, checker HealthChecker) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	// Checker that ignores context is not waited for longer than timeout
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Status: StatusOk, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,74 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/dgrijalva/jwt-go/v4"
+)
+
+// SigningMethodEd25519 implements EdDSA signing method over Ed25519 curve (RFC 8037),
+// which is not provided by jwt package itself. Expects ed25519.PrivateKey (or crypto.Signer)
+// for signing and ed25519.PublicKey for validation
+type SigningMethodEd25519 struct{}
+
+var SigningMethodEdDSA *SigningMethodEd25519
+
+func init() {
+	SigningMethodEdDSA = &SigningMethodEd25519{}
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+func (m *SigningMethodEd25519) Alg() string {
+	return "EdDSA"
+}
+
+func (m *SigningMethodEd25519) Verify(signingString, signature string, key interface{}) error {
+	var pub ed25519.PublicKey
+
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		pub = k
+	case crypto.Signer:
+		var ok bool
+		if pub, ok = k.Public().(ed25519.PublicKey); !ok {
+			return &jwt.InvalidKeyError{Message: fmt.Sprintf("signer returned unexpected public key type: %T", k.Public())}
+		}
+	default:
+		return jwt.NewInvalidKeyTypeError("ed25519.PublicKey or crypto.Signer", key)
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(pub, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+
+	return nil
+}
+
+func (m *SigningMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return "", jwt.NewInvalidKeyTypeError("ed25519.PrivateKey or crypto.Signer", key)
+	}
+
+	if _, ok := signer.Public().(ed25519.PublicKey); !ok {
+		return "", &jwt.InvalidKeyError{Message: fmt.Sprintf("signer returned unexpected public key type: %T", signer.Public())}
+	}
+
+	// Ed25519 signs the whole message, so no hash function is passed
+	sig, err := signer.Sign(rand.Reader, []byte(signingString), crypto.Hash(0))
+	if err != nil {
+		return "", err
+	}
+
+	return jwt.EncodeSegment(sig), nil
+}
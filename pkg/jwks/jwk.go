@@ -0,0 +1,66 @@
+package jwks
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// Set is a JWK set served to clients verifying tokens
+type Set struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewSet returns JWK set with public parts of given keys
+func NewSet(keys []Key) (Set, error) {
+	set := Set{Keys: make([]JWK, 0, len(keys))}
+
+	for _, key := range keys {
+		jwk, err := NewJWK(key)
+		if err != nil {
+			return Set{}, err
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+// NewJWK converts public part of given key into JWK
+func NewJWK(key Key) (JWK, error) {
+	jwk := JWK{
+		Use: "sig",
+		Kid: key.Id,
+		Alg: key.Method.Alg(),
+	}
+
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", key.Public)
+	}
+
+	return jwk, nil
+}
@@ -0,0 +1,104 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go/v4"
+)
+
+// Key is a verification key identified by key id ('kid' header of the token).
+// Signer is set only if private key is available, so key may be used for signing
+type Key struct {
+	Id     string
+	Method jwt.SigningMethod
+	Public crypto.PublicKey
+	Signer crypto.Signer
+}
+
+// LoadDir loads all '*.pem' keys in given directory. File name without extension
+// is used as key id. Files may contain PKCS#8 or PKCS#1 private keys or PKIX public
+// keys - the latter are useful to keep verifying tokens signed with retired keys
+func LoadDir(dir string) ([]Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no '*.pem' keys found in %q", dir)
+	}
+
+	sort.Strings(paths)
+
+	keys := make([]Key, 0, len(paths))
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+
+		key, err := ParsePEM(id, raw)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", path, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// ParsePEM parses single PEM encoded RSA or Ed25519 key with given key id
+func ParsePEM(id string, raw []byte) (Key, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return Key{}, errors.New("no PEM data found")
+	}
+
+	var parsed interface{}
+	var err error
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+
+	if err != nil {
+		return Key{}, err
+	}
+
+	key := Key{Id: id}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Public, key.Signer = jwt.SigningMethodRS256, &k.PublicKey, k
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Public, key.Signer = SigningMethodEdDSA, k.Public(), k
+	case ed25519.PublicKey:
+		key.Method, key.Public = SigningMethodEdDSA, k
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
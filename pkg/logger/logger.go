@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -68,6 +69,15 @@ func New(lvl string) (*Logger, func() error) {
 	}, fileWriter.Close
 }
 
+// NewWriter returns logger writing only to given writer, e.g. io.Discard in tests
+func NewWriter(w io.Writer) *Logger {
+	logger := zerolog.New(w).With().Timestamp().Logger()
+
+	return &Logger{
+		logger: &logger,
+	}
+}
+
 func (l *Logger) Info(message string, args ...interface{}) {
 	l.log(message, args...)
 }
@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+)
+
+// WriterMailer writes messages to given writer instead of sending them. It is meant
+// for local development and tests, where messages are written to stdout or file
+type WriterMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+// This ensures that WriterMailer struct implements Mailer interface
+var _ Mailer = (*WriterMailer)(nil)
+
+func NewWriter(w io.Writer, from string) *WriterMailer {
+	return &WriterMailer{
+		w:    w,
+		from: from,
+	}
+}
+
+// NewFile returns mailer appending messages to file with given path and file
+// closing function that should be defered when this function is called
+func NewFile(path, from string) (*WriterMailer, func() error, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return NewWriter(f, from), f.Close, nil
+}
+
+func (m *WriterMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.w.Write(format(m.from, msg)); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(m.w, "\r\n")
+
+	return err
+}
@@ -0,0 +1,170 @@
+// Package metrics collects Prometheus metrics of http requests, database queries and
+// authentication events. Nil *Metrics records nothing, so metrics may be disabled
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "inditilla"
+
+// Results of log in attempts
+const (
+	LoginSuccess = "success" // Tokens were issued
+	LoginFailure = "failure" // Credentials or second factor were rejected
+	LoginBlocked = "blocked" // Attempt was not allowed by log in throttling
+)
+
+// MethodOther is label of requests with method outside of the standard set
+const MethodOther = "OTHER"
+
+// methods are standard http methods requests are labelled by
+var methods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Method returns label of request method. Method is set by client, so any method outside
+// of the standard set is labelled MethodOther to keep number of label values bounded
+func Method(method string) string {
+	if methods[method] {
+		return method
+	}
+
+	return MethodOther
+}
+
+// Metrics holds collectors of the application registered in its own registry
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+	signups         prometheus.Counter
+	logins          *prometheus.CounterVec
+	tokenFailures   *prometheus.CounterVec
+}
+
+// New returns Metrics with all collectors, along with Go runtime and process collectors
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of http requests by method, route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of http requests by method, route pattern and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Duration of database queries by repository operation and result.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "result"}),
+		signups: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_signups_total",
+			Help:      "Number of users signed up.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_logins_total",
+			Help:      "Number of log in attempts by result (success, failure, blocked).",
+		}, []string{"result"}),
+		tokenFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_token_validation_failures_total",
+			Help:      "Number of requests with rejected access token by reason.",
+		}, []string{"reason"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.queryDuration,
+		m.signups,
+		m.logins,
+		m.tokenFailures,
+	)
+
+	return m
+}
+
+// Handler serves metrics in Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records http request served by route with given pattern (e.g. /v1/user/profile/:id)
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	method, code := Method(method), strconv.Itoa(status)
+	m.requests.WithLabelValues(method, route, code).Inc()
+	m.requestDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// ObserveQuery records database query run by given repository operation (e.g. user.GetById)
+func (m *Metrics) ObserveQuery(operation string, err error, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	m.queryDuration.WithLabelValues(operation, result).Observe(duration.Seconds())
+}
+
+// Signup records signed up user
+func (m *Metrics) Signup() {
+	if m == nil {
+		return
+	}
+
+	m.signups.Inc()
+}
+
+// Login records log in attempt with given result, one of Login* constants
+func (m *Metrics) Login(result string) {
+	if m == nil {
+		return
+	}
+
+	m.logins.WithLabelValues(result).Inc()
+}
+
+// TokenValidationFailed records request rejected because of its access token, e.g. 'expired'
+func (m *Metrics) TokenValidationFailed(reason string) {
+	if m == nil {
+		return
+	}
+
+	m.tokenFailures.WithLabelValues(reason).Inc()
+}
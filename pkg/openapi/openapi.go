@@ -0,0 +1,255 @@
+// Package openapi builds OpenAPI 3 documents. Schemas are derived from Go types by
+// reflection following encoding/json rules for 'json' tags
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem holds operations of single path by lower case method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"` // One of path, query, header
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// New returns empty document with given title and version of the API
+func New(title, version string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]SecurityScheme),
+		},
+	}
+}
+
+// Add adds operation of given method to the path. Path is in httprouter format,
+// its ':name' parameters are converted to '{name}' and added to the operation
+func (d *Document) Add(method, path string, op *Operation) {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, ":") {
+			name := strings.TrimPrefix(segment, ":")
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+			segment = "{" + name + "}"
+		}
+		segments = append(segments, segment)
+	}
+
+	path = strings.Join(segments, "/")
+
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	(*item)[strings.ToLower(method)] = op
+}
+
+// JSON returns JSON media type content with schema of given value
+func (d *Document) JSON(v interface{}) map[string]MediaType {
+	return map[string]MediaType{
+		"application/json": {Schema: d.Schema(v)},
+	}
+}
+
+// File returns multipart form content with single required binary file in given field
+func File(field string) map[string]MediaType {
+	return map[string]MediaType{
+		"multipart/form-data": {Schema: &Schema{
+			Type:       "object",
+			Properties: map[string]*Schema{field: {Type: "string", Format: "binary"}},
+			Required:   []string{field},
+		}},
+	}
+}
+
+// Schema returns schema of given value type. Named struct types are added to
+// document's components and referenced
+func (d *Document) Schema(v interface{}) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+// QueryParameters returns query parameters described by fields of given struct
+// with 'form' tags. Fields without tag or with '-' tag are skipped
+func (d *Document) QueryParameters(v interface{}) []Parameter {
+	var params []Parameter
+
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name := strings.Split(f.Tag.Get("form"), ",")[0]
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+
+		params = append(params, Parameter{
+			Name:   name,
+			In:     "query",
+			Schema: d.schemaOf(f.Type),
+		})
+	}
+
+	return params
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		s := d.schemaOf(t.Elem())
+		if s.Ref != "" {
+			return s
+		}
+		s.Nullable = true
+		return s
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		if _, ok := d.Components.Schemas[t.Name()]; !ok {
+			// Placeholder guards against infinite recursion of self referencing types
+			d.Components.Schemas[t.Name()] = &Schema{}
+			d.Components.Schemas[t.Name()] = d.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return d.structSchema(t)
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", Nullable: true, AdditionalProperties: d.schemaOf(t.Elem())}
+	default:
+		return &Schema{}
+	}
+}
+
+// structSchema returns object schema with properties of struct fields. Fields without
+// 'omitempty' option which are not pointers are required
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+
+		// Fields of embedded structs without name are promoted, as encoding/json does
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded := d.structSchema(f.Type)
+			for prop, schema := range embedded.Properties {
+				s.Properties[prop] = schema
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = d.schemaOf(f.Type)
+
+		omitEmpty := len(tag) > 1 && strings.Contains(strings.Join(tag[1:], ","), "omitempty")
+		if !omitEmpty && f.Type.Kind() != reflect.Pointer {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return s
+}
@@ -1,24 +1,23 @@
 package parser
 
 import (
-	"fmt"
+	"errors"
 	"inditilla/internal/data"
 	"inditilla/internal/entity"
 
 	"github.com/dgrijalva/jwt-go/v4"
 )
 
-// ParseToken parses given raw token with given signing key and returns
-// custom claims extracted from token
-func ParseToken(accessToken string, signingKey []byte) (*data.Claims, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &data.Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		return signingKey, nil
-	})
-
+// ParseToken parses given raw token verifying it with the key returned by given
+// key function and returns custom claims extracted from token. Expired tokens are
+// rejected with entity.ErrExpiredAccessToken
+func ParseToken(accessToken string, keyFunc jwt.Keyfunc) (*data.Claims, error) {
+	token, err := jwt.ParseWithClaims(accessToken, &data.Claims{}, keyFunc)
 	if err != nil {
+		var expired *jwt.TokenExpiredError
+		if errors.As(err, &expired) {
+			return nil, entity.ErrExpiredAccessToken
+		}
 		return nil, err
 	}
 
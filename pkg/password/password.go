@@ -0,0 +1,42 @@
+// Package password hashes passwords with bcrypt. Hashing is traced, as it takes most
+// of the time of sign up, log in and password change
+package password
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var tracer = otel.Tracer("inditilla/pkg/password")
+
+// Hash returns bcrypt hash of password with given cost
+func Hash(ctx context.Context, password string, cost int) ([]byte, error) {
+	_, span := tracer.Start(ctx, "bcrypt.GenerateFromPassword")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("bcrypt.cost", cost))
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return hash, err
+}
+
+// Compare compares bcrypt hash with password. It returns bcrypt.ErrMismatchedHashAndPassword
+// if password does not match hash
+func Compare(ctx context.Context, hash []byte, password string) error {
+	_, span := tracer.Start(ctx, "bcrypt.CompareHashAndPassword")
+	defer span.End()
+
+	if cost, err := bcrypt.Cost(hash); err == nil {
+		span.SetAttributes(attribute.Int("bcrypt.cost", cost))
+	}
+
+	return bcrypt.CompareHashAndPassword(hash, []byte(password))
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const pruneInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is time the bucket becomes full, it could be forgotten after it
+	full time.Time
+}
+
+// MemoryStore keeps buckets in memory of the process. Full buckets are pruned,
+// since new bucket is full anyway
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*bucket),
+		lastPrune: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	capacity := float64(limit.Requests)
+	rate := limit.rate()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity}
+		s.buckets[key] = b
+	} else {
+		b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	}
+	b.updated = now
+
+	res := Result{Limit: limit.Requests}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((capacity - b.tokens) / rate)
+	b.full = now.Add(res.Reset)
+
+	s.prune(now)
+
+	return res, nil
+}
+
+// prune deletes full buckets, it is done at most once per pruneInterval.
+// Must be called with mutex locked
+func (s *MemoryStore) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < pruneInterval {
+		return
+	}
+
+	for key, b := range s.buckets {
+		if now.After(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+
+	s.lastPrune = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,228 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// clock is fake time of the store, which is advanced by tests
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestStore() (*MemoryStore, *clock) {
+	c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	s := NewMemoryStore()
+	s.now = c.Now
+	s.lastPrune = c.now
+
+	return s, c
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	// Bucket of 5 tokens refilled with one token per 2 seconds
+	limit := Limit{Requests: 5, Period: 10 * time.Second}
+
+	type take struct {
+		after time.Duration // time passed since previous take
+		want  Result
+	}
+
+	allowed := func(remaining int, reset time.Duration) Result {
+		return Result{Allowed: true, Limit: 5, Remaining: remaining, Reset: reset}
+	}
+	denied := func(reset, retryAfter time.Duration) Result {
+		return Result{Limit: 5, Reset: reset, RetryAfter: retryAfter}
+	}
+
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{
+			"burst up to capacity",
+			[]take{
+				{0, allowed(4, 2*time.Second)},
+				{0, allowed(3, 4*time.Second)},
+				{0, allowed(2, 6*time.Second)},
+				{0, allowed(1, 8*time.Second)},
+				{0, allowed(0, 10*time.Second)},
+				{0, denied(10*time.Second, 2*time.Second)},
+			},
+		},
+		{
+			"refill after burst",
+			[]take{
+				{0, allowed(4, 2*time.Second)},
+				{0, allowed(3, 4*time.Second)},
+				{0, allowed(2, 6*time.Second)},
+				{0, allowed(1, 8*time.Second)},
+				{0, allowed(0, 10*time.Second)},
+				{time.Second, denied(9*time.Second, time.Second)},
+				{time.Second, allowed(0, 10*time.Second)},
+				{3 * time.Second, allowed(0, 9*time.Second)},
+				{500 * time.Millisecond, denied(8500*time.Millisecond, 500*time.Millisecond)},
+			},
+		},
+		{
+			"refill is capped by capacity",
+			[]take{
+				{0, allowed(4, 2*time.Second)},
+				{time.Hour, allowed(4, 2*time.Second)},
+			},
+		},
+		{
+			"reset is time until bucket is full",
+			[]take{
+				{0, allowed(4, 2*time.Second)},
+				{0, allowed(3, 4*time.Second)},
+				{4 * time.Second, allowed(4, 2*time.Second)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, c := newTestStore()
+
+			for i, take := range tt.takes {
+				c.Advance(take.after)
+
+				got, err := s.Take(context.Background(), "key", limit)
+				if err != nil {
+					t.Fatalf("take %d: %v", i, err)
+				}
+				if got != take.want {
+					t.Errorf("take %d = %+v; want %+v", i, got, take.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeys(t *testing.T) {
+	s, _ := newTestStore()
+	ctx := context.Background()
+	limit := Limit{Requests: 1, Period: time.Minute}
+
+	if res, _ := s.Take(ctx, "a", limit); !res.Allowed {
+		t.Fatal("first take of key a is not allowed")
+	}
+	if res, _ := s.Take(ctx, "a", limit); res.Allowed {
+		t.Error("second take of key a is allowed")
+	}
+	if res, _ := s.Take(ctx, "b", limit); !res.Allowed {
+		t.Error("take of key b is not allowed after key a is exhausted")
+	}
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	ctx := context.Background()
+	// Bucket is full again a minute after a take
+	limit := Limit{Requests: 10, Period: 10 * time.Minute}
+
+	tests := []struct {
+		name       string
+		after      time.Duration // time passed since bucket was taken from, when other key is taken
+		wantPruned bool
+	}{
+		{"not pruned before prune interval", pruneInterval / 2, false},
+		{"not pruned until full", pruneInterval, false},
+		{"pruned when full", pruneInterval + time.Second, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, c := newTestStore()
+
+			if _, err := s.Take(ctx, "key", limit); err != nil {
+				t.Fatal(err)
+			}
+
+			c.Advance(tt.after)
+			if _, err := s.Take(ctx, "other", limit); err != nil {
+				t.Fatal(err)
+			}
+
+			_, ok := s.buckets["key"]
+			if ok == tt.wantPruned {
+				t.Errorf("bucket kept = %v; want %v", ok, !tt.wantPruned)
+			}
+			if _, ok := s.buckets["other"]; !ok {
+				t.Error("bucket just taken from is pruned")
+			}
+		})
+	}
+
+	t.Run("at most once per interval", func(t *testing.T) {
+		s, c := newTestStore()
+
+		take := func(key string, after time.Duration) {
+			t.Helper()
+
+			c.Advance(after)
+			if _, err := s.Take(ctx, key, limit); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		take("a", 0)
+		take("b", 30*time.Second)
+		take("c", 31*time.Second) // prunes "a"
+
+		// "b" is full by now, but buckets were pruned less than interval ago
+		take("d", 30*time.Second)
+		if _, ok := s.buckets["b"]; !ok {
+			t.Error("bucket is pruned twice within interval")
+		}
+
+		take("e", 30*time.Second)
+		if _, ok := s.buckets["b"]; ok {
+			t.Error("full bucket is not pruned at next interval")
+		}
+		if _, ok := s.buckets["a"]; ok {
+			t.Error("full bucket is not pruned")
+		}
+	})
+}
+
+func TestLimiter(t *testing.T) {
+	l := New(NewMemoryStore(), Limit{Requests: 100, Period: time.Minute}, map[string]Limit{
+		"login":     {Requests: 5, Period: time.Minute},
+		"disabled":  {Requests: 0, Period: time.Minute},
+		"no period": {Requests: 5},
+	})
+
+	if limit, ok := l.Global(); !ok || limit.Requests != 100 {
+		t.Errorf("Global = %+v, %v; want 100 requests, enabled", limit, ok)
+	}
+
+	tests := []struct {
+		route string
+		want  bool
+	}{
+		{"login", true},
+		{"disabled", false},
+		{"no period", false},
+		{"signup", false},
+	}
+	for _, tt := range tests {
+		if _, ok := l.Route(tt.route); ok != tt.want {
+			t.Errorf("Route(%q) enabled = %v; want %v", tt.route, ok, tt.want)
+		}
+	}
+
+	if _, ok := New(NewMemoryStore(), Limit{}, nil).Global(); ok {
+		t.Error("zero global limit is enabled")
+	}
+}
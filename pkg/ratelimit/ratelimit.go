@@ -0,0 +1,73 @@
+// Package ratelimit implements token bucket rate limiting. Buckets are kept by
+// a Store, so limits could be shared between server instances
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit allows Requests per Period. Bucket holds up to Requests tokens and is
+// refilled at Requests/Period rate, so short bursts up to Requests are allowed
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Result is result of taking token from a bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is time until next token is available, set if request was not allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps buckets by keys. Implementations must be safe for concurrent use
+type Store interface {
+	// Take takes one token from the bucket of given key for given limit
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Limiter holds global limit and limits of routes with a store their buckets are kept in
+type Limiter struct {
+	store  Store
+	global Limit
+	routes map[string]Limit
+}
+
+// New returns limiter with given limits. Limits with no requests are disabled
+func New(store Store, global Limit, routes map[string]Limit) *Limiter {
+	return &Limiter{
+		store:  store,
+		global: global,
+		routes: routes,
+	}
+}
+
+// Global returns global limit and whether it is enabled
+func (l *Limiter) Global() (Limit, bool) {
+	return l.global, l.global.valid()
+}
+
+// Route returns limit of given route and whether there is one
+func (l *Limiter) Route(route string) (Limit, bool) {
+	limit, ok := l.routes[route]
+	return limit, ok && limit.valid()
+}
+
+// Take takes one token from the bucket of given key for given limit
+func (l *Limiter) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	return l.store.Take(ctx, key, limit)
+}
+
+func (l Limit) valid() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// rate returns number of tokens bucket is refilled with per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
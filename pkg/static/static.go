@@ -0,0 +1,144 @@
+// Package static serves files of file system. Responses carry ETag made of file's
+// content hash, so clients revalidate cached files cheaply with If-None-Match
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Handler serves files of file system by request path. Directories are never listed,
+// their 'index.html' is served instead if it exists. Hidden files are not served
+type Handler struct {
+	fsys         fs.FS
+	cacheControl string
+
+	mu    sync.Mutex
+	etags map[string]etag
+}
+
+// etag is ETag of file computed for its modification time and size, it is computed
+// again once file changes
+type etag struct {
+	modTime time.Time
+	size    int64
+	value   string
+}
+
+// New returns handler serving files of 'fsys' with given Cache-Control header value.
+// Request path is file name, so handler is mounted under prefix with http.StripPrefix
+func New(fsys fs.FS, cacheControl string) *Handler {
+	return &Handler{
+		fsys:         fsys,
+		cacheControl: cacheControl,
+		etags:        make(map[string]etag),
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+req.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+
+	f, stat, err := h.open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) || errors.Is(err, fs.ErrPermission) {
+			http.NotFound(w, req)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	tag, err := h.etag(name, stat, content)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", h.cacheControl)
+
+	// ServeContent answers If-None-Match with 304 and sets Content-Type by file extension
+	http.ServeContent(w, req, stat.Name(), stat.ModTime(), content)
+}
+
+// open opens regular file by name, 'index.html' of directory is opened for directory
+func (h *Handler) open(name string) (fs.File, fs.FileInfo, error) {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") && segment != "." {
+			return nil, nil, fs.ErrNotExist
+		}
+	}
+
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	if stat.IsDir() {
+		f.Close()
+		return h.open(path.Join(name, "index.html"))
+	}
+	if !stat.Mode().IsRegular() {
+		f.Close()
+		return nil, nil, fs.ErrNotExist
+	}
+
+	return f, stat, nil
+}
+
+// etag returns strong ETag of file content, it is cached until file's modification time or size change
+func (h *Handler) etag(name string, stat fs.FileInfo, content io.ReadSeeker) (string, error) {
+	h.mu.Lock()
+	cached, ok := h.etags[name]
+	h.mu.Unlock()
+
+	if ok && cached.modTime.Equal(stat.ModTime()) && cached.size == stat.Size() {
+		return cached.value, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	value := `"` + base64.RawURLEncoding.EncodeToString(hash.Sum(nil)[:16]) + `"`
+
+	h.mu.Lock()
+	h.etags[name] = etag{modTime: stat.ModTime(), size: stat.Size(), value: value}
+	h.mu.Unlock()
+
+	return value, nil
+}
@@ -0,0 +1,25 @@
+// Package thumbnail makes square thumbnails of images
+package thumbnail
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// Square returns image of 'size' x 'size' pixels made of center square of 'src'
+// scaled with Catmull-Rom filter. Transparent pixels are put on white background
+func Square(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+
+	side := min(b.Dx(), b.Dy())
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	crop := image.Rect(x, y, x+side, y+side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Over, nil)
+
+	return dst
+}
@@ -0,0 +1,78 @@
+package thumbnail_test
+
+import (
+	"image"
+	"image/color"
+	"inditilla/pkg/thumbnail"
+	"testing"
+)
+
+var (
+	red   = color.RGBA{R: 255, A: 255}
+	green = color.RGBA{G: 255, A: 255}
+	white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+)
+
+func TestSquare(t *testing.T) {
+	tests := []struct {
+		name   string
+		bounds image.Rectangle
+		size   int
+	}{
+		{"wide", image.Rect(0, 0, 300, 100), 64},
+		{"tall", image.Rect(0, 0, 100, 300), 64},
+		{"square", image.Rect(0, 0, 100, 100), 64},
+		{"upscaled", image.Rect(0, 0, 30, 10), 64},
+		{"shifted bounds", image.Rect(50, -20, 350, 80), 32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Center square of source image is green, the rest is red
+			src := image.NewRGBA(tt.bounds)
+			b := tt.bounds
+			side := min(b.Dx(), b.Dy())
+			center := image.Rect(0, 0, side, side).Add(image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2))
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if image.Pt(x, y).In(center) {
+						src.Set(x, y, green)
+					} else {
+						src.Set(x, y, red)
+					}
+				}
+			}
+
+			dst := thumbnail.Square(src, tt.size)
+
+			if got := dst.Bounds(); got != image.Rect(0, 0, tt.size, tt.size) {
+				t.Fatalf("bounds = %v; want %dx%d at origin", got, tt.size, tt.size)
+			}
+
+			// Only center square is scaled, so no red is blended in, even at the edges
+			for _, p := range []image.Point{{0, 0}, {tt.size - 1, 0}, {0, tt.size - 1}, {tt.size - 1, tt.size - 1}, {tt.size / 2, tt.size / 2}} {
+				if got := dst.RGBAAt(p.X, p.Y); got != green {
+					t.Errorf("pixel at %v = %v; want %v", p, got, green)
+				}
+			}
+		})
+	}
+}
+
+func TestSquareTransparent(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	for x := 0; x < 50; x++ {
+		for y := 0; y < 100; y++ {
+			src.Set(x, y, red)
+		}
+	}
+
+	dst := thumbnail.Square(src, 10)
+
+	if got := dst.RGBAAt(0, 5); got != red {
+		t.Errorf("opaque pixel = %v; want %v", got, red)
+	}
+	if got := dst.RGBAAt(9, 5); got != white {
+		t.Errorf("transparent pixel = %v; want white background %v", got, white)
+	}
+}
@@ -0,0 +1,151 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"inditilla/pkg/totp"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is base32 encoded ASCII secret "12345678901234567890" of RFC 6238 test vectors
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// RFC 6238 Appendix B, SHA1 mode. Vectors are 8 digits long, codes are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},          // 94287082
+		{1111111109, "081804"},  // 07081804
+		{1111111111, "050471"},  // 14050471
+		{1234567890, "005924"},  // 89005924
+		{2000000000, "279037"},  // 69279037
+		{20000000000, "353130"}, // 65353130
+	}
+
+	for _, tt := range tests {
+		got, err := totp.Code(rfcSecret, totp.Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s; want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeSecret(t *testing.T) {
+	lower, err := totp.Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1)
+	if err != nil {
+		t.Fatalf("Code with lower case secret: %v", err)
+	}
+	upper, _ := totp.Code(rfcSecret, 1)
+	if lower != upper {
+		t.Errorf("Code with lower case secret = %s; want %s", lower, upper)
+	}
+
+	if _, err := totp.Code("not base32!", 1); err == nil {
+		t.Error("Code with invalid secret succeeded; want error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := totp.Step(now)
+
+	code := func(offset int64) string {
+		c, err := totp.Code(rfcSecret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		wantStep int64
+		wantOk   bool
+	}{
+		{"current step", code(0), 1, step, true},
+		{"previous step within skew", code(-1), 1, step - 1, true},
+		{"next step within skew", code(1), 1, step + 1, true},
+		{"two steps late", code(-2), 1, 0, false},
+		{"two steps early", code(2), 1, 0, false},
+		{"previous step without skew", code(-1), 0, 0, false},
+		{"current step without skew", code(0), 0, step, true},
+		{"two steps late with wider skew", code(-2), 2, step - 2, true},
+		{"wrong code", "000000", 1, 0, false},
+		{"short code", code(0)[:5], 1, 0, false},
+		{"long code", code(0) + "0", 1, 0, false},
+		{"empty code", "", 1, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := totp.Validate(rfcSecret, tt.code, now, tt.skew)
+			if ok != tt.wantOk || gotStep != tt.wantStep {
+				t.Errorf("Validate = %d, %v; want %d, %v", gotStep, ok, tt.wantStep, tt.wantOk)
+			}
+		})
+	}
+
+	t.Run("invalid secret", func(t *testing.T) {
+		if _, ok := totp.Validate("not base32!", "123456", now, 1); ok {
+			t.Error("Validate with invalid secret succeeded")
+		}
+	})
+}
+
+func TestNewSecret(t *testing.T) {
+	first, err := totp.NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret: %v", err)
+	}
+	second, err := totp.NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret: %v", err)
+	}
+
+	if first == second {
+		t.Error("NewSecret returned the same secret twice")
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(first)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", first, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret is %d bytes long; want 20", len(key))
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(totp.URI("Acme Inc", "ann@example.com", rfcSecret))
+	if err != nil {
+		t.Fatalf("parse URI: %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("URI = %s; want otpauth://totp/...", uri)
+	}
+	if uri.Path != "/Acme Inc:ann@example.com" {
+		t.Errorf("label = %q; want %q", uri.Path, "/Acme Inc:ann@example.com")
+	}
+
+	want := map[string]string{
+		"secret":    rfcSecret,
+		"issuer":    "Acme Inc",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	}
+	for key, value := range want {
+		if got := uri.Query().Get(key); got != value {
+			t.Errorf("%s = %q; want %q", key, got, value)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Package tracing sets up OpenTelemetry tracer provider with exporter set in options and
+// W3C trace context propagation. Packages create spans with otel.Tracer of their import path
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// Options are settings of tracer provider
+type Options struct {
+	// Exporter is one of 'otlp' (OTLP over HTTP), 'stdout' or 'none' (spans are not recorded)
+	Exporter string
+
+	// Endpoint is host:port of OTLP collector, Insecure sends spans over plain HTTP
+	Endpoint string
+	Insecure bool
+
+	// SampleRatio is ratio of traces started here that are recorded, from 0 to 1. Traces
+	// started by callers are recorded if callers recorded them
+	SampleRatio float64
+
+	ServiceName    string
+	ServiceVersion string
+}
+
+// New sets global tracer provider and propagator and returns function flushing spans
+// and shutting provider down. Incoming trace context is propagated even if exporter is 'none'
+func New(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch opts.Exporter {
+	case "otlp":
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(opts.Endpoint)}
+		if opts.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+
+		e, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: %v", err)
+		}
+		exporter = e
+	case "stdout":
+		e, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("tracing: %v", err)
+		}
+		exporter = e
+	case "none", "":
+		return func(context.Context) error { return nil }, nil
+	default:
+		return nil, fmt.Errorf("tracing: unsupported exporter %q", opts.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+		semconv.ServiceVersion(opts.ServiceVersion),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,323 @@
+// Pages of profile web UI. Scripts are loaded from file, not inline, to comply with
+// Content-Security-Policy of the application
+'use strict';
+
+const tokens = {
+	get access() { return localStorage.getItem('access_token'); },
+	get refresh() { return localStorage.getItem('refresh_token'); },
+
+	save(pair) {
+		localStorage.setItem('access_token', pair.access_token);
+		localStorage.setItem('refresh_token', pair.refresh_token);
+	},
+
+	clear() {
+		localStorage.removeItem('access_token');
+		localStorage.removeItem('refresh_token');
+	},
+
+	// userId returns id of logged in user from access token claims
+	userId() {
+		const payload = this.access.split('.')[1].replace(/-/g, '+').replace(/_/g, '/');
+		return JSON.parse(atob(payload)).uid;
+	},
+};
+
+// api sends request to JSON API and returns response with decoded body. Access token
+// is refreshed once if it is expired
+async function api(method, path, { body, headers = {}, auth = false, retry = true } = {}) {
+	const init = { method, headers: { ...headers } };
+
+	if (body instanceof FormData) {
+		init.body = body;
+	} else if (body !== undefined) {
+		init.headers['Content-Type'] = 'application/json';
+		init.body = JSON.stringify(body);
+	}
+	if (auth) {
+		init.headers['Authorization'] = 'Bearer ' + tokens.access;
+	}
+
+	const resp = await fetch(path, init);
+	const text = await resp.text();
+	const data = text ? JSON.parse(text) : null;
+
+	if (resp.status === 401 && auth && retry && await refresh()) {
+		return api(method, path, { body, headers, auth, retry: false });
+	}
+
+	return { status: resp.status, ok: resp.ok, headers: resp.headers, data };
+}
+
+async function refresh() {
+	if (!tokens.refresh) {
+		return false;
+	}
+
+	const resp = await api('POST', '/v1/user/token/refresh', { body: { refresh_token: tokens.refresh } });
+	if (!resp.ok) {
+		return false;
+	}
+
+	tokens.save(resp.data);
+	return true;
+}
+
+function showMessage(text, isError = false) {
+	const el = document.getElementById('message');
+	el.textContent = text;
+	el.classList.toggle('error', isError);
+	el.hidden = false;
+}
+
+// showError shows error response message along with its validation errors
+function showError(resp) {
+	const data = resp.data || {};
+	const lines = [data.message || 'request failed with status ' + resp.status];
+
+	for (const [field, message] of Object.entries(data.validations || {})) {
+		lines.push(field + ': ' + message);
+	}
+
+	showMessage(lines.join('\n'), true);
+}
+
+function formValues(form) {
+	return Object.fromEntries(new FormData(form).entries());
+}
+
+function initSignup() {
+	document.getElementById('signup-form').addEventListener('submit', async (event) => {
+		event.preventDefault();
+
+		const resp = await api('POST', '/v1/user/signup', { body: formValues(event.target) });
+		if (!resp.ok) {
+			showError(resp);
+			return;
+		}
+
+		event.target.reset();
+		showMessage('Account is created, you can log in now. Check your email to verify it.');
+	});
+}
+
+function initLogin() {
+	const loginForm = document.getElementById('login-form');
+	const mfaForm = document.getElementById('mfa-form');
+	let mfaToken = '';
+
+	const loggedIn = (pair) => {
+		tokens.save(pair);
+		location.assign('profile.html');
+	};
+
+	loginForm.addEventListener('submit', async (event) => {
+		event.preventDefault();
+
+		const resp = await api('POST', '/v1/user/login', { body: formValues(loginForm) });
+		if (!resp.ok) {
+			showError(resp);
+			return;
+		}
+
+		// User with MFA enabled completes log in with code from authenticator app
+		if (resp.status === 202) {
+			mfaToken = resp.data.mfa_token;
+			loginForm.hidden = true;
+			mfaForm.hidden = false;
+			return;
+		}
+
+		loggedIn(resp.data);
+	});
+
+	mfaForm.addEventListener('submit', async (event) => {
+		event.preventDefault();
+
+		const resp = await api('POST', '/v1/user/login/mfa', {
+			body: { mfa_token: mfaToken, code: formValues(mfaForm).code },
+		});
+		if (!resp.ok) {
+			showError(resp);
+			return;
+		}
+
+		loggedIn(resp.data);
+	});
+}
+
+// initResetPassword sends reset link to given email, or sets new password if page is
+// opened by the link with reset token
+function initResetPassword() {
+	const forgotForm = document.getElementById('forgot-form');
+	const resetForm = document.getElementById('reset-form');
+	const token = new URLSearchParams(location.search).get('token');
+
+	if (token) {
+		forgotForm.hidden = true;
+		resetForm.hidden = false;
+	}
+
+	forgotForm.addEventListener('submit', async (event) => {
+		event.preventDefault();
+
+		const resp = await api('POST', '/v1/user/password/forgot', { body: formValues(forgotForm) });
+		if (!resp.ok) {
+			showError(resp);
+			return;
+		}
+
+		forgotForm.reset();
+		showMessage(resp.data.message);
+	});
+
+	resetForm.addEventListener('submit', async (event) => {
+		event.preventDefault();
+
+		const resp = await api('POST', '/v1/user/password/reset', {
+			body: { token, password: formValues(resetForm).password },
+		});
+		if (!resp.ok) {
+			showError(resp);
+			return;
+		}
+
+		resetForm.hidden = true;
+		showMessage('Password is changed, you can log in with it now.');
+	});
+}
+
+// initVerifyEmail verifies email by token of the link page is opened by. New link could be
+// requested if token is missing or expired
+async function initVerifyEmail() {
+	const resendForm = document.getElementById('resend-form');
+	const token = new URLSearchParams(location.search).get('token');
+
+	resendForm.addEventListener('submit', async (event) => {
+		event.preventDefault();
+
+		const resp = await api('POST', '/v1/user/email/verify/resend', { body: formValues(resendForm) });
+		if (!resp.ok) {
+			showError(resp);
+			return;
+		}
+
+		resendForm.reset();
+		showMessage(resp.data.message);
+	});
+
+	if (!token) {
+		resendForm.hidden = false;
+		return;
+	}
+
+	const resp = await api('POST', '/v1/user/email/verify', { body: { token } });
+	if (!resp.ok) {
+		showError(resp);
+		resendForm.hidden = false;
+		return;
+	}
+
+	showMessage('Email is verified.');
+}
+
+const profileFields = ['firstName', 'lastName', 'email', 'displayName', 'bio', 'phone', 'dateOfBirth', 'locale', 'timezone', 'website'];
+
+async function initProfile() {
+	if (!tokens.access) {
+		location.assign('index.html');
+		return;
+	}
+
+	const path = '/v1/user/profile/' + tokens.userId();
+	const form = document.getElementById('profile-form');
+	let etag = '';
+	let profile = {};
+
+	const render = (resp) => {
+		etag = resp.headers.get('ETag');
+		profile = resp.data;
+
+		for (const name of profileFields) {
+			form.elements[name].value = profile[name] || '';
+		}
+		form.elements.password.value = '';
+
+		const pending = document.getElementById('pending-email');
+		pending.textContent = profile.pendingEmail ? 'Email ' + profile.pendingEmail + ' waits for verification.' : '';
+		pending.hidden = !profile.pendingEmail;
+
+		const avatar = document.getElementById('avatar');
+		avatar.hidden = !profile.avatarUrl;
+		if (profile.avatarUrl) {
+			avatar.src = profile.avatarUrl.replace(/-256\.jpg$/, '-128.jpg');
+		}
+	};
+
+	const resp = await api('GET', path, { auth: true });
+	if (resp.status === 401) {
+		tokens.clear();
+		location.assign('index.html');
+		return;
+	}
+	if (!resp.ok) {
+		showError(resp);
+		return;
+	}
+	render(resp);
+
+	form.addEventListener('submit', async (event) => {
+		event.preventDefault();
+
+		// Only changed fields are sent, update fails if profile was changed since it was loaded
+		const values = formValues(form);
+		const changes = {};
+		for (const name of profileFields) {
+			if (values[name] !== (profile[name] || '')) {
+				changes[name] = values[name];
+			}
+		}
+		if (values.password) {
+			changes.password = values.password;
+		}
+
+		const resp = await api('PATCH', path, { body: changes, headers: { 'If-Match': etag }, auth: true });
+		if (!resp.ok) {
+			showError(resp);
+			return;
+		}
+
+		render(resp);
+		showMessage('Profile is saved.');
+	});
+
+	document.getElementById('avatar-form').addEventListener('submit', async (event) => {
+		event.preventDefault();
+
+		const resp = await api('PUT', path + '/avatar', { body: new FormData(event.target), auth: true });
+		if (!resp.ok) {
+			showError(resp);
+			return;
+		}
+
+		event.target.reset();
+		render(resp);
+		showMessage('Avatar is uploaded.');
+	});
+
+	document.getElementById('logout').addEventListener('click', async () => {
+		await api('POST', '/v1/user/logout', { auth: true });
+		tokens.clear();
+		location.assign('index.html');
+	});
+}
+
+const pages = {
+	signup: initSignup,
+	login: initLogin,
+	profile: initProfile,
+	'reset-password': initResetPassword,
+	'verify-email': initVerifyEmail,
+};
+
+pages[document.body.dataset.page]();
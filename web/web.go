@@ -0,0 +1,21 @@
+// Package web holds profile web UI. Pages call JSON API of the application, they
+// are embedded into the binary and served under /ui/
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed ui
+var files embed.FS
+
+// UI returns file system with pages and assets of web UI
+func UI() fs.FS {
+	ui, err := fs.Sub(files, "ui")
+	if err != nil {
+		panic(err)
+	}
+
+	return ui
+}